@@ -0,0 +1,266 @@
+// Package agent implements a long-running process that keeps vault items in
+// memory and serves TOTP codes over a Unix socket, similar to ssh-agent.
+// Secrets never leave the agent, clients get generated codes only.
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const (
+	// SocketEnv is the environment variable overriding agent socket path
+	SocketEnv = "CLOTP_AGENT_SOCK"
+
+	socketName = "agent.sock"
+
+	opCode  = "code"
+	opNames = "names"
+	opPing  = "ping"
+	opStop  = "stop"
+
+	// connTimeout limits a request, so a stuck client doesn't block its handler
+	connTimeout = 5 * time.Second
+)
+
+var ErrAgentStopped = errors.New("agent is stopped")
+
+// SocketPath returns agent socket path: $CLOTP_AGENT_SOCK if set, otherwise
+// clotp/agent.sock in $XDG_RUNTIME_DIR or in a per-user temporary directory
+func SocketPath() string {
+	if p := os.Getenv(SocketEnv); p != "" {
+		return p
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "clotp", socketName)
+	}
+
+	return filepath.Join(os.TempDir(), "clotp-"+strconv.Itoa(os.Getuid()), socketName)
+}
+
+type request struct {
+	Op     string `json:"op"`
+	Name   string `json:"name,omitempty"`
+	Time   int64  `json:"time,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+}
+
+type response struct {
+	Code  *totp.Code `json:"code,omitempty"`
+	Names []string   `json:"names,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// NewServer returns agent server holding given items, it stops after being
+// idle for given timeout, zero timeout means no timeout
+func NewServer(items []*vault.Item, timeout time.Duration) *Server {
+	return &Server{
+		items:   items,
+		timeout: timeout,
+		done:    make(chan struct{}),
+	}
+}
+
+type Server struct {
+	mu      sync.Mutex
+	items   []*vault.Item
+	timeout time.Duration
+	timer   *time.Timer
+
+	listener net.Listener
+	done     chan struct{}
+	once     sync.Once
+}
+
+// Listen creates socket at given path, its directory is created with
+// owner-only permissions. An existing directory should be owned by the user
+// and have 0700 mode, so nobody else can replace the socket, e.g. in a shared
+// temporary directory.
+func (s *Server) Listen(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	if err := checkSocketDir(dir); err != nil {
+		return err
+	}
+
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return fmt.Errorf("agent is already running on %s", path)
+	}
+
+	// socket may be left by a crashed agent
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	s.listener = l
+
+	return nil
+}
+
+// Serve accepts connections until the server is stopped or idle timeout expires
+func (s *Server) Serve() error {
+	if s.timeout > 0 {
+		s.mu.Lock()
+		s.timer = time.AfterFunc(s.timeout, s.Stop)
+		s.mu.Unlock()
+	}
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		go s.handle(conn)
+	}
+}
+
+// Done returns channel closed when the server stops
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stop stops the server, wipes and forgets items
+func (s *Server) Stop() {
+	s.once.Do(func() {
+		close(s.done)
+
+		s.mu.Lock()
+		for _, i := range s.items {
+			i.Wipe()
+		}
+		s.items = nil
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		s.mu.Unlock()
+
+		if s.listener != nil {
+			s.listener.Close()
+		}
+	})
+}
+
+// touch resets idle timer, only generated codes count as use, so pings of
+// every clotp run don't keep the agent alive
+func (s *Server) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Reset(s.timeout)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	if req.Op == opCode {
+		s.touch()
+	}
+
+	resp := s.process(req)
+
+	_ = json.NewEncoder(conn).Encode(resp)
+
+	if req.Op == opStop {
+		s.Stop()
+	}
+}
+
+func checkSocketDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !info.IsDir():
+		return fmt.Errorf("socket directory %s isn't a directory", dir)
+	case !ownedByUser(info):
+		return fmt.Errorf("socket directory %s isn't owned by the user", dir)
+	case info.Mode().Perm() != 0700:
+		return fmt.Errorf("socket directory %s should have 0700 mode, got: %04o", dir, info.Mode().Perm())
+	}
+
+	return nil
+}
+
+func (s *Server) process(req request) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Op {
+	case opPing, opStop:
+		return response{}
+	case opNames:
+		names := make([]string, 0, len(s.items))
+		for _, i := range s.items {
+			names = append(names, i.Name)
+		}
+
+		return response{Names: names}
+	case opCode:
+		for _, i := range s.items {
+			if i.Name != req.Name {
+				continue
+			}
+
+			t, err := i.TOTP()
+			if err != nil {
+				return response{Error: err.Error()}
+			}
+			defer t.Wipe()
+
+			step := int64(t.CounterAt(time.Unix(req.Time, 0))) + int64(req.Offset)
+			if step < 0 {
+				return response{Error: "step is out of range"}
+			}
+
+			code := t.CodeFor(uint64(step))
+
+			return response{Code: &code}
+		}
+
+		return response{Error: fmt.Sprintf("%v: %s", vault.ErrItemNotFound, req.Name)}
+	default:
+		return response{Error: "unknown operation: " + req.Op}
+	}
+}
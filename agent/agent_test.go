@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func startServer(items []*vault.Item, timeout time.Duration) (*Server, string, func()) {
+	dir, err := ioutil.TempDir("", "agent")
+	if err != nil {
+		panic(err)
+	}
+
+	path := filepath.Join(dir, "sub", socketName)
+
+	s := NewServer(items, timeout)
+	if err := s.Listen(path); err != nil {
+		panic(err)
+	}
+
+	go func() {
+		if err := s.Serve(); err != nil {
+			panic(err)
+		}
+	}()
+
+	return s, path, func() {
+		s.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAgent(t *testing.T) {
+	items := []*vault.Item{
+		{Name: "n1", Key: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"), Digits: 8},
+		{Name: "n2", Key: []byte("GE")},
+	}
+
+	_, path, stop := startServer(items, 0)
+	defer stop()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		panic(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("wrong socket permissions: %s", info.Mode())
+	}
+
+	c := NewClient(path)
+
+	if err := c.Ping(); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+
+	names, err := c.Names()
+	if err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"n1", "n2"}) {
+		t.Errorf("wrong names: %v", names)
+	}
+
+	// RFC 6238 test vectors
+	for _, tc := range []struct {
+		offset int
+		want   string
+	}{
+		{offset: 0, want: "07081804"},
+		{offset: 1, want: "14050471"},
+	} {
+		code, err := c.Code("n1", time.Unix(1111111109, 0), tc.offset)
+		if err != nil {
+			t.Errorf("unwanted error: %v", err)
+			continue
+		}
+
+		if code.Value != tc.want {
+			t.Errorf("wrong code for %d offset, want: %s != got: %s", tc.offset, tc.want, code.Value)
+		}
+
+		if code.ValidUntil.Sub(code.ValidFrom) != 30*time.Second {
+			t.Errorf("wrong validity interval: %s - %s", code.ValidFrom, code.ValidUntil)
+		}
+	}
+
+	if _, err := c.Code("unknown", time.Now(), 0); !errors.Is(err, vault.ErrItemNotFound) {
+		t.Errorf("error should match with %v, got: %v", vault.ErrItemNotFound, err)
+	}
+}
+
+func TestAgent_Stop(t *testing.T) {
+	s, path, stop := startServer([]*vault.Item{{Name: "n", Key: []byte("GE")}}, 0)
+	defer stop()
+
+	c := NewClient(path)
+	if err := c.Stop(); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("agent wasn't stopped")
+	}
+
+	if err := c.Ping(); !errors.Is(err, ErrAgentStopped) {
+		t.Errorf("error should match with %v, got: %v", ErrAgentStopped, err)
+	}
+}
+
+func TestAgent_IdleTimeout(t *testing.T) {
+	s, path, stop := startServer([]*vault.Item{{Name: "n", Key: []byte("GE")}}, 200*time.Millisecond)
+	defer stop()
+
+	c := NewClient(path)
+
+	// codes reset idle timer
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+
+		if _, err := c.Code("n", time.Now(), 0); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+	}
+
+	// pings don't
+	for i := 0; i < 3; i++ {
+		if err := c.Ping(); err != nil {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("agent wasn't stopped after idle timeout")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.items != nil {
+		t.Error("items should be forgotten")
+	}
+}
+
+func TestServer_Listen_AlreadyRunning(t *testing.T) {
+	_, path, stop := startServer(nil, 0)
+	defer stop()
+
+	if err := NewServer(nil, 0).Listen(path); err == nil {
+		t.Error("error shouldn't be nil when agent is already running")
+	}
+}
+
+func TestServer_Listen_SocketDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agent")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	shared := filepath.Join(dir, "shared")
+	if err := os.Mkdir(shared, 0700); err != nil {
+		panic(err)
+	}
+
+	// e.g. created by other user in /tmp
+	if err := os.Chmod(shared, 0777); err != nil {
+		panic(err)
+	}
+
+	if err := NewServer(nil, 0).Listen(filepath.Join(shared, socketName)); err == nil {
+		t.Error("socket is created in the directory writable by others")
+	}
+
+	if err := os.Symlink(shared, filepath.Join(dir, "link")); err != nil {
+		panic(err)
+	}
+
+	if err := NewServer(nil, 0).Listen(filepath.Join(dir, "link", socketName)); err == nil {
+		t.Error("socket is created in symlinked directory")
+	}
+}
+
+func TestServer_Deadline(t *testing.T) {
+	_, path, stop := startServer(nil, 0)
+	defer stop()
+
+	// a client sending nothing doesn't block others
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	if err := NewClient(path).Ping(); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	defer os.Setenv(SocketEnv, os.Getenv(SocketEnv))
+	defer os.Setenv("XDG_RUNTIME_DIR", os.Getenv("XDG_RUNTIME_DIR"))
+
+	os.Setenv(SocketEnv, "/tmp/custom.sock")
+	if got := SocketPath(); got != "/tmp/custom.sock" {
+		t.Errorf("wrong socket path: %s", got)
+	}
+
+	os.Setenv(SocketEnv, "")
+	os.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got := SocketPath(); got != "/run/user/1000/clotp/agent.sock" {
+		t.Errorf("wrong socket path: %s", got)
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const dialTimeout = time.Second
+
+// NewClient returns client of the agent listening on given socket path
+func NewClient(path string) *Client {
+	return &Client{path}
+}
+
+type Client struct {
+	path string
+}
+
+// Ping checks whether the agent is running
+func (c *Client) Ping() error {
+	_, err := c.do(request{Op: opPing})
+	return err
+}
+
+// Code returns code of the named item for the step of given time shifted by
+// offset steps, e.g. offset 1 returns the next code
+func (c *Client) Code(name string, at time.Time, offset int) (totp.Code, error) {
+	resp, err := c.do(request{Op: opCode, Name: name, Time: at.Unix(), Offset: offset})
+	if err != nil {
+		return totp.Code{}, err
+	}
+
+	if resp.Code == nil {
+		return totp.Code{}, errors.New("agent returned no code")
+	}
+
+	return *resp.Code, nil
+}
+
+// Names returns names of the items held by the agent
+func (c *Client) Names() ([]string, error) {
+	resp, err := c.do(request{Op: opNames})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Names, nil
+}
+
+// Stop asks the agent to forget items and exit
+func (c *Client) Stop() error {
+	_, err := c.do(request{Op: opStop})
+	return err
+}
+
+func (c *Client) do(req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.path, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAgentStopped, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid agent response: %w", err)
+	}
+
+	if resp.Error != "" {
+		for _, err := range []error{vault.ErrItemNotFound, vault.ErrOCRAItem} {
+			if strings.HasPrefix(resp.Error, err.Error()) {
+				return nil, fmt.Errorf("%w%s", err, strings.TrimPrefix(resp.Error, err.Error()))
+			}
+		}
+
+		return nil, errors.New(resp.Error)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package agent
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByUser reports whether the file is owned by the user of the process
+func ownedByUser(info os.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+
+	return ok && int(st.Uid) == os.Getuid()
+}
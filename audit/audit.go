@@ -0,0 +1,259 @@
+// Package audit implements append-only local log of vault events: items
+// added, edited and removed, codes generated, imports and exports. Neither
+// codes nor secrets are logged.
+//
+// Events may be hash-chained: every event keeps the hash of the previous one,
+// so editing, inserting or removing events is detected. Removing events from
+// the end of the log can't be detected without keeping the last hash
+// elsewhere.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+const (
+	ActionAdded   = "added"
+	ActionEdited  = "edited"
+	ActionRemoved = "removed"
+	ActionCode    = "code"
+	ActionImport  = "import"
+	ActionExport  = "export"
+	// recipient actions are changes of shared vault recipients, the item is
+	// empty and the detail is the recipient key
+	ActionRecipientAdded   = "recipient-added"
+	ActionRecipientRemoved = "recipient-removed"
+
+	// DefaultName is the log file name in the config directory
+	DefaultName = "audit.log"
+
+	maxLine = 64 << 10
+	// tailChunk is how much of the log is read at once looking for the last
+	// chained event
+	tailChunk = 4 << 10
+)
+
+var ErrTampered = errors.New("audit log is tampered")
+
+// Event is a log line
+type Event struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Item   string    `json:"item,omitempty"`
+	// Detail is the command or the format of the event
+	Detail string `json:"detail,omitempty"`
+	// Prev is the hash of the previous chained event, empty for the first one
+	Prev string `json:"prev,omitempty"`
+	// Hash is empty if the event isn't chained
+	Hash string `json:"hash,omitempty"`
+}
+
+// Opts are log options
+type Opts struct {
+	// Chain makes new events hash-chained
+	Chain bool
+	// Clock is SystemClock if nil
+	Clock totp.Clock
+}
+
+// Open returns log kept in the file, the file is created by the first Record
+func Open(path string, opts Opts) *Log {
+	if opts.Clock == nil {
+		opts.Clock = totp.SystemClock
+	}
+
+	return &Log{path: path, opts: opts}
+}
+
+type Log struct {
+	path string
+	opts Opts
+}
+
+// Record appends event to the log. The file is locked while the previous hash
+// is read and the event is appended, so concurrent runs don't fork the chain.
+func (l *Log) Record(action, item, detail string) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return err
+	}
+
+	e := Event{Time: l.opts.Clock.Now().UTC(), Action: action, Item: item, Detail: detail}
+
+	if l.opts.Chain {
+		if e.Prev, err = lastHash(f); err != nil {
+			return err
+		}
+
+		if e.Hash, err = hash(e); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// Events reads the log and verifies its hash chain. Events are returned even
+// if the log is tampered, the error tells the first line the chain is broken at.
+// Missing log has no events.
+func (l *Log) Events() ([]Event, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		events   []Event
+		last     string
+		tampered error
+	)
+
+	err = scan(f, func(n int, line []byte) {
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			if tampered == nil {
+				tampered = fmt.Errorf("%w: line %d isn't an event", ErrTampered, n)
+			}
+
+			return
+		}
+
+		events = append(events, e)
+
+		if e.Hash == "" {
+			return
+		}
+
+		if h, err := hash(e); (err != nil || h != e.Hash || e.Prev != last) && tampered == nil {
+			tampered = fmt.Errorf("%w: hash chain is broken at line %d", ErrTampered, n)
+		}
+
+		last = e.Hash
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, tampered
+}
+
+// lastHash returns hash of the last chained event. The file is read backwards
+// by chunks, so usually the last line is read only.
+func lastHash(f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// data is the unread part of the lines before off
+	var data []byte
+
+	for off := info.Size(); ; {
+		// the line after a newline is complete, the first one is complete
+		// once the file start is read
+		for {
+			i := bytes.LastIndexByte(data, '\n')
+			if i < 0 && off > 0 {
+				break
+			}
+
+			line := data[i+1:]
+			if i < 0 {
+				data = data[:0]
+			} else {
+				data = data[:i]
+			}
+
+			var e Event
+			if json.Unmarshal(line, &e) == nil && e.Hash != "" {
+				return e.Hash, nil
+			}
+
+			if i < 0 {
+				return "", nil
+			}
+		}
+
+		if len(data) > maxLine {
+			return "", bufio.ErrTooLong
+		}
+
+		n := int64(tailChunk)
+		if off < n {
+			n = off
+		}
+
+		off -= n
+
+		chunk := make([]byte, n, int(n)+len(data))
+		if _, err := f.ReadAt(chunk, off); err != nil {
+			return "", err
+		}
+
+		data = append(chunk, data...)
+	}
+}
+
+func scan(r io.Reader, fn func(n int, line []byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		fn(n, scanner.Bytes())
+	}
+
+	return scanner.Err()
+}
+
+// hash is the hash of the event JSON without the hash itself
+func hash(e Event) (string, error) {
+	e.Hash = ""
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
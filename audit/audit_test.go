@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+var testTime = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestLog(chain bool) (*Log, string, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		panic(err)
+	}
+
+	path := filepath.Join(dir, "clotp", DefaultName)
+	now := testTime
+
+	l := Open(path, Opts{Chain: chain, Clock: totp.ClockFunc(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	})})
+
+	return l, path, func() { os.RemoveAll(dir) }
+}
+
+func record(l *Log) {
+	for _, e := range [][3]string{
+		{ActionAdded, "github", "new"},
+		{ActionCode, "github", "get"},
+		{ActionExport, "", "aegis"},
+	} {
+		if err := l.Record(e[0], e[1], e[2]); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	for _, chain := range []bool{true, false} {
+		l, path, cleanup := newTestLog(chain)
+		defer cleanup()
+
+		record(l)
+
+		events, err := l.Events()
+		if err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+
+		if len(events) != 3 {
+			t.Fatalf("3 events expected, got: %+v", events)
+		}
+
+		if e := events[1]; e.Action != ActionCode || e.Item != "github" || e.Detail != "get" || !e.Time.Equal(testTime.Add(2*time.Minute)) {
+			t.Errorf("wrong event: %+v", e)
+		}
+
+		chained := events[0].Prev == "" && events[1].Prev == events[0].Hash && events[2].Prev == events[1].Hash && events[2].Hash != ""
+		if chained != chain {
+			t.Errorf("events should be chained: %v, got: %+v", chain, events)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			panic(err)
+		}
+
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("log should be accessible by owner only, got: %s", info.Mode())
+		}
+	}
+}
+
+func TestLog_Tampered(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		tamper func(lines []string) []string
+		line   string
+	}{
+		{
+			name:   "edited",
+			tamper: func(l []string) []string { l[1] = strings.Replace(l[1], "github", "gitlab", 1); return l },
+			line:   "line 2",
+		},
+		{
+			name:   "removed",
+			tamper: func(l []string) []string { return append(l[:1], l[2:]...) },
+			line:   "line 2",
+		},
+		{
+			name:   "first removed",
+			tamper: func(l []string) []string { return l[1:] },
+			line:   "line 1",
+		},
+		{
+			name:   "swapped",
+			tamper: func(l []string) []string { l[0], l[1] = l[1], l[0]; return l },
+			line:   "line 1",
+		},
+		{
+			name:   "garbage",
+			tamper: func(l []string) []string { return append(l, "garbage") },
+			line:   "line 4",
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			l, path, cleanup := newTestLog(true)
+			defer cleanup()
+
+			record(l)
+
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				panic(err)
+			}
+
+			lines := c.tamper(strings.Split(strings.TrimSpace(string(content)), "\n"))
+			if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+				panic(err)
+			}
+
+			_, err = l.Events()
+			if !errors.Is(err, ErrTampered) || !strings.Contains(err.Error(), c.line) {
+				t.Errorf("tampering at %s expected, got: %v", c.line, err)
+			}
+		})
+	}
+}
+
+func TestLog_Missing(t *testing.T) {
+	l, _, cleanup := newTestLog(true)
+	defer cleanup()
+
+	events, err := l.Events()
+	if err != nil || len(events) != 0 {
+		t.Errorf("missing log should have no events, got: %+v, %v", events, err)
+	}
+}
+
+func TestLog_ChainResumed(t *testing.T) {
+	l, _, cleanup := newTestLog(true)
+	defer cleanup()
+
+	record(l)
+
+	// unchained events longer than a read chunk are skipped looking for the
+	// last hash
+	unchained := Open(l.path, Opts{Clock: l.opts.Clock})
+	for i := 0; i < 10; i++ {
+		if err := unchained.Record(ActionCode, "github", strings.Repeat("get", 500)); err != nil {
+			panic(err)
+		}
+	}
+
+	record(l)
+
+	if _, err := l.Events(); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+}
+
+func TestLog_Concurrent(t *testing.T) {
+	_, path, cleanup := newTestLog(true)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// like separate clotp runs
+			if err := Open(path, Opts{Chain: true}).Record(ActionCode, "github", "get"); err != nil {
+				t.Errorf("unwanted error: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	events, err := Open(path, Opts{}).Events()
+	if err != nil || len(events) != 50 {
+		t.Errorf("chain is forked: %d events, %v", len(events), err)
+	}
+}
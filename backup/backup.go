@@ -0,0 +1,265 @@
+// Package backup implements self-contained passphrase-protected archives of
+// vault items. The passphrase is stretched with scrypt and items are sealed
+// with XChaCha20-Poly1305, KDF parameters are stored in the archive.
+package backup
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/mullakhmetov/clotp/securemem"
+	"github.com/mullakhmetov/clotp/vault"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	format  = "clotp-backup"
+	version = 1
+
+	kdfScrypt        = "scrypt"
+	cipherXChaCha    = "xchacha20-poly1305"
+	saltSize         = 16
+	maxArchiveSize   = 16 << 20
+	maxScryptCostLog = 22
+	maxScryptR       = 32
+	maxScryptP       = 16
+)
+
+// scrypt parameters of new archives, see https://pkg.go.dev/golang.org/x/crypto/scrypt
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrInvalidArchive  = errors.New("invalid backup archive")
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted archive")
+)
+
+// header is the unencrypted part of the archive, it is authenticated as
+// additional data of the ciphertext
+type header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	KDF     kdf    `json:"kdf"`
+	Cipher  string `json:"cipher"`
+	Nonce   []byte `json:"nonce"`
+}
+
+type kdf struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+type archive struct {
+	header
+	Data []byte `json:"data"`
+}
+
+type payload struct {
+	Created time.Time `json:"created"`
+	Items   []item    `json:"items"`
+}
+
+// item mirrors vault.Item, so the archive format doesn't depend on its tags
+type item struct {
+	Name      string `json:"name"`
+	Issuer    string `json:"issuer,omitempty"`
+	Secret    secret `json:"secret"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Digits    int    `json:"digits,omitempty"`
+	Step      int    `json:"step,omitempty"`
+	T0        int64  `json:"t0,omitempty"`
+	Suite     string `json:"ocra,omitempty"`
+	Counter   uint64 `json:"counter,omitempty"`
+}
+
+// Write writes archive of items encrypted with the passphrase to w
+func Write(w io.Writer, items []*vault.Item, passphrase []byte, created time.Time) error {
+	p := payload{Created: created.UTC(), Items: make([]item, 0, len(items))}
+	for _, i := range items {
+		p.Items = append(p.Items, item{
+			Name:      i.Name,
+			Issuer:    i.Issuer,
+			Secret:    secret(i.Key),
+			Algorithm: i.Algorithm,
+			Digits:    i.Digits,
+			Step:      i.Step,
+			T0:        i.T0,
+			Suite:     i.Suite,
+			Counter:   i.Counter,
+		})
+	}
+
+	plaintext, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	defer securemem.Wipe(plaintext)
+
+	h := header{
+		Format:  format,
+		Version: version,
+		KDF:     kdf{Name: kdfScrypt, Salt: make([]byte, saltSize), N: scryptN, R: scryptR, P: scryptP},
+		Cipher:  cipherXChaCha,
+		Nonce:   make([]byte, chacha20poly1305.NonceSizeX),
+	}
+
+	if _, err := rand.Read(h.KDF.Salt); err != nil {
+		return err
+	}
+
+	if _, err := rand.Read(h.Nonce); err != nil {
+		return err
+	}
+
+	aead, err := h.aead(passphrase)
+	if err != nil {
+		return err
+	}
+
+	ad, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	a := archive{header: h, Data: aead.Seal(nil, h.Nonce, plaintext, ad)}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(a)
+}
+
+// Read decrypts archive read from r with the passphrase and returns its items
+// and the time it was created at
+func Read(r io.Reader, passphrase []byte) ([]*vault.Item, time.Time, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxArchiveSize))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var a archive
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	if err := a.validate(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	aead, err := a.aead(passphrase)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	ad, err := json.Marshal(a.header)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	plaintext, err := aead.Open(nil, a.Nonce, a.Data, ad)
+	if err != nil {
+		return nil, time.Time{}, ErrWrongPassphrase
+	}
+	defer securemem.Wipe(plaintext)
+
+	var p payload
+	if err := json.Unmarshal(plaintext, &p); err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	items := make([]*vault.Item, 0, len(p.Items))
+	for _, i := range p.Items {
+		items = append(items, &vault.Item{
+			Name:      i.Name,
+			Issuer:    i.Issuer,
+			Key:       i.Secret,
+			Algorithm: i.Algorithm,
+			Digits:    i.Digits,
+			Step:      i.Step,
+			T0:        i.T0,
+			Suite:     i.Suite,
+			Counter:   i.Counter,
+		})
+	}
+
+	return items, p.Created, nil
+}
+
+func (a archive) validate() error {
+	switch {
+	case a.Format != format:
+		return fmt.Errorf("%w: not a clotp backup", ErrInvalidArchive)
+	case a.Version != version:
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, a.Version)
+	case a.KDF.Name != kdfScrypt || a.Cipher != cipherXChaCha:
+		return fmt.Errorf("%w: unsupported encryption %s/%s", ErrInvalidArchive, a.KDF.Name, a.Cipher)
+	case a.KDF.N < 2 || a.KDF.N > 1<<maxScryptCostLog ||
+		a.KDF.R < 1 || a.KDF.R > maxScryptR || a.KDF.P < 1 || a.KDF.P > maxScryptP:
+		// limits memory a crafted archive makes us allocate
+		return fmt.Errorf("%w: unsupported scrypt parameters", ErrInvalidArchive)
+	case len(a.Nonce) != chacha20poly1305.NonceSizeX:
+		return fmt.Errorf("%w: invalid nonce", ErrInvalidArchive)
+	}
+
+	return nil
+}
+
+func (h header) aead(passphrase []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, h.KDF.Salt, h.KDF.N, h.KDF.R, h.KDF.P, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer securemem.Wipe(key)
+
+	return chacha20poly1305.NewX(key)
+}
+
+// secret is JSON string which is read and written without intermediate Go
+// strings, so it can be wiped. Base32 secrets never need escaping, other
+// secrets fall back to the regular encoding.
+type secret []byte
+
+func (s secret) MarshalJSON() ([]byte, error) {
+	for _, c := range s {
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			return json.Marshal(string(s))
+		}
+	}
+
+	b := make([]byte, 0, len(s)+2)
+	b = append(b, '"')
+	b = append(b, s...)
+
+	return append(b, '"'), nil
+}
+
+func (s *secret) UnmarshalJSON(b []byte) error {
+	if len(b) < 2 || b[0] != '"' || b[len(b)-1] != '"' || bytes.IndexByte(b, '\\') >= 0 {
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+
+		*s = secret(str)
+
+		return nil
+	}
+
+	*s = append(secret(nil), b[1:len(b)-1]...)
+
+	return nil
+}
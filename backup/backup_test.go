@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestMain(m *testing.M) {
+	// keep tests fast, parameters are read from the archive anyway
+	scryptN = 1 << 10
+
+	os.Exit(m.Run())
+}
+
+var testItems = []*vault.Item{
+	{Name: "github", Key: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")},
+	{Name: "bank", Issuer: "Bank", Key: []byte("GE"), Algorithm: "sha256", Digits: 8, Step: 60, T0: 100},
+	{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 42},
+}
+
+func TestWriteRead(t *testing.T) {
+	created := time.Unix(1111111109, 0).UTC()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testItems, []byte("passphrase"), created); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if strings.Contains(buf.String(), "GEZDGNBVGY3TQOJQ") {
+		t.Error("archive contains plaintext secret")
+	}
+
+	items, gotCreated, err := Read(bytes.NewReader(buf.Bytes()), []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if !reflect.DeepEqual(items, testItems) {
+		t.Errorf("wrong items, want: %+v != got: %+v", testItems, items)
+	}
+
+	if !gotCreated.Equal(created) {
+		t.Errorf("wrong creation time, want: %v != got: %v", created, gotCreated)
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testItems, []byte("passphrase"), time.Now()); err != nil {
+		panic(err)
+	}
+
+	archive := buf.String()
+
+	for _, c := range []struct {
+		name       string
+		archive    string
+		passphrase string
+		want       error
+	}{
+		{
+			name:       "wrong passphrase",
+			archive:    archive,
+			passphrase: "wrong",
+			want:       ErrWrongPassphrase,
+		},
+		{
+			name:       "tampered header",
+			archive:    strings.Replace(archive, `"r": 8`, `"r": 9`, 1),
+			passphrase: "passphrase",
+			want:       ErrWrongPassphrase,
+		},
+		{
+			name:       "not json",
+			archive:    "[general]\n",
+			passphrase: "passphrase",
+			want:       ErrInvalidArchive,
+		},
+		{
+			name:       "other format",
+			archive:    strings.Replace(archive, format, "other", 1),
+			passphrase: "passphrase",
+			want:       ErrInvalidArchive,
+		},
+		{
+			name:       "newer version",
+			archive:    strings.Replace(archive, `"version": 1`, `"version": 2`, 1),
+			passphrase: "passphrase",
+			want:       ErrInvalidArchive,
+		},
+		{
+			name:       "huge scrypt cost",
+			archive:    strings.Replace(archive, `"n": 1024`, `"n": 1073741824`, 1),
+			passphrase: "passphrase",
+			want:       ErrInvalidArchive,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := Read(strings.NewReader(c.archive), []byte(c.passphrase))
+			if !errors.Is(err, c.want) {
+				t.Errorf("wrong error, want: %v != got: %v", c.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// Policy tells Restore what to do with an item whose name is already taken
+type Policy string
+
+const (
+	// PolicyFail fails the restore
+	PolicyFail Policy = ""
+	// PolicySkip keeps the existing item
+	PolicySkip Policy = "skip"
+	// PolicyOverwrite replaces the existing item with the restored one
+	PolicyOverwrite Policy = "overwrite"
+	// PolicyRename restores the item under a free name with a numeric suffix
+	PolicyRename Policy = "rename"
+)
+
+// ParsePolicy returns merge policy by its name, empty name means PolicyFail
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyFail, PolicySkip, PolicyOverwrite, PolicyRename:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown merge policy: %s", s)
+	}
+}
+
+// Action is what Restore did with an item
+type Action string
+
+const (
+	Added       Action = "added"
+	Skipped     Action = "skipped"
+	Overwritten Action = "overwritten"
+	Renamed     Action = "renamed"
+)
+
+// Change describes what Restore did with an item, Name is the name the item
+// has in the vault
+type Change struct {
+	Action Action
+	Item   string
+	Name   string
+}
+
+// Restore adds items to the config resolving name conflicts with the policy.
+// On error the config may be partially modified and shouldn't be written.
+func Restore(cfg *vault.Config, items []*vault.Item, policy Policy) ([]Change, error) {
+	changes := make([]Change, 0, len(items))
+
+	for _, i := range items {
+		err := cfg.Add(i)
+		if err == nil {
+			changes = append(changes, Change{Action: Added, Item: i.Name, Name: i.Name})
+			continue
+		}
+
+		if !errors.Is(err, vault.ErrItemAlreadyExists) {
+			return nil, fmt.Errorf("%s: %w", i.Name, err)
+		}
+
+		switch policy {
+		case PolicySkip:
+			changes = append(changes, Change{Action: Skipped, Item: i.Name, Name: i.Name})
+		case PolicyOverwrite:
+			if err := cfg.Update(i); err != nil {
+				return nil, err
+			}
+
+			changes = append(changes, Change{Action: Overwritten, Item: i.Name, Name: i.Name})
+		case PolicyRename:
+			renamed := *i
+			renamed.Name = freeName(cfg, i.Name)
+
+			if err := cfg.Add(&renamed); err != nil {
+				return nil, err
+			}
+
+			changes = append(changes, Change{Action: Renamed, Item: i.Name, Name: renamed.Name})
+		default:
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+func freeName(cfg *vault.Config, name string) string {
+	for n := 1; ; n++ {
+		candidate := name + "-" + strconv.Itoa(n)
+		if cfg.Get(candidate) == nil {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+type memMapper struct {
+	items []*vault.Item
+}
+
+func (m *memMapper) Read() ([]*vault.Item, error) {
+	return m.items, nil
+}
+
+func (m *memMapper) Write(items []*vault.Item) error {
+	return nil
+}
+
+func TestRestore(t *testing.T) {
+	restored := []*vault.Item{
+		{Name: "github", Key: []byte("NEWA")},
+		{Name: "aws", Key: []byte("GE")},
+	}
+
+	for _, c := range []struct {
+		name        string
+		policy      Policy
+		wantChanges []Change
+		wantItems   []*vault.Item
+		wantErr     error
+	}{
+		{
+			name:    "fail",
+			policy:  PolicyFail,
+			wantErr: vault.ErrItemAlreadyExists,
+		},
+		{
+			name:   "skip",
+			policy: PolicySkip,
+			wantChanges: []Change{
+				{Action: Skipped, Item: "github", Name: "github"},
+				{Action: Added, Item: "aws", Name: "aws"},
+			},
+			wantItems: []*vault.Item{
+				{Name: "github", Key: []byte("OLDA")},
+				{Name: "github-1", Key: []byte("GE")},
+				{Name: "aws", Key: []byte("GE")},
+			},
+		},
+		{
+			name:   "overwrite",
+			policy: PolicyOverwrite,
+			wantChanges: []Change{
+				{Action: Overwritten, Item: "github", Name: "github"},
+				{Action: Added, Item: "aws", Name: "aws"},
+			},
+			wantItems: []*vault.Item{
+				{Name: "github", Key: []byte("NEWA")},
+				{Name: "github-1", Key: []byte("GE")},
+				{Name: "aws", Key: []byte("GE")},
+			},
+		},
+		{
+			name:   "rename",
+			policy: PolicyRename,
+			wantChanges: []Change{
+				{Action: Renamed, Item: "github", Name: "github-2"},
+				{Action: Added, Item: "aws", Name: "aws"},
+			},
+			wantItems: []*vault.Item{
+				{Name: "github", Key: []byte("OLDA")},
+				{Name: "github-1", Key: []byte("GE")},
+				{Name: "github-2", Key: []byte("NEWA")},
+				{Name: "aws", Key: []byte("GE")},
+			},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			cfg, err := vault.NewConfigWithMapper(&memMapper{items: []*vault.Item{
+				{Name: "github", Key: []byte("OLDA")},
+				{Name: "github-1", Key: []byte("GE")},
+			}})
+			if err != nil {
+				panic(err)
+			}
+
+			changes, err := Restore(cfg, restored, c.policy)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("wrong error, want: %v != got: %v", c.wantErr, err)
+			}
+
+			if c.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(changes, c.wantChanges) {
+				t.Errorf("wrong changes, want: %+v != got: %+v", c.wantChanges, changes)
+			}
+
+			if !reflect.DeepEqual(cfg.Items, c.wantItems) {
+				t.Errorf("wrong items, want: %+v != got: %+v", c.wantItems, cfg.Items)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, s := range []string{"", "skip", "overwrite", "rename"} {
+		if p, err := ParsePolicy(s); err != nil || string(p) != s {
+			t.Errorf("wrong policy for %q: %v, %v", s, p, err)
+		}
+	}
+
+	if _, err := ParsePolicy("merge"); err == nil {
+		t.Error("error shouldn't be nil")
+	}
+}
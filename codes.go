@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// itemCode returns code for the step of given time shifted by offset steps,
+// the agent is asked if it's running so the vault isn't read at all. Items
+// unknown to the agent, e.g. added after it was started, are read from the
+// vault.
+func itemCode(env *Env, cfg *vault.Config, name string, now time.Time, offset int) (totp.Code, error) {
+	if env.Agent != nil {
+		code, err := env.Agent.Code(name, now, offset)
+		if !errors.Is(err, vault.ErrItemNotFound) || (cfg == nil && env.openVault == nil) {
+			return code, ocraHint(err)
+		}
+
+		if cfg == nil {
+			if cfg, err = env.openVault(); err != nil {
+				return totp.Code{}, err
+			}
+		}
+	}
+
+	t, err := cfg.TOTP(name)
+	if err != nil {
+		return totp.Code{}, ocraHint(err)
+	}
+	defer t.Wipe()
+
+	return t.CodeFor(t.CounterAt(now) + uint64(offset)), nil
+}
+
+// ocraHint points to ocra command if the error is about OCRA item
+func ocraHint(err error) error {
+	if errors.Is(err, vault.ErrOCRAItem) {
+		return fmt.Errorf("%w, use %s command", err, CommandOCRAName)
+	}
+
+	return err
+}
+
+// totpItems returns items with TOTP codes, i.e. all but OCRA ones
+func totpItems(items []*vault.Item) []*vault.Item {
+	res := make([]*vault.Item, 0, len(items))
+	for _, i := range items {
+		if i.Suite == "" {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
+
+// freshCode returns current code of the item. If it expires sooner than
+// minValidity, freshCode either waits for the next step showing a countdown
+// on stderr, or returns the next code right away if wait is false.
+// minValidity should be less than the item's step, no code is valid longer.
+func freshCode(env *Env, cfg *vault.Config, name string, minValidity time.Duration, wait bool) (totp.Code, error) {
+	now := env.Clock.Now()
+
+	code, err := itemCode(env, cfg, name, now, 0)
+	if err != nil {
+		return totp.Code{}, err
+	}
+
+	if step := code.ValidUntil.Sub(code.ValidFrom); minValidity >= step {
+		return totp.Code{}, fmt.Errorf("min-validity %ds should be less than %ds step of %s", seconds(minValidity), seconds(step), name)
+	}
+
+	remaining := code.ValidUntil.Sub(now)
+	if remaining >= minValidity {
+		return code, nil
+	}
+
+	if !wait {
+		return itemCode(env, cfg, name, now, 1)
+	}
+
+	countdown(env, remaining)
+
+	return itemCode(env, cfg, name, code.ValidUntil, 0)
+}
+
+// countdown sleeps for given duration showing seconds left on stderr
+func countdown(env *Env, d time.Duration) {
+	for d > 0 {
+		fmt.Fprintf(env.Stderr, "\rwaiting %ds for the next code", seconds(d))
+
+		tick := d % time.Second
+		if tick == 0 {
+			tick = time.Second
+		}
+
+		env.Sleep(tick)
+		d -= tick
+	}
+
+	fmt.Fprintln(env.Stderr)
+}
+
+func printCode(w io.Writer, code totp.Code, now time.Time, verbose bool) {
+	if !verbose {
+		fmt.Fprintln(w, code.Value)
+		return
+	}
+
+	fmt.Fprintf(w, "%s\t", code.Value)
+	printValidity(w, code, now)
+}
+
+// printNextNotice explains that the code isn't valid yet
+func printNextNotice(w io.Writer, code totp.Code, now time.Time) {
+	fmt.Fprint(w, "current code is about to expire, the next one: ")
+	printValidity(w, code, now)
+}
+
+func printValidity(w io.Writer, code totp.Code, now time.Time) {
+	fmt.Fprintf(w, "step %d, valid from %s until %s",
+		code.Counter, code.ValidFrom.In(now.Location()).Format(time.RFC3339), code.ValidUntil.In(now.Location()).Format(time.RFC3339))
+
+	if now.Before(code.ValidFrom) {
+		fmt.Fprintf(w, ", starts in %ds\n", seconds(code.ValidFrom.Sub(now)))
+	} else {
+		fmt.Fprintf(w, ", %ds remaining\n", seconds(code.ValidUntil.Sub(now)))
+	}
+}
+
+// seconds rounds duration up to whole seconds
+func seconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
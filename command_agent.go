@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mullakhmetov/clotp/agent"
+	"github.com/mullakhmetov/clotp/securemem"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const (
+	CommandAgentName = "agent"
+
+	defaultAgentTimeout = 15 * time.Minute
+	agentStartTimeout   = 2 * time.Second
+
+	// agentStarted is written to the status file descriptor once the agent
+	// listens, anything else written there is the error it failed with
+	agentStarted = "ok"
+)
+
+func NewCommandAgent(env *Env, cfg *vault.Config) *CommandAgent {
+	return &CommandAgent{env, cfg}
+}
+
+// CommandAgent runs the agent, the vault is opened only when the agent is
+// started, so it isn't asked for the password to stop the agent
+type CommandAgent struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandAgent) Execute(args []string) int {
+	agentCommand := c.env.FlagSet(CommandAgentName)
+	timeoutFlag := agentCommand.Duration("timeout", defaultAgentTimeout, "Forget items and exit after being idle for this long, 0 to never")
+	foregroundFlag := agentCommand.Bool("foreground", false, "Don't detach from the terminal")
+	stopFlag := agentCommand.Bool("stop", false, "Stop running agent")
+	statusFDFlag := agentCommand.Int("status-fd", 0, "Read items from stdin and report start to this file descriptor, used by the detached agent")
+
+	if err := agentCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	path := agent.SocketPath()
+
+	switch {
+	case *stopFlag:
+		if err := agent.NewClient(path).Stop(); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+
+		return 0
+	case *statusFDFlag > 0:
+		return c.serveDetached(path, *timeoutFlag, *statusFDFlag)
+	case *foregroundFlag:
+		cfg, err := c.vault()
+		if err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+
+		if err := c.serve(path, *timeoutFlag, cfg, nil); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+
+		return 0
+	default:
+		return c.start(path, *timeoutFlag)
+	}
+}
+
+func (c CommandAgent) vault() (*vault.Config, error) {
+	if c.cfg != nil {
+		return c.cfg, nil
+	}
+
+	return c.env.openVault()
+}
+
+// start runs agent in a background process and prints shell commands to
+// export its socket path. The vault is unlocked here, where the password can
+// be asked, and its items are passed to the agent on its stdin.
+func (c CommandAgent) start(path string, timeout time.Duration) int {
+	cfg, err := c.vault()
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	pid, err := startAgent(path, timeout, cfg.Items)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(c.env.Stdout, "%s=%s; export %s;\n", agent.SocketEnv, path, agent.SocketEnv)
+	fmt.Fprintf(c.env.Stdout, "echo Agent pid %d;\n", pid)
+
+	return 0
+}
+
+func startAgent(path string, timeout time.Duration, items []*vault.Item) (int, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return 0, err
+	}
+	defer securemem.Wipe(data)
+
+	itemsR, itemsW, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer itemsW.Close()
+
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		itemsR.Close()
+		return 0, err
+	}
+	defer statusR.Close()
+
+	// the status pipe is the child's file descriptor 3
+	cmd := exec.Command(self, CommandAgentName, "-timeout", timeout.String(), "-status-fd", "3")
+	cmd.Env = append(os.Environ(), agent.SocketEnv+"="+path)
+	cmd.Stdin = itemsR
+	cmd.ExtraFiles = []*os.File{statusW}
+
+	err = cmd.Start()
+
+	// the child keeps its ends, EOF is read once it closes them
+	itemsR.Close()
+	statusW.Close()
+
+	if err != nil {
+		return 0, err
+	}
+
+	status := make(chan string, 1)
+
+	go func() {
+		b, _ := ioutil.ReadAll(statusR)
+		status <- strings.TrimSpace(string(b))
+	}()
+
+	if _, err := itemsW.Write(data); err != nil {
+		_ = cmd.Process.Kill()
+		return 0, fmt.Errorf("failed to pass items to the agent: %w", err)
+	}
+
+	itemsW.Close()
+
+	select {
+	case s := <-status:
+		switch s {
+		case agentStarted:
+		case "":
+			return 0, fmt.Errorf("agent failed to start: %v", cmd.Wait())
+		default:
+			_ = cmd.Wait()
+			return 0, errors.New(s)
+		}
+	case <-time.After(agentStartTimeout):
+		_ = cmd.Process.Kill()
+		return 0, errors.New("agent didn't start in time")
+	}
+
+	pid := cmd.Process.Pid
+
+	return pid, cmd.Process.Release()
+}
+
+// serveDetached serves items read from stdin, start errors are written to
+// the status file descriptor as stdio of the detached agent is discarded
+func (c CommandAgent) serveDetached(path string, timeout time.Duration, statusFD int) int {
+	status := os.NewFile(uintptr(statusFD), "status-fd"+strconv.Itoa(statusFD))
+	if status == nil {
+		fmt.Fprintf(c.env.Stderr, "invalid file descriptor: %d\n", statusFD)
+		return 1
+	}
+
+	reported := false
+	report := func(err error) {
+		if reported {
+			return
+		}
+
+		reported = true
+
+		msg := agentStarted
+		if err != nil {
+			msg = err.Error()
+		}
+
+		fmt.Fprintln(status, msg)
+		status.Close()
+	}
+
+	cfg, err := readAgentItems(c.env.Stdin)
+	if err != nil {
+		report(err)
+		return 1
+	}
+	defer cfg.Wipe()
+
+	if err := c.serve(path, timeout, cfg, report); err != nil {
+		report(err)
+		return 1
+	}
+
+	return 0
+}
+
+// readAgentItems reads items passed by startAgent
+func readAgentItems(r io.Reader) (*vault.Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(data)
+
+	var items []*vault.Item
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid agent items: %w", err)
+	}
+
+	return vault.NewConfigWithMapper(agentItems(items))
+}
+
+// agentItems is read-only mapper of the items the agent serves
+type agentItems []*vault.Item
+
+func (a agentItems) Read() ([]*vault.Item, error) {
+	return a, nil
+}
+
+func (a agentItems) Write([]*vault.Item) error {
+	return errors.New("agent items can't be written")
+}
+
+// serve serves the items until the agent is stopped, started is called with
+// nil once the socket listens, if it isn't nil. Listen errors are returned
+// before it's called.
+func (c CommandAgent) serve(path string, timeout time.Duration, cfg *vault.Config, started func(error)) error {
+	// the agent keeps secrets for hours, keep them out of swap if possible
+	if err := cfg.Lock(); err != nil {
+		fmt.Fprintf(c.env.Stderr, "warning: secrets aren't locked in memory: %v\n", err)
+	}
+
+	s := agent.NewServer(cfg.Items, timeout)
+	if err := s.Listen(path); err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	// keep running after the terminal is closed
+	signal.Ignore(syscall.SIGHUP)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	defer signal.Stop(sig)
+
+	go func() {
+		select {
+		case <-sig:
+			s.Stop()
+		case <-s.Done():
+		}
+	}()
+
+	if started != nil {
+		started(nil)
+	}
+
+	return s.Serve()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/agent"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// testMainEnv makes the test binary run as clotp, so the agent started in
+// background by the tests is the tested code
+const testMainEnv = "CLOTP_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testMainEnv) == "1" {
+		main()
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestCommandAgent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agent")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "agent.sock")
+
+	defer setEnv(agent.SocketEnv, path)()
+	defer setEnv(testMainEnv, "1")()
+
+	items := []*vault.Item{{Name: "github", Key: []byte(testSecret), Digits: 8}}
+
+	t.Run("start", func(t *testing.T) {
+		e := newTestEnv(items)
+
+		code, out := e.run("agent", "-timeout", "1m")
+		if code != 0 {
+			t.Fatalf("agent isn't started: %s", out)
+		}
+
+		if !strings.Contains(out, agent.SocketEnv+"="+path+"; export "+agent.SocketEnv+";\necho Agent pid ") {
+			t.Errorf("wrong output: %s", out)
+		}
+
+		// the agent got the items of the unlocked vault
+		c := agent.NewClient(path)
+
+		got, err := c.Code("github", testTime, 0)
+		if err != nil {
+			t.Fatalf("failed to get code: %v", err)
+		}
+
+		if got.Value != "07081804" {
+			t.Errorf("wrong code: %s", got.Value)
+		}
+
+		if code, out := newTestEnv(nil).run("agent", "-stop"); code != 0 {
+			t.Errorf("agent isn't stopped: %s", out)
+		}
+	})
+
+	t.Run("start error", func(t *testing.T) {
+		// the socket directory can't be created under a file
+		file := filepath.Join(dir, "file")
+		if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+			panic(err)
+		}
+
+		defer setEnv(agent.SocketEnv, filepath.Join(file, "agent.sock"))()
+
+		e := newTestEnv(items)
+
+		code, _ := e.run("agent")
+		if code != 1 || !strings.Contains(e.stderr.String(), "failed to create socket directory") {
+			t.Errorf("start error isn't reported: %d %s", code, e.stderr.String())
+		}
+	})
+
+	t.Run("foreground", func(t *testing.T) {
+		// the started agent may be removing its socket yet
+		path := filepath.Join(dir, "foreground.sock")
+		defer setEnv(agent.SocketEnv, path)()
+
+		done := make(chan int, 1)
+
+		go func() {
+			code, _ := newTestEnv(items).run("agent", "-foreground", "-timeout", "0")
+			done <- code
+		}()
+
+		c := agent.NewClient(path)
+		for deadline := time.Now().Add(agentStartTimeout); c.Ping() != nil; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("agent isn't started")
+			}
+		}
+
+		if code, out := newTestEnv(nil).run("agent", "-stop"); code != 0 {
+			t.Errorf("agent isn't stopped: %s", out)
+		}
+
+		if code := <-done; code != 0 {
+			t.Errorf("wrong exit code: %d", code)
+		}
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		code, _ := newTestEnv(nil).run("agent", "-stop")
+		if code != 1 {
+			t.Errorf("stopped agent is stopped: %d", code)
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		e := newTestEnv(nil)
+		_, out := e.run("agent", "-timeout", "forever")
+		assertGolden(t, out)
+	})
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandExportRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clotp")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "backup.clotp")
+
+	exported := []*vault.Item{
+		{Name: "github", Key: []byte(testSecret)},
+		{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 7},
+	}
+
+	e := newTestEnv(exported, "secret", "secret")
+	if code, out := e.run("export", "-encrypt", "-out", path); code != 0 {
+		t.Fatalf("export failed:\n%s", out)
+	}
+
+	// items of backups aren't validated until they are added
+	invalidPath := filepath.Join(dir, "invalid.clotp")
+
+	e = newTestEnv([]*vault.Item{{Name: "broken", Key: []byte("1")}}, "secret", "secret")
+	if code, out := e.run("export", "-encrypt", "-out", invalidPath); code != 0 {
+		t.Fatalf("export failed:\n%s", out)
+	}
+
+	for _, c := range []struct {
+		name    string
+		items   []*vault.Item
+		args    []string
+		answers []interface{}
+		want    []*vault.Item
+	}{
+		{
+			name:    "empty vault",
+			args:    []string{"restore", path},
+			answers: []interface{}{"secret"},
+			want:    exported,
+		},
+		{
+			name:    "conflict",
+			items:   []*vault.Item{{Name: "github", Key: []byte("GE")}},
+			args:    []string{"restore", path},
+			answers: []interface{}{"secret"},
+		},
+		{
+			name:    "merge skip",
+			items:   []*vault.Item{{Name: "github", Key: []byte("GE")}},
+			args:    []string{"restore", path, "-merge", "skip"},
+			answers: []interface{}{"secret"},
+			want:    []*vault.Item{{Name: "github", Key: []byte("GE")}, exported[1]},
+		},
+		{
+			name:    "merge overwrite",
+			items:   []*vault.Item{{Name: "github", Key: []byte("GE")}},
+			args:    []string{"restore", "-merge", "overwrite", path},
+			answers: []interface{}{"secret"},
+			want:    exported,
+		},
+		{
+			name:    "merge rename",
+			items:   []*vault.Item{{Name: "github", Key: []byte("GE")}},
+			args:    []string{"restore", "-merge", "rename", path},
+			answers: []interface{}{"secret"},
+			want: []*vault.Item{
+				{Name: "github", Key: []byte("GE")},
+				{Name: "github-1", Key: []byte(testSecret)},
+				exported[1],
+			},
+		},
+		{
+			name:    "unknown merge policy",
+			args:    []string{"restore", "-merge", "union", path},
+			answers: []interface{}{"secret"},
+		},
+		{
+			name:    "wrong passphrase",
+			args:    []string{"restore", path},
+			answers: []interface{}{"wrong"},
+		},
+		{
+			name:    "invalid item",
+			args:    []string{"restore", invalidPath},
+			answers: []interface{}{"secret"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv(c.items, c.answers...)
+			_, out := e.run(c.args...)
+			assertGolden(t, out)
+
+			if !reflect.DeepEqual(e.mapper.written, c.want) {
+				t.Errorf("wrong written items, want: %+v != got: %+v", c.want, e.mapper.written)
+			}
+		})
+	}
+}
+
+func TestCommandExport(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		args    []string
+		answers []interface{}
+	}{
+		{
+			name: "no encrypt",
+			args: []string{"export", "-out", "backup.clotp"},
+		},
+		{
+			name: "no out",
+			args: []string{"export", "-encrypt"},
+		},
+		{
+			name:    "passphrase mismatch",
+			args:    []string{"export", "-encrypt", "-out", filepath.Join(os.TempDir(), "clotp-never-written")},
+			answers: []interface{}{"secret", "secreT"},
+		},
+		{
+			name: "format aegis",
+			args: []string{"export", "-format", "aegis"},
+		},
+		{
+			name: "format andotp",
+			args: []string{"export", "-format", "andotp"},
+		},
+		{
+			name: "format uri-list",
+			args: []string{"export", "-format", "uri-list"},
+		},
+		{
+			name: "format bitwarden",
+			args: []string{"export", "-format", "bitwarden"},
+		},
+		{
+			name: "format csv",
+			args: []string{"export", "-format", "csv"},
+		},
+		{
+			name: "format encrypt",
+			args: []string{"export", "-format", "csv", "-encrypt"},
+		},
+		{
+			name: "unknown format",
+			args: []string{"export", "-format", "keepass"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv([]*vault.Item{
+				{Name: "github", Key: []byte(testSecret)},
+				{Name: "bank", Issuer: "Bank", Key: []byte("GE"), Algorithm: "sha256", Digits: 8, T0: 100},
+				{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08"},
+			}, c.answers...)
+			_, out := e.run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mullakhmetov/clotp/sntp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandDoctorName = "doctor"
+
+func NewCommandDoctor(env *Env, cfg *vault.Config) *CommandDoctor {
+	return &CommandDoctor{env, cfg}
+}
+
+type CommandDoctor struct {
+	env *Env
+	cfg *vault.Config
+}
+
+// Execute measures clock offset with NTP servers and warns about items the
+// offset breaks. The offset is measured against env clock, so time offset
+// which is already applied is taken into account.
+func (c CommandDoctor) Execute(args []string) int {
+	doctorCommand := c.env.FlagSet(CommandDoctorName)
+	timeoutFlag := doctorCommand.Duration("timeout", sntp.DefaultTimeout, "How long to wait for every NTP server")
+
+	servers, err := parseInterspersed(doctorCommand, args)
+	if err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if len(servers) == 0 {
+		servers = []string{sntp.DefaultServer}
+	}
+
+	offsets := make([]time.Duration, 0, len(servers))
+
+	for _, s := range servers {
+		resp, err := sntp.Query(s, sntp.Opts{Timeout: *timeoutFlag, Clock: c.env.Clock})
+		if err != nil {
+			fmt.Fprintf(c.env.Stderr, "%s: %v\n", s, err)
+			continue
+		}
+
+		fmt.Fprintf(c.env.Stdout, "%s: offset %s, delay %s, stratum %d\n",
+			s, formatOffset(resp.Offset), resp.Delay.Round(time.Millisecond), resp.Stratum)
+
+		offsets = append(offsets, resp.Offset)
+	}
+
+	if len(offsets) == 0 {
+		fmt.Fprintln(c.env.Stderr, "no NTP server responded, clock offset is unknown")
+		return 1
+	}
+
+	// median is robust to a single falseticker
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	offset := offsets[len(offsets)/2]
+
+	fmt.Fprintf(c.env.Stdout, "clock offset: %s\n", formatOffset(offset))
+
+	if !c.checkItems(offset) {
+		fmt.Fprintf(c.env.Stdout, "run clotp with --time-offset %s or set %s=%s to correct codes\n",
+			offset.Round(time.Millisecond), timeOffsetEnv, offset.Round(time.Millisecond))
+		return 1
+	}
+
+	fmt.Fprintln(c.env.Stdout, "clock is accurate enough for all items")
+
+	return 0
+}
+
+// checkItems warns about items which codes are likely rejected with the
+// offset, it's fine while the offset is less than half of the step
+func (c CommandDoctor) checkItems(offset time.Duration) bool {
+	if offset < 0 {
+		offset = -offset
+	}
+
+	byStep := make(map[int][]string)
+	for _, i := range c.cfg.Items {
+		// OCRA items are counter or challenge based
+		if i.Suite != "" {
+			continue
+		}
+
+		step := i.TimeStep()
+		byStep[step] = append(byStep[step], i.Name)
+	}
+
+	steps := make([]int, 0, len(byStep))
+	for step := range byStep {
+		steps = append(steps, step)
+	}
+
+	sort.Ints(steps)
+
+	ok := true
+
+	for _, step := range steps {
+		d := time.Duration(step) * time.Second
+		names := strings.Join(byStep[step], ", ")
+
+		switch {
+		case offset >= d:
+			fmt.Fprintf(c.env.Stdout, "warning: offset exceeds %ds step, codes are rejected: %s\n", step, names)
+		case offset >= d/2:
+			fmt.Fprintf(c.env.Stdout, "warning: offset approaches %ds step, codes may be rejected: %s\n", step, names)
+		default:
+			continue
+		}
+
+		ok = false
+	}
+
+	return ok
+}
+
+// formatOffset formats offset in seconds with a sign
+func formatOffset(d time.Duration) string {
+	return fmt.Sprintf("%+.3fs", d.Seconds())
+}
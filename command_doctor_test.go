@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/sntp"
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// startSNTP starts fake SNTP server which clock is ahead of test time by
+// offset, it returns the server connection to close
+func startSNTP(offset time.Duration, stratum uint8) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	r := sntp.NewResponder(totp.ClockFunc(func() time.Time { return testTime.Add(offset) }))
+	r.Stratum = stratum
+
+	go func() { _ = r.Serve(conn) }()
+
+	return conn
+}
+
+func TestCommandDoctor(t *testing.T) {
+	items := []*vault.Item{
+		{Name: "default", Key: []byte(testSecret)},
+		{Name: "slow", Key: []byte(testSecret), Step: 60},
+		{Name: "ocra", Key: []byte(testSecret), Suite: "OCRA-1:HOTP-SHA1-6:QN08"},
+	}
+
+	for _, c := range []struct {
+		name    string
+		offsets []time.Duration
+		args    []string
+	}{
+		{name: "in sync", offsets: []time.Duration{2 * time.Second}},
+		{name: "approaches step", offsets: []time.Duration{-20 * time.Second}},
+		{name: "exceeds step", offsets: []time.Duration{42 * time.Second}},
+		{name: "median", offsets: []time.Duration{42 * time.Second, time.Second, 2 * time.Second}},
+		{name: "time offset applied", offsets: []time.Duration{42 * time.Second}, args: []string{"--time-offset", "42s"}},
+		{name: "kiss of death", offsets: []time.Duration{-1, time.Second}},
+		{name: "no server responded", offsets: []time.Duration{-1}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			args := append(c.args, "doctor")
+			names := make([]string, 0, len(c.offsets))
+
+			for n, offset := range c.offsets {
+				// -1 is kiss-of-death server
+				var conn net.PacketConn
+				if offset == -1 {
+					conn = startSNTP(0, 0)
+				} else {
+					conn = startSNTP(offset, 1)
+				}
+				defer conn.Close()
+
+				addr := conn.LocalAddr().String()
+				args = append(args, addr)
+				names = append(names, addr, "ntp"+string(rune('1'+n)))
+			}
+
+			_, out := newTestEnv(items).run(args...)
+			assertGolden(t, strings.NewReplacer(names...).Replace(out))
+		})
+	}
+}
+
+func TestTimeOffset(t *testing.T) {
+	items := []*vault.Item{{Name: "default", Key: []byte(testSecret)}}
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{name: "ahead", args: []string{"--time-offset", "30s", "get", "default", "-verbose"}},
+		{name: "behind", args: []string{"-time-offset=-30s", "get", "default", "-verbose"}},
+		{name: "invalid", args: []string{"--time-offset", "soon", "get", "default"}},
+		{name: "help", args: []string{"-h"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newTestEnv(items).run(c.args...)
+			if c.name == "help" {
+				if !strings.Contains(out, "usage: clotp") {
+					t.Errorf("help expected, got: %s", out)
+				}
+
+				return
+			}
+
+			assertGolden(t, out)
+		})
+	}
+}
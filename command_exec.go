@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const (
+	CommandExecName = "exec"
+
+	otpPlaceholder = "{{otp}}"
+)
+
+func NewCommandExec(env *Env, cfg *vault.Config) *CommandExec {
+	return &CommandExec{env, cfg}
+}
+
+type CommandExec struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandExec) Execute(args []string) int {
+	execCommand := c.env.FlagSet(CommandExecName)
+	envFlag := execCommand.String("env", "", "Environment variable to pass the code in")
+	minValidityFlag := execCommand.Int("min-validity", 0, "Wait for the next code if the current one expires in fewer seconds")
+
+	args, err := parseInterspersed(execCommand, args)
+	if err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if len(args) < 2 {
+		fmt.Fprintln(c.env.Stderr, "usage: clotp exec <name> [-env VAR] [-min-validity <seconds>] -- <cmd> [args...]")
+		return 1
+	}
+
+	name, cmdArgs := args[0], args[1:]
+
+	if *envFlag == "" && !hasPlaceholder(cmdArgs) {
+		fmt.Fprintf(c.env.Stderr, "nowhere to pass the code, use -env flag or %s in arguments\n", otpPlaceholder)
+		return 1
+	}
+
+	code, err := freshCode(c.env, c.cfg, name, time.Duration(*minValidityFlag)*time.Second, true)
+	if errors.Is(err, vault.ErrItemNotFound) {
+		fmt.Fprintf(c.env.Stderr, "unknown TOTP name: %s\n", name)
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.codeUsed(name, CommandExecName)
+
+	for i, a := range cmdArgs {
+		cmdArgs[i] = strings.Replace(a, otpPlaceholder, code.Value, -1)
+	}
+
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	cmd.Stdin = c.env.Stdin
+	cmd.Stdout = c.env.Stdout
+	cmd.Stderr = c.env.Stderr
+
+	cmd.Env = os.Environ()
+	if *envFlag != "" {
+		cmd.Env = append(cmd.Env, *envFlag+"="+code.Value)
+	}
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func hasPlaceholder(args []string) bool {
+	for _, a := range args {
+		if strings.Contains(a, otpPlaceholder) {
+			return true
+		}
+	}
+
+	return false
+}
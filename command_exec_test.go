@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandExec(t *testing.T) {
+	items := []*vault.Item{{Name: "rfc6238", Key: []byte(testSecret), Digits: 8}}
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "env",
+			args: []string{"exec", "rfc6238", "-env", "TOKEN", "--", "sh", "-c", "echo token is $TOKEN"},
+		},
+		{
+			name: "placeholder",
+			args: []string{"exec", "rfc6238", "--", "echo", "--token-code", "{{otp}}", "code={{otp}}"},
+		},
+		{
+			name: "min validity",
+			args: []string{"exec", "-min-validity", "5", "rfc6238", "--", "echo", "{{otp}}"},
+		},
+		{
+			name: "enough validity",
+			args: []string{"exec", "-min-validity", "1", "rfc6238", "--", "echo", "{{otp}}"},
+		},
+		{
+			name: "exit code",
+			args: []string{"exec", "rfc6238", "--", "sh", "-c", "exit 3 # {{otp}}"},
+		},
+		{
+			name: "no placeholder",
+			args: []string{"exec", "rfc6238", "--", "echo"},
+		},
+		{
+			name: "no command",
+			args: []string{"exec", "rfc6238"},
+		},
+		{
+			name: "unknown name",
+			args: []string{"exec", "unknown", "--", "echo", "{{otp}}"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newTestEnv(items).run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/backup"
+	"github.com/mullakhmetov/clotp/export"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandExportName = "export"
+
+func NewCommandExport(env *Env, cfg *vault.Config) *CommandExport {
+	return &CommandExport{env, cfg}
+}
+
+type CommandExport struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandExport) Execute(args []string) int {
+	exportCommand := c.env.FlagSet(CommandExportName)
+	encryptFlag := exportCommand.Bool("encrypt", false, "Encrypt the archive with a passphrase")
+	outFlag := exportCommand.String("out", "", "File to write the archive to, stdout for -format if empty")
+	formatFlag := exportCommand.String("format", "",
+		"Write unencrypted export for other authenticators: "+strings.Join(export.Formats, ", "))
+
+	if err := exportCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if *formatFlag != "" {
+		return c.exportFormat(*formatFlag, *encryptFlag, *outFlag)
+	}
+
+	if !*encryptFlag {
+		fmt.Fprintln(c.env.Stderr, "export contains secrets, -encrypt is required")
+		return 1
+	}
+
+	if *outFlag == "" {
+		fmt.Fprintln(c.env.Stderr, "-out is required")
+		return 1
+	}
+
+	passphrase, err := askPassphrase(c.env, "Enter backup passphrase", true)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	f, err := os.OpenFile(*outFlag, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if err := backup.Write(f, c.cfg.Items, passphrase, c.env.Clock.Now()); err != nil {
+		f.Close()
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if err := f.Close(); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.record(audit.ActionExport, "", "backup")
+
+	fmt.Fprintf(c.env.Stdout, "%d items were exported to %s\n", len(c.cfg.Items), *outFlag)
+
+	return 0
+}
+
+func (c CommandExport) exportFormat(name string, encrypt bool, out string) int {
+	format, err := export.ParseFormat(name)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if encrypt {
+		fmt.Fprintf(c.env.Stderr, "%s export can't be encrypted, omit -encrypt\n", format)
+		return 1
+	}
+
+	var (
+		w io.Writer = c.env.Stdout
+		f *os.File
+	)
+
+	if out != "" {
+		if f, err = os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+
+		w = f
+	}
+
+	warnings, err := export.Write(w, format, c.cfg.Items)
+
+	for _, warning := range warnings {
+		fmt.Fprintf(c.env.Stderr, "warning: %s\n", warning)
+	}
+
+	if f != nil {
+		// the export isn't complete if it fails to be flushed
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.record(audit.ActionExport, "", string(format))
+
+	fmt.Fprintln(c.env.Stderr, "the export contains unencrypted secrets, keep it safe and delete it after import")
+
+	return 0
+}
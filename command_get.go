@@ -1,45 +1,74 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
 )
 
 const CommandGetName = "get"
 
-func NewCommandGet(cfg *Config) *CommandGet {
-	return &CommandGet{cfg}
+func NewCommandGet(env *Env, cfg *vault.Config) *CommandGet {
+	return &CommandGet{env, cfg}
 }
 
 type CommandGet struct {
-	cfg *Config
+	env *Env
+	cfg *vault.Config
 }
 
 func (c CommandGet) Help() {
-	fmt.Println("")
+	fmt.Fprintln(c.env.Stdout, "")
 }
 
 func (c CommandGet) Execute(args []string) int {
+	getCommand := c.env.FlagSet(CommandGetName)
+	verboseFlag := getCommand.Bool("verbose", false, "Show time step and validity interval of the code")
+	nextFlag := getCommand.Bool("next", false, "Show the code of the next time step instead of the current one")
+	minValidityFlag := getCommand.Int("min-validity", 0, "Wait for the next code if the current one expires in fewer seconds")
+	noWaitFlag := getCommand.Bool("no-wait", false, "Show the next code and its validity instead of waiting for it")
+
+	args, err := parseInterspersed(getCommand, args)
+	if err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
 	if len(args) != 1 {
-		fmt.Printf("invalid TOTP name input: %s\n", args)
+		fmt.Fprintf(c.env.Stderr, "invalid TOTP name input: %s\n", args)
 		return 1
 	}
 
 	name := args[0]
 
-	var item *Item
-	for _, i := range c.cfg.Items {
-		if i.Name == name {
-			item = i
-			break
-		}
+	var code totp.Code
+	if *nextFlag {
+		code, err = itemCode(c.env, c.cfg, name, c.env.Clock.Now(), 1)
+	} else {
+		code, err = freshCode(c.env, c.cfg, name, time.Duration(*minValidityFlag)*time.Second, !*noWaitFlag)
 	}
 
-	if item != nil {
-		code := item.TOTP().Now()
-		fmt.Println(code)
-		return 0
+	if errors.Is(err, vault.ErrItemNotFound) {
+		fmt.Fprintf(c.env.Stderr, "unknown TOTP name: %s\n", name)
+		return 1
 	}
 
-	fmt.Printf("unknown TOTP name: %s\n", name)
-	return 1
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.codeUsed(name, CommandGetName)
+
+	now := c.env.Clock.Now()
+	if !*verboseFlag && !*nextFlag && now.Before(code.ValidFrom) {
+		printNextNotice(c.env.Stderr, code, now)
+	}
+
+	printCode(c.env.Stdout, code, now, *verboseFlag)
+
+	return 0
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/agent"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandGet(t *testing.T) {
+	items := []*vault.Item{
+		{Name: "default", Key: []byte(testSecret)},
+		{Name: "sha1-8", Key: []byte(testSecret), Digits: 8},
+		{Name: "sha256", Key: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"), Algorithm: "sha256", Digits: 8},
+		{Name: "ocra", Key: []byte(testSecret), Suite: "OCRA-1:HOTP-SHA1-6:QN08"},
+	}
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{name: "default", args: []string{"get", "default"}},
+		{name: "rfc6238 sha1", args: []string{"get", "sha1-8"}},
+		{name: "rfc6238 sha256", args: []string{"get", "sha256"}},
+		{name: "verbose", args: []string{"get", "default", "-verbose"}},
+		{name: "next", args: []string{"get", "-next", "default"}},
+		{name: "next verbose", args: []string{"get", "-next", "-verbose", "default"}},
+		{name: "min validity", args: []string{"get", "default", "-min-validity", "5"}},
+		{name: "min validity verbose", args: []string{"get", "default", "-min-validity", "5", "-verbose"}},
+		{name: "min validity no wait", args: []string{"get", "default", "-min-validity", "5", "-no-wait"}},
+		{name: "enough validity", args: []string{"get", "default", "-min-validity", "1"}},
+		{name: "min validity exceeds step", args: []string{"get", "default", "-min-validity", "30"}},
+		{name: "unknown name", args: []string{"get", "unknown"}},
+		{name: "ocra item", args: []string{"get", "ocra"}},
+		{name: "no name", args: []string{"get"}},
+		{name: "unknown flag", args: []string{"get", "default", "-unknown"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newTestEnv(items).run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
+
+func TestCommandGet_Agent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "agent")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "agent.sock")
+
+	s := agent.NewServer([]*vault.Item{{Name: "default", Key: []byte(testSecret)}}, 0)
+	if err := s.Listen(path); err != nil {
+		panic(err)
+	}
+	defer s.Stop()
+
+	go func() {
+		if err := s.Serve(); err != nil {
+			panic(err)
+		}
+	}()
+
+	for _, c := range []struct {
+		name  string
+		args  []string
+		opens int
+	}{
+		{name: "verbose", args: []string{"get", "default", "-verbose"}},
+		{name: "next", args: []string{"get", "-next", "default"}},
+		{name: "unknown name", args: []string{"get", "unknown"}, opens: 1},
+		{name: "added after start", args: []string{"get", "added"}, opens: 1},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv([]*vault.Item{{Name: "added", Key: []byte(testSecret)}})
+			e.Agent = agent.NewClient(path)
+
+			// items unknown to the agent are read from the vault
+			opens := 0
+			code := run(e.Env, func() (*vault.Config, error) {
+				opens++
+				return e.cfg, nil
+			}, c.args)
+
+			if opens != c.opens {
+				t.Errorf("vault is opened %d times, want: %d", opens, c.opens)
+			}
+
+			// the same output as without agent
+			assertGolden(t, e.output(code))
+		})
+	}
+}
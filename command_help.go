@@ -1,27 +1,81 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
 
-var help = `usage: clotp <command> [<args>]
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+var help = `usage: clotp [--time-offset <duration>] <command> [<args>]
 
 There are clotp commands:
-list - get available TOTP's
-new - create new TOPT
-get - get particular TOTP code by it's name
+list - get available TOTP's, list -sort recent|frequent|name|issuer orders them, recently used first by default
+new - create new TOPT, new [-name <name>] [-secret-fd <n>|-secret-file <file>|-secret-env <var>|-secret-command <cmd>]
+  reads the secret key from the first line of a file descriptor, a file, a variable or a command output
+get - get particular TOTP code by it's name, -verbose shows its validity, -next shows the next code
+  get and list wait for the next code if the current one expires in less than -min-validity seconds,
+  -no-wait shows the next code with its validity instead
+pick - choose item in an external menu and print its code: pick [-menu dmenu|rofi|fzf|<cmd>] [-copy] [-sort <order>]
+  -copy copies the code to the clipboard with wl-copy, xclip, xsel, pbcopy or -clipboard <cmd>
+provision - create new TOTP with generated secret, show its QR code and confirm it: provision <name>
+secret generate - generate new random secret: secret generate [-bytes <n>] [-algorithm <name>]
+ocra - get OCRA response for the given challenge: ocra <name> -challenge <q>
+exec - run command with the code in environment variable or in place of {{otp}} in its arguments:
+  exec <name> [-env VAR] [-min-validity <seconds>] -- <cmd> [args...]
+agent - keep items in memory and serve codes to get command, eval $(clotp agent) to start, agent -stop to stop
+serve - serve HTTP/JSON API for local tools: serve [-listen unix:<path>|127.0.0.1:<port>] [-tokens <file>]
+  tokens file lines are "<token> <item>,<item>" or "<token> *" for all items,
+  verification accepts -window steps around the current one, rejects used codes and locks the user out
+  for -lockout after -max-failures failures, the state is kept in -verify-state file
+export - write passphrase-protected backup of all items: export -encrypt -out <file>
+  or unencrypted export for other authenticators: export -format aegis|andotp|uri-list|bitwarden|csv [-out <file>]
+restore - restore items from backup: restore [-merge skip|overwrite|rename] <file>
+  existing items are kept, overwritten or restored under a new name, restore fails on conflicts without -merge
 
+doctor - measure clock offset with NTP servers and warn about items it breaks: doctor [-timeout <duration>] [<server>...]
+  pool.ntp.org is queried by default, the offset is corrected by --time-offset <duration> option preceding
+  any command, e.g. clotp --time-offset 42s get <name>, or by CLOTP_TIME_OFFSET environment variable
+log - show audit log of added, edited and removed items, generated codes, imports and exports:
+  log [-since <duration>|<time>|<date>] [-item <name>], the log is hash-chained so tampering is reported
+history - show commits of the vault if ~/.config/clotp is a git repository, every change of items is committed,
+  the shared vault is kept in the repository of its directory, CLOTP_GIT sets git binary
+revert - commit undoing the changes of the vault commit: revert <rev>
+sync - rebase local vault commits onto the upstream and push them, changes of different items are merged,
+  nothing is synced if an item is changed differently on both sides
+merge - merge vault files by items, HOTP counters take the greater value: merge <base> <ours> <theirs>
+  the result is written to ours file, conflicting edits are reported and ours versions are kept,
+  use as git merge driver: git config merge.clotp.driver "clotp merge %O %A %B" and "config.ini merge=clotp"
+  line in .gitattributes, shared vaults are merged if both sides have the same recipients
+vault recipients - list recipients of the shared vault: vault recipients [add|remove <key>|<public key file>]
+  keys are age1... X25519 recipients or ssh-ed25519 public keys, removing a recipient rotates the data key
 help - show this help
+
+Items are kept in ~/.config/clotp/config.ini. CLOTP_BACKEND=pass keeps them as otpauth URIs in pass(1) store
+instead, $PASSWORD_STORE_DIR is respected, items are kept in its otp/ subdirectory, CLOTP_PASS_PREFIX sets
+another one or "." for the whole store, and CLOTP_GPG sets gpg binary. CLOTP_BACKEND=keepass uses TOTP entries of KeePass KDBX 4 database
+CLOTP_KEEPASS_DB, the password is asked, CLOTP_KEEPASS_KEYFILE sets the database key file.
+CLOTP_BACKEND=shared keeps them in ~/.config/clotp/shared.vault or CLOTP_SHARED_VAULT encrypted to every recipient,
+any of them opens it with CLOTP_IDENTITY age identity file or SSH ed25519 private key, ~/.ssh/id_ed25519 by default.
+The database password and the identity passphrase are read without a terminal from file descriptor CLOTP_PASSPHRASE_FD,
+file CLOTP_PASSPHRASE_FILE, CLOTP_PASSPHRASE variable or output of CLOTP_PASSPHRASE_COMMAND, e.g. pass show clotp.
+Variables may be set in ~/.config/clotp/settings.ini, e.g. passphrase_command = "pass show clotp" sets the last one.
+Events are logged to ~/.config/clotp/audit.log or CLOTP_AUDIT_LOG, codes and secrets are never logged.
+CLOTP_AUDIT=plain turns off hash chaining, CLOTP_AUDIT=off turns off the log. Last use time and use count
+of items are kept in ~/.config/clotp/usage.json. CLOTP_MLOCK=1 locks secrets into RAM so they're never
+swapped out, the agent always tries to lock them. Core dumps are disabled, secrets are wiped after use.
 `
 
-func NewHelpCommand(cfg *Config) *CommandHelp {
-	return &CommandHelp{cfg}
+func NewHelpCommand(env *Env, cfg *vault.Config) *CommandHelp {
+	return &CommandHelp{env, cfg}
 }
 
 type CommandHelp struct {
-	cfg *Config
+	env *Env
+	cfg *vault.Config
 }
 
 func (c CommandHelp) Execute(_ []string) int {
-	fmt.Println(help)
+	fmt.Fprintln(c.env.Stdout, help)
 
 	return 0
 }
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestCommandHelp(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{name: "help", args: []string{"help"}},
+		{name: "unknown command", args: []string{"unknown"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newTestEnv(nil).run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
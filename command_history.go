@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandHistoryName = "history"
+
+func NewCommandHistory(env *Env, cfg *vault.Config) *CommandHistory {
+	return &CommandHistory{env, cfg}
+}
+
+type CommandHistory struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandHistory) Execute(args []string) int {
+	historyCommand := c.env.FlagSet(CommandHistoryName)
+
+	if err := historyCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if !hasHistory(c.env) {
+		return 1
+	}
+
+	commits, err := c.env.History.Log()
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	now := c.env.Clock.Now()
+
+	for _, commit := range commits {
+		fmt.Fprintf(c.env.Stdout, "%s\t%s\t%s\t%s\n", commit.Hash, commit.Time.In(now.Location()).Format(time.RFC3339), commit.Author, commit.Subject)
+	}
+
+	return 0
+}
+
+// hasHistory reports whether the vault is kept in a git repository, the
+// user is told how to set it up if it isn't
+func hasHistory(env *Env) bool {
+	if env.History != nil {
+		return true
+	}
+
+	fmt.Fprintln(env.Stderr, "vault history isn't kept, run git init in the vault directory to keep it")
+
+	return false
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/envelope"
+	"github.com/mullakhmetov/clotp/history"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// gitTestEnv makes commits reproducible, so their hashes may be in golden files
+var gitTestEnv = []string{
+	"GIT_AUTHOR_NAME=Alice",
+	"GIT_AUTHOR_EMAIL=alice@example.com",
+	"GIT_AUTHOR_DATE=1111111109 +0000",
+	"GIT_COMMITTER_NAME=Alice",
+	"GIT_COMMITTER_EMAIL=alice@example.com",
+	"GIT_COMMITTER_DATE=1111111109 +0000",
+}
+
+// setGitTestEnv sets gitTestEnv variables and returns function restoring them
+func setGitTestEnv(t *testing.T) func() {
+	if _, err := exec.LookPath(history.DefaultGit); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	var restore []func()
+
+	for _, kv := range gitTestEnv {
+		kv := strings.SplitN(kv, "=", 2)
+
+		if prev, ok := os.LookupEnv(kv[0]); ok {
+			restore = append(restore, func() { os.Setenv(kv[0], prev) })
+		} else {
+			restore = append(restore, func() { os.Unsetenv(kv[0]) })
+		}
+
+		os.Setenv(kv[0], kv[1])
+	}
+
+	return func() {
+		for _, r := range restore {
+			r()
+		}
+	}
+}
+
+// newGitEnv returns test environment of the ini vault kept in git repository dir
+func newGitEnv(dir string, answers ...interface{}) *testEnv {
+	e := newTestEnv(nil, answers...)
+	e.History = history.Open(dir, vault.DefaultConfigName, history.Opts{})
+
+	cfg, err := vault.NewConfig(vault.Opts{Path: dir})
+	if err != nil {
+		panic(err)
+	}
+
+	e.cfg = cfg
+
+	return e
+}
+
+func gitCommand(dir string, args ...string) string {
+	cmd := exec.Command(history.DefaultGit, args...)
+	cmd.Dir = dir
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		panic(string(out))
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+func TestCommandHistory(t *testing.T) {
+	defer setGitTestEnv(t)()
+
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	remote := filepath.Join(dir, "remote.git")
+	gitCommand(dir, "init", "--quiet", "--bare", remote)
+
+	alice, bob := filepath.Join(dir, "alice"), filepath.Join(dir, "bob")
+	for _, clone := range []string{alice, bob} {
+		gitCommand(dir, "clone", "--quiet", remote, clone)
+	}
+
+	auditPath := filepath.Join(dir, audit.DefaultName)
+
+	for _, step := range []struct {
+		dir     string
+		args    []string
+		answers []interface{}
+	}{
+		{alice, []string{"new"}, []interface{}{"github", testSecret}},
+		{alice, []string{"sync"}, nil},
+		{bob, []string{"sync"}, nil},
+		// both sides change the vault, the changes are merged by items
+		{alice, []string{"new"}, []interface{}{"gitlab", testSecret}},
+		{bob, []string{"new"}, []interface{}{"aws", testSecret}},
+		{alice, []string{"sync"}, nil},
+		{bob, []string{"sync"}, nil},
+		{alice, []string{"sync"}, nil},
+	} {
+		e := newGitEnv(step.dir, step.answers...)
+		if step.dir == alice {
+			e.Audit = audit.Open(auditPath, audit.Opts{Clock: e.Clock})
+		}
+
+		if code, out := e.run(step.args...); code != 0 {
+			t.Fatalf("%s failed: %s", step.args, out)
+		}
+	}
+
+	for _, clone := range []string{alice, bob} {
+		var names []string
+		for _, i := range newGitEnv(clone).cfg.Items {
+			names = append(names, i.Name)
+		}
+
+		if got := strings.Join(names, ","); got != "github,gitlab,aws" {
+			t.Errorf("wrong synced items of %s: %s", filepath.Base(clone), got)
+		}
+	}
+
+	events, err := audit.Open(auditPath, audit.Opts{}).Events()
+	if err != nil {
+		panic(err)
+	}
+
+	if last := events[len(events)-1]; last.Action != audit.ActionAdded || last.Item != "aws" || last.Detail != CommandSyncName {
+		t.Errorf("synced item should be logged, got: %+v", last)
+	}
+
+	addAWS := gitCommand(bob, "log", "--format=%h", "--grep", "Add aws")
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{name: "history", args: []string{"history"}},
+		{name: "revert", args: []string{"revert", addAWS}},
+		{name: "history after revert", args: []string{"history"}},
+		{name: "revert unknown revision", args: []string{"revert", "unknown"}},
+		{name: "revert without revision", args: []string{"revert"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newGitEnv(bob).run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+
+	if item := newGitEnv(bob).cfg.Get("aws"); item != nil {
+		t.Error("reverted item should be removed")
+	}
+
+	for _, args := range [][]string{{"history"}, {"revert", addAWS}, {"sync"}} {
+		if code, out := newTestEnv(nil).run(args...); code != 1 || !strings.Contains(out, "git init") {
+			t.Errorf("%s should fail without repository, got: %s", args, out)
+		}
+	}
+}
+
+func TestCommandHistory_SyncConflict(t *testing.T) {
+	defer setGitTestEnv(t)()
+
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	remote := filepath.Join(dir, "remote.git")
+	gitCommand(dir, "init", "--quiet", "--bare", remote)
+
+	alice, bob := filepath.Join(dir, "alice"), filepath.Join(dir, "bob")
+	for _, clone := range []string{alice, bob} {
+		gitCommand(dir, "clone", "--quiet", remote, clone)
+	}
+
+	if code, out := newGitEnv(alice, "github", testSecret).run("new"); code != 0 {
+		t.Fatalf("new failed: %s", out)
+	}
+
+	for _, clone := range []string{alice, bob} {
+		if code, out := newGitEnv(clone).run("sync"); code != 0 {
+			t.Fatalf("sync failed: %s", out)
+		}
+	}
+
+	// both sides edit the same item differently
+	for clone, digits := range map[string]int{alice: 8, bob: 7} {
+		e := newGitEnv(clone)
+		e.cfg.Get("github").Digits = digits
+
+		if err := e.cfg.Write(); err != nil {
+			panic(err)
+		}
+
+		if err := e.History.Commit("Edit github"); err != nil {
+			panic(err)
+		}
+	}
+
+	if code, out := newGitEnv(alice).run("sync"); code != 0 {
+		t.Fatalf("sync failed: %s", out)
+	}
+
+	_, out := newGitEnv(bob).run("sync")
+	assertGolden(t, out)
+
+	// the rebase is aborted, the local commit isn't pushed
+	if got := gitCommand(bob, "status", "--porcelain", "--branch"); !strings.Contains(got, "[ahead 1, behind 1]") {
+		t.Errorf("local commit should be kept, got status: %s", got)
+	}
+
+	for clone, digits := range map[string]int{alice: 8, bob: 7} {
+		if got := newGitEnv(clone).cfg.Get("github").Digits; got != digits {
+			t.Errorf("wrong digits of %s: %d", filepath.Base(clone), got)
+		}
+	}
+}
+
+// newSharedGitEnv returns test environment of the shared vault kept in git
+// repository dir, it's opened with testAgeIdentity
+func newSharedGitEnv(dir string, answers ...interface{}) *testEnv {
+	e := newTestEnv(nil, answers...)
+	e.History = history.Open(dir, vault.DefaultSharedName, history.Opts{})
+
+	ids, err := envelope.ParseIdentities([]byte(testAgeIdentity), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	cfg, err := vault.NewConfigWithMapper(vault.NewSharedMapper(vault.SharedOpts{
+		Path:       filepath.Join(dir, vault.DefaultSharedName),
+		Identities: ids,
+	}))
+	if err != nil {
+		panic(err)
+	}
+
+	e.cfg = cfg
+
+	return e
+}
+
+func TestCommandHistory_Shared(t *testing.T) {
+	defer setGitTestEnv(t)()
+
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	remote := filepath.Join(dir, "remote.git")
+	gitCommand(dir, "init", "--quiet", "--bare", remote)
+
+	alice, bob := filepath.Join(dir, "alice"), filepath.Join(dir, "bob")
+	for _, clone := range []string{alice, bob} {
+		gitCommand(dir, "clone", "--quiet", remote, clone)
+	}
+
+	identity := filepath.Join(dir, "age.txt")
+	if err := ioutil.WriteFile(identity, []byte(testAgeIdentity+"\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	defer setEnv(backendEnv, "shared")()
+	defer setEnv(sharedVaultEnv, filepath.Join(alice, vault.DefaultSharedName))()
+	defer setEnv(identityEnv, identity)()
+
+	if repo := openHistory(); repo == nil {
+		t.Error("history of the shared vault isn't opened")
+	}
+
+	for _, step := range []struct {
+		dir     string
+		args    []string
+		answers []interface{}
+	}{
+		{alice, []string{"new"}, []interface{}{"github", testSecret}},
+		{alice, []string{"sync"}, nil},
+		{bob, []string{"sync"}, nil},
+		// both sides change the vault, the changes are merged by items
+		{alice, []string{"new"}, []interface{}{"gitlab", testSecret}},
+		{bob, []string{"new"}, []interface{}{"aws", testSecret}},
+		{alice, []string{"sync"}, nil},
+		{bob, []string{"sync"}, nil},
+		{alice, []string{"sync"}, nil},
+	} {
+		if code, out := newSharedGitEnv(step.dir, step.answers...).run(step.args...); code != 0 {
+			t.Fatalf("%s failed: %s", step.args, out)
+		}
+	}
+
+	for _, clone := range []string{alice, bob} {
+		var names []string
+		for _, i := range newSharedGitEnv(clone).cfg.Items {
+			names = append(names, i.Name)
+		}
+
+		if got := strings.Join(names, ","); got != "github,gitlab,aws" {
+			t.Errorf("wrong synced items of %s: %s", filepath.Base(clone), got)
+		}
+	}
+
+	e := newSharedGitEnv(bob)
+	if code, out := e.run("history"); code != 0 || strings.Count(e.stdout.String(), "\n") != 3 {
+		t.Errorf("wrong history: %s", out)
+	}
+
+	// recipient changes aren't merged
+	if code, out := newSharedGitEnv(alice).run("vault", "recipients", "add", testSSHIdentity+".pub"); code != 0 {
+		t.Fatalf("recipient isn't added: %s", out)
+	}
+
+	for _, step := range []struct {
+		dir     string
+		args    []string
+		answers []interface{}
+	}{
+		{alice, []string{"sync"}, nil},
+		{bob, []string{"new"}, []interface{}{"vpn", testSecret}},
+	} {
+		if code, out := newSharedGitEnv(step.dir, step.answers...).run(step.args...); code != 0 {
+			t.Fatalf("%s failed: %s", step.args, out)
+		}
+	}
+
+	e = newSharedGitEnv(bob)
+	if code, _ := e.run("sync"); code != 1 || !strings.Contains(e.stderr.String(), vault.ErrSharedMerge.Error()) {
+		t.Errorf("recipient change isn't reported: %d %s", code, e.stderr.String())
+	}
+
+	if item := newSharedGitEnv(bob).cfg.Get("vpn"); item == nil {
+		t.Error("local item is lost")
+	}
+}
@@ -3,34 +3,52 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/mullakhmetov/clotp/usage"
+	"github.com/mullakhmetov/clotp/vault"
 )
 
 const CommandListName = "list"
 
-func NewCommandList(cfg *Config) *CommandList {
-	return &CommandList{cfg}
+func NewCommandList(env *Env, cfg *vault.Config) *CommandList {
+	return &CommandList{env, cfg}
 }
 
 type CommandList struct {
-	cfg *Config
+	env *Env
+	cfg *vault.Config
 }
 
 func (c CommandList) Help() {
-	fmt.Println("")
+	fmt.Fprintln(c.env.Stdout, "")
 }
 
-func (c CommandList) Execute(_ []string) int {
-	m := make(map[string]*Item)
+func (c CommandList) Execute(args []string) int {
+	listCommand := c.env.FlagSet(CommandListName)
+	minValidityFlag := listCommand.Int("min-validity", 0, "Wait for the next code if the current one expires in fewer seconds")
+	noWaitFlag := listCommand.Bool("no-wait", false, "Show the next code and its validity instead of waiting for it")
+	sortFlag := listCommand.String("sort", string(usage.ByRecent), sortFlagUsage)
+
+	if err := listCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	order, err := usage.ParseOrder(*sortFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
 	options := make([]string, 0, len(c.cfg.Items))
-	for _, i := range c.cfg.Items {
-		m[i.Name] = i
+	for _, i := range sortedItems(c.env, totpItems(c.cfg.Items), order) {
 		options = append(options, i.Name)
 	}
 
 	if len(options) == 0 {
-		fmt.Println("You have no configured TOTP entities. Run `new` command to create one")
+		fmt.Fprintln(c.env.Stderr, "You have no configured TOTP entities. Run `new` command to create one")
 		return 1
 	}
 
@@ -41,13 +59,24 @@ func (c CommandList) Execute(_ []string) int {
 	}
 
 	var name string
-	if err := survey.AskOne(q, &name, survey.WithValidator(survey.Required)); err != nil {
-		fmt.Println(err)
+	if err := c.env.Prompter.AskOne(q, &name, survey.WithValidator(survey.Required)); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
 		return 1
 	}
 
-	t := m[name].TOTP()
-	fmt.Println(t.Now())
+	code, err := freshCode(c.env, c.cfg, name, time.Duration(*minValidityFlag)*time.Second, !*noWaitFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.codeUsed(name, CommandListName)
+
+	if now := c.env.Clock.Now(); now.Before(code.ValidFrom) {
+		printNextNotice(c.env.Stderr, code, now)
+	}
+
+	fmt.Fprintln(c.env.Stdout, code.Value)
 
 	return 0
 }
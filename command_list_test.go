@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/usage"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandList(t *testing.T) {
+	ocra := &vault.Item{Name: "ocra", Key: []byte(testSecret), Suite: "OCRA-1:HOTP-SHA1-6:QN08"}
+	// OCRA items have no TOTP codes, so they aren't offered
+	items := []*vault.Item{
+		{Name: "first", Key: []byte("GE")},
+		ocra,
+		{Name: "rfc6238", Key: []byte(testSecret), Digits: 8},
+	}
+
+	for _, c := range []struct {
+		name    string
+		items   []*vault.Item
+		args    []string
+		answers []interface{}
+	}{
+		{name: "select", items: items, args: []string{"list"}, answers: []interface{}{"rfc6238"}},
+		{name: "no command", items: items, args: []string{}, answers: []interface{}{"rfc6238"}},
+		{name: "empty", args: []string{"list"}},
+		{name: "ocra only", items: []*vault.Item{ocra}, args: []string{"list"}},
+		{name: "min validity", items: items, args: []string{"list", "-min-validity", "5"}, answers: []interface{}{"rfc6238"}},
+		{
+			name:    "min validity no wait",
+			items:   items,
+			args:    []string{"list", "-min-validity", "5", "-no-wait"},
+			answers: []interface{}{"rfc6238"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newTestEnv(c.items, c.answers...).run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
+
+func TestCommandList_Sort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usage")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	items := []*vault.Item{
+		{Name: "rfc6238", Key: []byte(testSecret), Digits: 8},
+		{Name: "daily", Issuer: "Work", Key: []byte(testSecret)},
+		{Name: "bank", Issuer: "Bank", Key: []byte(testSecret)},
+		{Name: "hourly", Key: []byte(testSecret)},
+	}
+
+	store := usage.Open(filepath.Join(dir, usage.DefaultName))
+
+	// codes are used by get command, the last one is the most recent
+	e := newTestEnv(items)
+	e.Usage = store
+
+	for _, name := range []string{"daily", "daily", "daily", "hourly", "hourly", "bank"} {
+		if code, out := e.run("get", name); code != 0 {
+			t.Fatalf("get failed: %s", out)
+		}
+
+		e.now = e.now.Add(time.Hour)
+	}
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{name: "default", args: []string{"list"}},
+		{name: "name", args: []string{"list", "-sort", "name"}},
+		{name: "recent", args: []string{"list", "-sort", "recent"}},
+		{name: "frequent", args: []string{"list", "-sort", "frequent"}},
+		{name: "issuer", args: []string{"list", "-sort", "issuer"}},
+		{name: "unknown", args: []string{"list", "-sort", "random"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			// every subtest starts with the same statistics, list counts use too
+			data, err := ioutil.ReadFile(filepath.Join(dir, usage.DefaultName))
+			if err != nil {
+				panic(err)
+			}
+
+			path := filepath.Join(dir, c.name+".json")
+			if err := ioutil.WriteFile(path, data, 0600); err != nil {
+				panic(err)
+			}
+
+			e := newTestEnv(items, "rfc6238")
+			e.Usage = usage.Open(path)
+
+			_, out := e.run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
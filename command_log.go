@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandLogName = "log"
+
+func NewCommandLog(env *Env, cfg *vault.Config) *CommandLog {
+	return &CommandLog{env, cfg}
+}
+
+type CommandLog struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandLog) Execute(args []string) int {
+	logCommand := c.env.FlagSet(CommandLogName)
+	sinceFlag := logCommand.String("since", "", "Show events since the duration ago, e.g. 24h, or since RFC 3339 time or YYYY-MM-DD date")
+	itemFlag := logCommand.String("item", "", "Show events of the item only")
+
+	if err := logCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if c.env.Audit == nil {
+		fmt.Fprintf(c.env.Stderr, "audit log is off, unset %s to turn it on\n", auditEnv)
+		return 1
+	}
+
+	now := c.env.Clock.Now()
+
+	var since time.Time
+	if *sinceFlag != "" {
+		var err error
+		if since, err = parseSince(*sinceFlag, now); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+	}
+
+	events, err := c.env.Audit.Events()
+	if err != nil && !errors.Is(err, audit.ErrTampered) {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	for _, e := range events {
+		if e.Time.Before(since) || (*itemFlag != "" && e.Item != *itemFlag) {
+			continue
+		}
+
+		item := e.Item
+		if item == "" {
+			item = "-"
+		}
+
+		fmt.Fprintf(c.env.Stdout, "%s\t%s\t%s\t%s\n", e.Time.In(now.Location()).Format(time.RFC3339), e.Action, item, e.Detail)
+	}
+
+	// events are shown anyway, so it's known what the log claims
+	if err != nil {
+		fmt.Fprintf(c.env.Stderr, "warning: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// parseSince parses duration before now, RFC 3339 time or a date
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid -since: %s, use a duration, RFC 3339 time or YYYY-MM-DD date", s)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, audit.DefaultName)
+	items := []*vault.Item{{Name: "default", Key: []byte(testSecret)}, {Name: "other", Key: []byte(testSecret)}}
+
+	// events of the commands are logged a day apart
+	e := newTestEnv(items)
+	e.now = testTime.Add(-48 * time.Hour)
+	e.Audit = audit.Open(path, audit.Opts{Chain: true, Clock: e.Clock})
+
+	for _, args := range [][]string{
+		{"get", "default"},
+		{"get", "unknown"},
+		{"export", "-format", "uri-list"},
+		{"get", "other"},
+	} {
+		if code, out := e.run(args...); code != 0 && args[1] != "unknown" {
+			t.Fatalf("%s failed: %s", args, out)
+		}
+
+		e.now = e.now.Add(24 * time.Hour)
+	}
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{name: "all", args: []string{"log"}},
+		{name: "item", args: []string{"log", "-item", "default"}},
+		{name: "since duration", args: []string{"log", "-since", "30h"}},
+		{name: "since date", args: []string{"log", "-since", testTime.Format("2006-01-02")}},
+		{name: "invalid since", args: []string{"log", "-since", "yesterday"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv(items)
+			e.Audit = audit.Open(path, audit.Opts{})
+
+			_, out := e.run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+
+	t.Run("tampered", func(t *testing.T) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+
+		// the first event is forged to be of the other item
+		content = []byte(strings.Replace(string(content), `"item":"default"`, `"item":"other"`, 1))
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			panic(err)
+		}
+
+		e := newTestEnv(items)
+		e.Audit = audit.Open(path, audit.Opts{})
+
+		_, out := e.run("log")
+		assertGolden(t, out)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		_, out := newTestEnv(items).run("log")
+		assertGolden(t, out)
+	})
+}
+
+func TestEnvRecord(t *testing.T) {
+	// the log can't be written to a directory
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := newTestEnv([]*vault.Item{{Name: "default", Key: []byte(testSecret)}})
+	e.Audit = audit.Open(dir, audit.Opts{Clock: totp.SystemClock})
+
+	_, out := e.run("get", "default")
+	assertGolden(t, strings.Replace(out, dir, "<dir>", -1))
+}
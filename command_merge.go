@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandMergeName = "merge"
+
+func NewCommandMerge(env *Env, cfg *vault.Config) *CommandMerge {
+	return &CommandMerge{env, cfg}
+}
+
+// CommandMerge merges vault files by items, it's meant to be git merge
+// driver of the vault:
+//
+//	git config merge.clotp.driver "clotp merge %O %A %B"
+//	echo "config.ini merge=clotp" >> .gitattributes
+//
+// Shared vault files are decrypted to be merged, so the vault is opened then.
+type CommandMerge struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandMerge) Execute(args []string) int {
+	mergeCommand := c.env.FlagSet(CommandMergeName)
+
+	if err := mergeCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if mergeCommand.NArg() != 3 {
+		fmt.Fprintf(c.env.Stderr, "invalid merge input, want <base> <ours> <theirs>: %s\n", mergeCommand.Args())
+		return 1
+	}
+
+	merge := vault.MergeFiles
+	if vault.IsSharedFile(mergeCommand.Arg(1)) || vault.IsSharedFile(mergeCommand.Arg(2)) {
+		cfg, err := c.env.openVault()
+		if err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+
+		merge = cfg.MergeFiles
+	}
+
+	// the result is written to ours file, git leaves it conflicted on failure
+	if err := merge(mergeCommand.Arg(0), mergeCommand.Arg(1), mergeCommand.Arg(2)); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := "[vpn]\nsecret = GE\nocra = OCRA-1:HOTP-SHA1-6:QN08\ncounter = 3\n\n[github]\nsecret = GE\n"
+
+	for _, c := range []struct {
+		name         string
+		ours, theirs string
+		args         []string
+		want         []string
+	}{
+		{
+			name:   "merged",
+			ours:   "[vpn]\nsecret = GE\nocra = OCRA-1:HOTP-SHA1-6:QN08\ncounter = 5\n\n[github]\nsecret = GE\n\n[aws]\nsecret = GE\n",
+			theirs: "[vpn]\nsecret = GE\nocra = OCRA-1:HOTP-SHA1-6:QN08\ncounter = 9\n",
+			want:   []string{"vpn:9", "aws:0"},
+		},
+		{
+			name:   "conflict",
+			ours:   "[vpn]\nsecret = GE\nocra = OCRA-1:HOTP-SHA1-6:QN08\ncounter = 3\n\n[github]\nsecret = GE\ndigits = 8\n",
+			theirs: "[vpn]\nsecret = GE\nocra = OCRA-1:HOTP-SHA1-6:QN08\ncounter = 4\n",
+			want:   []string{"vpn:4", "github:0"},
+		},
+		{
+			name: "invalid input",
+			args: []string{"merge", "base"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			paths := make([]string, 3)
+			for n, content := range []string{base, c.ours, c.theirs} {
+				paths[n] = filepath.Join(dir, c.name+[]string{".base", ".ours", ".theirs"}[n])
+				if err := ioutil.WriteFile(paths[n], []byte(content), 0600); err != nil {
+					panic(err)
+				}
+			}
+
+			args := c.args
+			if args == nil {
+				args = append([]string{"merge"}, paths...)
+			}
+
+			// the vault isn't opened by merge driver
+			e := newTestEnv(nil)
+			code := run(e.Env, func() (*vault.Config, error) { return nil, errors.New("vault shouldn't be opened") }, args)
+			assertGolden(t, e.output(code))
+
+			if c.want == nil {
+				return
+			}
+
+			cfg, err := vault.NewConfig(vault.Opts{Path: dir, Filename: filepath.Base(paths[1])})
+			if err != nil {
+				panic(err)
+			}
+
+			var got []string
+			for _, i := range cfg.Items {
+				got = append(got, fmt.Sprintf("%s:%d", i.Name, i.Counter))
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(c.want) {
+				t.Errorf("wrong merged items, want: %v != got: %v", c.want, got)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/securemem"
+	"github.com/mullakhmetov/clotp/vault"
 )
 
 const CommandNewName string = "new"
@@ -37,8 +39,8 @@ var (
 			Name: "algorithm",
 			Prompt: &survey.Select{
 				Message: "Choose an hash algorithm:",
-				Options: supportedAlgorithms,
-				Default: defaultAlgorithm,
+				Options: vault.SupportedAlgorithms,
+				Default: vault.DefaultAlgorithm,
 			},
 		},
 		{
@@ -48,6 +50,10 @@ var (
 				Default: "6",
 			},
 		},
+		{
+			Name:   "suite",
+			Prompt: &survey.Input{Message: "Enter OCRA suite, e.g. OCRA-1:HOTP-SHA1-6:QN08 (empty for TOTP)"},
+		},
 		{
 			Name:     "key",
 			Prompt:   &survey.Password{Message: "Enter secret key"},
@@ -56,21 +62,30 @@ var (
 	}
 )
 
-func NewCommandNewItem(cfg *Config) *CommandNewItem {
-	return &CommandNewItem{cfg}
+func NewCommandNewItem(env *Env, cfg *vault.Config) *CommandNewItem {
+	return &CommandNewItem{env, cfg}
 }
 
 type CommandNewItem struct {
-	cfg *Config
+	env *Env
+	cfg *vault.Config
 }
 
 func (c CommandNewItem) Execute(args []string) int {
-	newCommand := flag.NewFlagSet(CommandNewName, flag.ExitOnError)
+	newCommand := c.env.FlagSet(CommandNewName)
 	helpFlag := newCommand.Bool("help", false, "Get this help")
 	verboseFlag := newCommand.Bool("verbose", false, "Show verbose TOTP create input form")
+	nameFlag := newCommand.String("name", "", "Service name, it isn't asked then")
+
+	var secret secretSource
+
+	newCommand.StringVar(&secret.fd, "secret-fd", "", "Read the secret key from file descriptor, e.g. 0 for stdin")
+	newCommand.StringVar(&secret.file, "secret-file", "", "Read the secret key from file")
+	newCommand.StringVar(&secret.env, "secret-env", "", "Read the secret key from environment variable")
+	newCommand.StringVar(&secret.command, "secret-command", "", "Read the secret key from output of shell command")
 
 	if err := newCommand.Parse(args); err != nil {
-		fmt.Print(err)
+		// flag set has already reported the error
 		return 1
 	}
 
@@ -84,36 +99,90 @@ func (c CommandNewItem) Execute(args []string) int {
 		qs = verboseQs
 	}
 
-	return c.ask(qs)
+	var (
+		answers newAnswers
+		key     []byte
+	)
+
+	if *nameFlag != "" {
+		answers.Name = *nameFlag
+		qs = withoutQuestion(qs, "name")
+	}
+
+	if secret.isSet() {
+		var err error
+		if key, err = secret.read(c.env, true); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+
+		qs = withoutQuestion(qs, "key")
+	}
+
+	return c.ask(qs, answers, key)
 }
 
-func (c CommandNewItem) ask(qs []*survey.Question) int {
-	item := &Item{}
-	if err := survey.Ask(qs, item); err != nil {
-		fmt.Println(err)
-		return 1
+// withoutQuestion returns questions but the named one
+func withoutQuestion(qs []*survey.Question, name string) []*survey.Question {
+	res := make([]*survey.Question, 0, len(qs))
+	for _, q := range qs {
+		if q.Name != name {
+			res = append(res, q)
+		}
 	}
 
-	d, err := c.cfg.parseAlgorithmFn(item.Algorithm)
-	if err != nil {
-		fmt.Println(err)
-		return 1
+	return res
+}
+
+// newAnswers receives the form answers, survey can't write the secret into
+// the byte slice of vault.Item, so Key is used for the typed secret only
+type newAnswers struct {
+	Name      string
+	Issuer    string
+	Algorithm string
+	Digits    int
+	Suite     string
+	Key       string
+}
 
+// ask asks the questions, answers given by flags are kept. The key read from
+// a secret source is put into the item as is, it's asked otherwise.
+func (c CommandNewItem) ask(qs []*survey.Question, answers newAnswers, key []byte) int {
+	if len(qs) > 0 {
+		if err := c.env.Prompter.Ask(qs, &answers); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
 	}
 
-	item.digest = d
+	if key == nil {
+		key = []byte(answers.Key)
+	}
+
+	item := &vault.Item{
+		Name:      answers.Name,
+		Issuer:    answers.Issuer,
+		Key:       key,
+		Algorithm: answers.Algorithm,
+		Digits:    answers.Digits,
+		Suite:     answers.Suite,
+	}
 
 	if err := c.cfg.Add(item); err != nil {
-		fmt.Println(err)
+		securemem.Wipe(item.Key)
+		fmt.Fprintln(c.env.Stderr, err)
 		return 1
 	}
 
 	if err := c.cfg.Write(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(c.env.Stderr, err)
 		return 1
 	}
 
-	fmt.Printf("TOTP %s entity was successfully created\n", item.Name)
+	c.env.record(audit.ActionAdded, item.Name, CommandNewName)
+	c.env.commit("Add " + item.Name)
+
+	fmt.Fprintf(c.env.Stdout, "TOTP %s entity was successfully created\n", item.Name)
 
 	return 0
 }
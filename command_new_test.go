@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "new")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// pass(1) files keep more lines after the secret
+	secretFile := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secretFile, []byte("GE\nrecovery: 1234\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		panic(err)
+	}
+	defer r.Close()
+
+	if _, err := w.WriteString("GE"); err != nil {
+		panic(err)
+	}
+
+	w.Close()
+
+	defer setEnv("CLOTP_TEST_SECRET", "GE")()
+
+	for _, c := range []struct {
+		name    string
+		items   []*vault.Item
+		args    []string
+		answers []interface{}
+		want    []*vault.Item
+	}{
+		{
+			name:    "short",
+			args:    []string{"new"},
+			answers: []interface{}{"github", "GE"},
+			want:    []*vault.Item{{Name: "github", Key: []byte("GE")}},
+		},
+		{
+			name:    "verbose",
+			args:    []string{"new", "-verbose"},
+			answers: []interface{}{"bank", "Bank", "sha256", "8", "", "GE"},
+			want:    []*vault.Item{{Name: "bank", Issuer: "Bank", Key: []byte("GE"), Algorithm: "sha256", Digits: 8}},
+		},
+		{
+			name:    "verbose ocra",
+			args:    []string{"new", "-verbose"},
+			answers: []interface{}{"vpn", "", "sha1", "6", "OCRA-1:HOTP-SHA1-6:QN08", "GE"},
+			want:    []*vault.Item{{Name: "vpn", Key: []byte("GE"), Algorithm: "sha1", Digits: 6, Suite: "OCRA-1:HOTP-SHA1-6:QN08"}},
+		},
+		{
+			name:    "duplicate",
+			items:   []*vault.Item{{Name: "github", Key: []byte("GE")}},
+			args:    []string{"new"},
+			answers: []interface{}{"github", "GE"},
+		},
+		{
+			name:    "empty name",
+			args:    []string{"new"},
+			answers: []interface{}{""},
+		},
+		{
+			name: "secret env",
+			args: []string{"new", "-name", "github", "-secret-env", "CLOTP_TEST_SECRET"},
+			want: []*vault.Item{{Name: "github", Key: []byte("GE")}},
+		},
+		{
+			name:    "secret file",
+			args:    []string{"new", "-secret-file", secretFile},
+			answers: []interface{}{"github"},
+			want:    []*vault.Item{{Name: "github", Key: []byte("GE")}},
+		},
+		{
+			name: "secret fd",
+			args: []string{"new", "-name", "github", "-secret-fd", strconv.Itoa(int(r.Fd()))},
+			want: []*vault.Item{{Name: "github", Key: []byte("GE")}},
+		},
+		{
+			name: "secret command",
+			args: []string{"new", "-name", "github", "-secret-command", `printf 'GE\r\n'`},
+			want: []*vault.Item{{Name: "github", Key: []byte("GE")}},
+		},
+		{
+			name: "empty secret",
+			args: []string{"new", "-name", "github", "-secret-command", "true"},
+		},
+		{
+			name: "secret sources",
+			args: []string{"new", "-name", "github", "-secret-env", "CLOTP_TEST_SECRET", "-secret-command", "true"},
+		},
+		{
+			name: "help",
+			args: []string{"new", "-help"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv(c.items, c.answers...)
+			_, out := e.run(c.args...)
+			assertGolden(t, out)
+
+			if !reflect.DeepEqual(e.mapper.written, c.want) {
+				t.Errorf("wrong written items, want: %+v != got: %+v", c.want, e.mapper.written)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandOCRAName = "ocra"
+
+func NewCommandOCRA(env *Env, cfg *vault.Config) *CommandOCRA {
+	return &CommandOCRA{env, cfg}
+}
+
+type CommandOCRA struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandOCRA) Execute(args []string) int {
+	ocraCommand := c.env.FlagSet(CommandOCRAName)
+	challengeFlag := ocraCommand.String("challenge", "", "Challenge (question) given by the verifier")
+	counterFlag := ocraCommand.Uint64("counter", 0, "Counter value, overrides the stored one")
+	pinFlag := ocraCommand.String("pin", "", "PIN, asked interactively if the suite requires it")
+	sessionFlag := ocraCommand.String("session", "", "Session information")
+
+	args, err := parseInterspersed(ocraCommand, args)
+	if err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if len(args) != 1 {
+		fmt.Fprintf(c.env.Stderr, "invalid OCRA name input: %s\n", args)
+		return 1
+	}
+
+	item := c.cfg.Get(args[0])
+	if item == nil {
+		fmt.Fprintf(c.env.Stderr, "unknown OCRA name: %s\n", args[0])
+		return 1
+	}
+
+	ocra, err := item.OCRA()
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+	defer ocra.Wipe()
+
+	in := totp.OCRAInput{
+		Counter:   item.Counter,
+		Challenge: *challengeFlag,
+		PIN:       *pinFlag,
+		Session:   []byte(*sessionFlag),
+		Time:      c.env.Clock.Now(),
+	}
+
+	ocraCommand.Visit(func(f *flag.Flag) {
+		if f.Name == "counter" {
+			in.Counter = *counterFlag
+		}
+	})
+
+	if in.Challenge == "" {
+		fmt.Fprintln(c.env.Stderr, "challenge is required, use -challenge flag")
+		return 1
+	}
+
+	if ocra.Suite.PIN != nil && in.PIN == "" {
+		if err := c.env.Prompter.AskOne(&survey.Password{Message: "Enter PIN"}, &in.PIN); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+	}
+
+	code, err := ocra.Generate(in)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if ocra.Suite.Counter {
+		item.Counter = in.Counter + 1
+		if err := c.cfg.Write(); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+
+		c.env.commit(fmt.Sprintf("Set %s counter to %d", item.Name, item.Counter))
+	}
+
+	c.env.codeUsed(item.Name, CommandOCRAName)
+
+	fmt.Fprintln(c.env.Stdout, code)
+
+	return 0
+}
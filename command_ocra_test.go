@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandOCRA(t *testing.T) {
+	// RFC 6287 Appendix C test suites and keys
+	newItems := func() []*vault.Item {
+		return []*vault.Item{
+			{Name: "counter", Key: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"), Suite: "OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", Counter: 1},
+			{Name: "challenge", Key: []byte(testSecret), Suite: "OCRA-1:HOTP-SHA1-6:QN08"},
+			{Name: "totp", Key: []byte(testSecret)},
+		}
+	}
+
+	for _, c := range []struct {
+		name    string
+		args    []string
+		answers []interface{}
+		// wantCounter is the counter written, zero if the vault isn't written
+		wantCounter uint64
+	}{
+		{name: "counter", args: []string{"ocra", "-challenge", "12345678", "-pin", "1234", "counter"}, wantCounter: 2},
+		{name: "counter flag", args: []string{"ocra", "-challenge", "12345678", "-pin", "1234", "-counter", "3", "counter"}, wantCounter: 4},
+		{name: "pin asked", args: []string{"ocra", "-challenge", "12345678", "counter"}, answers: []interface{}{"1234"}, wantCounter: 2},
+		{name: "challenge", args: []string{"ocra", "-challenge", "11111111", "challenge"}},
+		{name: "no challenge", args: []string{"ocra", "challenge"}},
+		{name: "totp item", args: []string{"ocra", "-challenge", "11111111", "totp"}},
+		{name: "unknown name", args: []string{"ocra", "-challenge", "11111111", "unknown"}},
+		{name: "no name", args: []string{"ocra", "-challenge", "11111111"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv(newItems(), c.answers...)
+			_, out := e.run(c.args...)
+			assertGolden(t, out)
+
+			var got uint64
+			for _, i := range e.mapper.written {
+				if i.Name == "counter" {
+					got = i.Counter
+				}
+			}
+
+			if got != c.wantCounter {
+				t.Errorf("wrong written counter, want: %d != got: %d", c.wantCounter, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mullakhmetov/clotp/usage"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandPickName = "pick"
+
+func NewCommandPick(env *Env, cfg *vault.Config) *CommandPick {
+	return &CommandPick{env, cfg}
+}
+
+type CommandPick struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandPick) Execute(args []string) int {
+	known := make([]string, 0, len(menus))
+	for m := range menus {
+		known = append(known, m)
+	}
+
+	sort.Strings(known)
+
+	pickCommand := c.env.FlagSet(CommandPickName)
+	menuFlag := pickCommand.String("menu", "dmenu", "Menu program: "+strings.Join(known, ", ")+" or a shell command")
+	copyFlag := pickCommand.Bool("copy", false, "Copy the code to the clipboard instead of printing it")
+	clipboardFlag := pickCommand.String("clipboard", "", "Shell command reading the code to copy on stdin, detected by default")
+	minValidityFlag := pickCommand.Int("min-validity", 0, "Wait for the next code if the current one expires in fewer seconds")
+	sortFlag := pickCommand.String("sort", string(usage.ByRecent), sortFlagUsage)
+
+	if err := pickCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	items := totpItems(c.cfg.Items)
+	if len(items) == 0 {
+		fmt.Fprintln(c.env.Stderr, "there are no TOTP entities yet, create one with new command")
+		return 1
+	}
+
+	order, err := usage.ParseOrder(*sortFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	options := make([]string, 0, len(items))
+	names := make(map[string]string, len(items))
+
+	for _, i := range sortedItems(c.env, items, order) {
+		option := pickOption(i)
+		options = append(options, option)
+		names[option] = i.Name
+	}
+
+	selected, err := selectOption(menuCommand(*menuFlag), options, c.env.Stderr)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	name, ok := names[selected]
+	if !ok {
+		fmt.Fprintf(c.env.Stderr, "unknown TOTP name: %s\n", selected)
+		return 1
+	}
+
+	code, err := freshCode(c.env, c.cfg, name, time.Duration(*minValidityFlag)*time.Second, true)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.codeUsed(name, CommandPickName)
+
+	if !*copyFlag && *clipboardFlag == "" {
+		fmt.Fprintln(c.env.Stdout, code.Value)
+		return 0
+	}
+
+	var clipboard *exec.Cmd
+	if *clipboardFlag != "" {
+		clipboard = shellCommand(*clipboardFlag)
+	} else if clipboard, err = clipboardCommand(); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if err := copyToClipboard(clipboard, code.Value, c.env.Stderr); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(c.env.Stderr, "%s code copied, %ds remaining\n", name, seconds(code.ValidUntil.Sub(c.env.Clock.Now())))
+
+	return 0
+}
+
+// pickOption is the menu line of the item, item name with its issuer
+func pickOption(i *vault.Item) string {
+	if i.Issuer == "" {
+		return i.Name
+	}
+
+	return i.Name + " (" + i.Issuer + ")"
+}
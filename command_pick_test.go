@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandPick(t *testing.T) {
+	ocra := &vault.Item{Name: "ocra", Key: []byte(testSecret), Suite: "OCRA-1:HOTP-SHA1-6:QN08"}
+	// OCRA items have no TOTP codes, so they aren't offered
+	items := []*vault.Item{
+		ocra,
+		{Name: "github", Key: []byte(testSecret)},
+		{Name: "bank", Issuer: "Bank", Key: []byte(testSecret), Digits: 8},
+	}
+
+	for _, c := range []struct {
+		name  string
+		items []*vault.Item
+		args  []string
+	}{
+		{
+			name:  "first",
+			items: items,
+			args:  []string{"pick", "-menu", "head -n 1"},
+		},
+		{
+			name:  "issuer",
+			items: items,
+			args:  []string{"pick", "-menu", "grep Bank"},
+		},
+		{
+			name:  "cancelled",
+			items: items,
+			args:  []string{"pick", "-menu", "false"},
+		},
+		{
+			name:  "unknown selection",
+			items: items,
+			args:  []string{"pick", "-menu", "echo gitlab"},
+		},
+		{
+			name: "empty",
+			args: []string{"pick", "-menu", "head -n 1"},
+		},
+		{
+			name:  "ocra only",
+			items: []*vault.Item{ocra},
+			args:  []string{"pick", "-menu", "head -n 1"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv(c.items)
+			_, out := e.run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
+
+func TestCommandPick_Clipboard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clotp")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "clipboard")
+
+	e := newTestEnv([]*vault.Item{{Name: "github", Key: []byte(testSecret)}})
+	_, out := e.run("pick", "-menu", "cat", "-clipboard", "cat > "+path)
+	assertGolden(t, out)
+
+	copied, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("code wasn't copied: %v", err)
+	}
+
+	if string(copied) != "081804" {
+		t.Errorf("wrong copied code: %q", copied)
+	}
+}
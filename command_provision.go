@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/vault"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	CommandProvisionName = "provision"
+
+	// provisionSkew is how many time steps around the current one are accepted
+	// as a confirmation code, user needs some time to scan and type it
+	provisionSkew = 1
+)
+
+func NewCommandProvision(env *Env, cfg *vault.Config) *CommandProvision {
+	return &CommandProvision{env, cfg}
+}
+
+type CommandProvision struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandProvision) Execute(args []string) int {
+	provisionCommand := c.env.FlagSet(CommandProvisionName)
+	issuerFlag := provisionCommand.String("issuer", "", "Issuer name")
+	algorithmFlag := provisionCommand.String("algorithm", vault.DefaultAlgorithm, "Hash algorithm")
+	digitsFlag := provisionCommand.Int("digits", vault.DefaultDigits, "Number of digits in the TOTP code")
+	stepFlag := provisionCommand.Int("step", vault.DefaultStep, "Time step in seconds")
+	bytesFlag := provisionCommand.Int("bytes", 0, "Secret size in bytes, hash output size by default")
+
+	args, err := parseInterspersed(provisionCommand, args)
+	if err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if len(args) != 1 {
+		fmt.Fprintf(c.env.Stderr, "invalid TOTP name input: %s\n", args)
+		return 1
+	}
+
+	if c.cfg.Get(args[0]) != nil {
+		fmt.Fprintf(c.env.Stderr, "%v: %s\n", vault.ErrItemAlreadyExists, args[0])
+		return 1
+	}
+
+	key, err := generateSecret(*algorithmFlag, *bytesFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	item := &vault.Item{
+		Name:      args[0],
+		Issuer:    *issuerFlag,
+		Key:       key,
+		Algorithm: *algorithmFlag,
+		Digits:    *digitsFlag,
+		Step:      *stepFlag,
+	}
+
+	if !item.Validate() {
+		fmt.Fprintln(c.env.Stderr, vault.ErrInvalidItem)
+		return 1
+	}
+
+	uri := item.URI()
+
+	qr, err := qrcode.New(uri, qrcode.Medium)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	fmt.Fprintln(c.env.Stdout, qr.ToSmallString(false))
+	fmt.Fprintln(c.env.Stdout, uri)
+	fmt.Fprintln(c.env.Stdout)
+
+	var code string
+	if err := c.env.Prompter.AskOne(
+		&survey.Input{Message: "Enter the code shown by the authenticator to confirm"},
+		&code,
+		survey.WithValidator(survey.Required),
+	); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if !confirmCode(item, strings.TrimSpace(code), c.env.Clock.Now()) {
+		fmt.Fprintln(c.env.Stderr, "confirmation code doesn't match, TOTP entity wasn't saved")
+		return 1
+	}
+
+	if err := c.cfg.Add(item); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if err := c.cfg.Write(); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.record(audit.ActionAdded, item.Name, CommandProvisionName)
+	c.env.commit("Provision " + item.Name)
+
+	fmt.Fprintf(c.env.Stdout, "TOTP %s entity was successfully provisioned\n", item.Name)
+
+	return 0
+}
+
+// confirmCode checks whether the code matches one of item's codes near given
+// time, the codes are compared in constant time
+func confirmCode(item *vault.Item, code string, now time.Time) bool {
+	t, err := item.TOTP()
+	if err != nil {
+		return false
+	}
+	defer t.Wipe()
+
+	_, matched, err := t.Match(now, provisionSkew, provisionSkew, code)
+
+	return err == nil && matched
+}
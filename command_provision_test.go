@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// authenticatorPrompter answers the confirmation with the code of the URI
+// printed to out, like an authenticator app scanning the QR code does
+type authenticatorPrompter struct {
+	out *bytes.Buffer
+	now time.Time
+}
+
+func (p *authenticatorPrompter) Ask([]*survey.Question, interface{}, ...survey.AskOpt) error {
+	panic("unexpected form")
+}
+
+func (p *authenticatorPrompter) AskOne(_ survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+	var uri string
+
+	s := bufio.NewScanner(bytes.NewReader(p.out.Bytes()))
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "otpauth://") {
+			uri = s.Text()
+		}
+	}
+
+	item, err := vault.ParseURI(uri)
+	if err != nil {
+		return err
+	}
+
+	t, err := item.TOTP()
+	if err != nil {
+		return err
+	}
+	defer t.Wipe()
+
+	return core.WriteAnswer(response, "", t.AtTime(p.now))
+}
+
+func TestCommandProvision(t *testing.T) {
+	t.Run("confirmed", func(t *testing.T) {
+		e := newTestEnv(nil)
+		e.Prompter = &authenticatorPrompter{out: &e.stdout, now: testTime}
+
+		code, out := e.run("provision", "-issuer", "Example", "-digits", "8", "github")
+		if code != 0 {
+			t.Fatalf("item isn't provisioned: %s", out)
+		}
+
+		if len(e.mapper.written) != 1 {
+			t.Fatalf("wrong written items: %+v", e.mapper.written)
+		}
+
+		item := e.mapper.written[0]
+		if item.Name != "github" || item.Issuer != "Example" || item.Digits != 8 || !item.Validate() {
+			t.Errorf("wrong item: %+v", item)
+		}
+
+		if !strings.HasSuffix(out, "TOTP github entity was successfully provisioned\n--- stderr\n") {
+			t.Errorf("wrong output: %s", out)
+		}
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		e := newTestEnv(nil, "not a code")
+
+		code, _ := e.run("provision", "github")
+		if code != 1 || e.stderr.String() != "confirmation code doesn't match, TOTP entity wasn't saved\n" {
+			t.Errorf("wrong result: %d %s", code, e.stderr.String())
+		}
+
+		if e.mapper.written != nil || e.cfg.Get("github") != nil {
+			t.Errorf("item is saved: %+v", e.mapper.written)
+		}
+	})
+
+	for _, c := range []struct {
+		name  string
+		items []*vault.Item
+		args  []string
+	}{
+		{name: "duplicate name", items: []*vault.Item{{Name: "github", Key: []byte(testSecret)}}, args: []string{"provision", "github"}},
+		{name: "invalid algorithm", args: []string{"provision", "-algorithm", "md5", "github"}},
+		{name: "no name", args: []string{"provision"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newTestEnv(c.items).run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/backup"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandRestoreName = "restore"
+
+func NewCommandRestore(env *Env, cfg *vault.Config) *CommandRestore {
+	return &CommandRestore{env, cfg}
+}
+
+type CommandRestore struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandRestore) Execute(args []string) int {
+	restoreCommand := c.env.FlagSet(CommandRestoreName)
+	mergeFlag := restoreCommand.String("merge", "",
+		"What to do with items which already exist: skip, overwrite or rename, restore fails by default")
+
+	args, err := parseInterspersed(restoreCommand, args)
+	if err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if len(args) != 1 {
+		fmt.Fprintf(c.env.Stderr, "invalid backup file input: %s\n", args)
+		return 1
+	}
+
+	policy, err := backup.ParsePolicy(*mergeFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	passphrase, err := askPassphrase(c.env, "Enter backup passphrase", false)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	items, created, err := backup.Read(f, passphrase)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	changes, err := backup.Restore(c.cfg, items, policy)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+
+		if errors.Is(err, vault.ErrItemAlreadyExists) {
+			fmt.Fprintln(c.env.Stderr, "nothing was restored, use -merge to resolve conflicts")
+		} else {
+			fmt.Fprintln(c.env.Stderr, "nothing was restored")
+		}
+
+		return 1
+	}
+
+	if err := c.cfg.Write(); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	c.env.record(audit.ActionImport, "", "backup")
+	c.env.commit("Restore backup of " + created.Format("2006-01-02 15:04:05 MST"))
+
+	for _, ch := range changes {
+		switch ch.Action {
+		case backup.Added, backup.Renamed:
+			c.env.record(audit.ActionAdded, ch.Name, CommandRestoreName)
+		case backup.Overwritten:
+			c.env.record(audit.ActionEdited, ch.Name, CommandRestoreName)
+		}
+	}
+
+	fmt.Fprintf(c.env.Stdout, "backup of %s:\n", created.Format("2006-01-02 15:04:05 MST"))
+
+	for _, ch := range changes {
+		if ch.Action == backup.Renamed {
+			fmt.Fprintf(c.env.Stdout, "%s %s -> %s\n", ch.Action, ch.Item, ch.Name)
+			continue
+		}
+
+		fmt.Fprintf(c.env.Stdout, "%s %s\n", ch.Action, ch.Item)
+	}
+
+	return 0
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandRevertName = "revert"
+
+func NewCommandRevert(env *Env, cfg *vault.Config) *CommandRevert {
+	return &CommandRevert{env, cfg}
+}
+
+type CommandRevert struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandRevert) Execute(args []string) int {
+	revertCommand := c.env.FlagSet(CommandRevertName)
+
+	if err := revertCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if revertCommand.NArg() != 1 {
+		fmt.Fprintf(c.env.Stderr, "invalid revision input: %s\n", revertCommand.Args())
+		return 1
+	}
+
+	if !hasHistory(c.env) {
+		return 1
+	}
+
+	rev := revertCommand.Arg(0)
+	if err := c.env.History.Revert(rev); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if !reloadVault(c.env, c.cfg, CommandRevertName) {
+		return 1
+	}
+
+	fmt.Fprintf(c.env.Stdout, "changes of %s were reverted\n", rev)
+
+	return 0
+}
+
+// reloadVault reads the vault changed by the command from the disk and
+// records the changes to the audit log
+func reloadVault(env *Env, cfg *vault.Config, command string) bool {
+	before := cfg.Items
+
+	if err := cfg.Reload(); err != nil {
+		fmt.Fprintln(env.Stderr, err)
+		return false
+	}
+
+	env.recordChanges(before, cfg.Items, command)
+
+	for _, i := range before {
+		i.Wipe()
+	}
+
+	return true
+}
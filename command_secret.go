@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mullakhmetov/clotp/securemem"
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const (
+	CommandSecretName         = "secret"
+	commandSecretGenerateName = "generate"
+)
+
+func NewCommandSecret(env *Env, cfg *vault.Config) *CommandSecret {
+	return &CommandSecret{env, cfg}
+}
+
+type CommandSecret struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandSecret) Execute(args []string) int {
+	if len(args) == 0 || args[0] != commandSecretGenerateName {
+		fmt.Fprintln(c.env.Stderr, "usage: clotp secret generate [-bytes <n>] [-algorithm <sha1|sha256|sha512>]")
+		return 1
+	}
+
+	generateCommand := c.env.FlagSet(commandSecretGenerateName)
+	bytesFlag := generateCommand.Int("bytes", 0, "Secret size in bytes, hash output size by default")
+	algorithmFlag := generateCommand.String("algorithm", vault.DefaultAlgorithm, "Hash algorithm the secret is used with")
+
+	if err := generateCommand.Parse(args[1:]); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	secret, err := generateSecret(*algorithmFlag, *bytesFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	defer securemem.Wipe(secret)
+
+	fmt.Fprintf(c.env.Stdout, "%s\n", secret)
+
+	return 0
+}
+
+// generateSecret returns new base32 encoded secret of given size or of the
+// algorithm's output size if size is zero
+func generateSecret(algorithm string, size int) ([]byte, error) {
+	d, err := vault.ParseAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if size == 0 {
+		size = d().Size()
+	}
+
+	secret, err := totp.GenerateSecret(size)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(secret)
+
+	return vault.EncodeBase32Secret(secret), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandSecret(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		args    []string
+		wantLen int
+	}{
+		{name: "default", args: []string{"secret", "generate"}, wantLen: 20},
+		{name: "sha512", args: []string{"secret", "generate", "-algorithm", "sha512"}, wantLen: 64},
+		{name: "bytes", args: []string{"secret", "generate", "-bytes", "16", "-algorithm", "sha256"}, wantLen: 16},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv(nil)
+
+			code, out := e.run(c.args...)
+			if code != 0 {
+				t.Fatalf("secret isn't generated: %s", out)
+			}
+
+			secret, err := vault.DecodeBase32Secret([]byte(strings.TrimSuffix(e.stdout.String(), "\n")))
+			if err != nil {
+				t.Fatalf("invalid secret: %v", err)
+			}
+
+			if len(secret) != c.wantLen {
+				t.Errorf("wrong secret size, want: %d != got: %d", c.wantLen, len(secret))
+			}
+		})
+	}
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{name: "no subcommand", args: []string{"secret"}},
+		{name: "unknown subcommand", args: []string{"secret", "rotate"}},
+		{name: "invalid algorithm", args: []string{"secret", "generate", "-algorithm", "md5"}},
+		{name: "too short", args: []string{"secret", "generate", "-bytes", "10"}},
+		{name: "invalid input", args: []string{"secret", "generate", "-bytes", "many"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, out := newTestEnv(nil).run(c.args...)
+			assertGolden(t, out)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/mullakhmetov/clotp/server"
+	"github.com/mullakhmetov/clotp/vault"
+	"github.com/mullakhmetov/clotp/verify"
+)
+
+const (
+	CommandServeName = "serve"
+
+	defaultServeListen  = "127.0.0.1:8765"
+	defaultTokensName   = "tokens"
+	defaultVerifyName   = "verify.json"
+	serveTimeout        = 10 * time.Second
+	serveShutdownPeriod = 5 * time.Second
+)
+
+func NewCommandServe(env *Env, cfg *vault.Config) *CommandServe {
+	return &CommandServe{env, cfg}
+}
+
+type CommandServe struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandServe) Execute(args []string) int {
+	serveCommand := c.env.FlagSet(CommandServeName)
+	listenFlag := serveCommand.String("listen", defaultServeListen, "Address to listen on: unix:<path> or loopback <host>:<port>")
+	tokensFlag := serveCommand.String("tokens", filepath.Join(vault.DefaultDir(), defaultTokensName),
+		"File with bearer tokens and items they are allowed to access")
+	windowFlag := serveCommand.Int("window", verify.DefaultWindow, "Number of time steps before and after the current one accepted by verification")
+	maxFailuresFlag := serveCommand.Int("max-failures", verify.DefaultMaxFailures, "Number of failed verifications in a row locking the user out")
+	lockoutFlag := serveCommand.Duration("lockout", verify.DefaultLockout, "How long the user is locked out for")
+	stateFlag := serveCommand.String("verify-state", filepath.Join(vault.DefaultDir(), defaultVerifyName),
+		"File keeping used codes and failed attempts, empty keeps them in memory")
+
+	if err := serveCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	tokens, err := server.ReadTokensFile(*tokensFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	var store verify.Store = verify.NewMemoryStore()
+	if *stateFlag != "" {
+		if store, err = verify.NewFileStore(*stateFlag); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+	}
+
+	validator := verify.NewValidator(store, verify.Opts{
+		Window:      *windowFlag,
+		MaxFailures: *maxFailuresFlag,
+		Lockout:     *lockoutFlag,
+		Clock:       c.env.Clock,
+	})
+
+	l, err := server.Listen(*listenFlag)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	handler := server.New(c.cfg, tokens, validator, c.env.Clock)
+	handler.OnCode = func(name string) { c.env.codeUsed(name, CommandServeName) }
+
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  serveTimeout,
+		WriteTimeout: serveTimeout,
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	defer signal.Stop(sig)
+
+	go func() {
+		<-sig
+
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownPeriod)
+		defer cancel()
+
+		_ = srv.Shutdown(ctx)
+	}()
+
+	fmt.Fprintf(c.env.Stderr, "serving on %s\n", *listenFlag)
+
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
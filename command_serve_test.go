@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serve")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "serve.sock")
+	items := []*vault.Item{{Name: "github", Key: []byte(testSecret), Digits: 8}}
+
+	t.Run("missing tokens", func(t *testing.T) {
+		e := newTestEnv(items)
+		_, out := e.run("serve", "-listen", "unix:"+path, "-tokens", filepath.Join(dir, "missing"))
+		assertGolden(t, strings.Replace(out, dir, "$DIR", -1))
+	})
+}
@@ -0,0 +1,93 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestCommandServe_Signal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serve")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokens := filepath.Join(dir, "tokens")
+	if err := ioutil.WriteFile(tokens, []byte("t1 github\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	path := filepath.Join(dir, "serve.sock")
+
+	e := newTestEnv([]*vault.Item{{Name: "github", Key: []byte(testSecret), Digits: 8}})
+	done := make(chan int, 1)
+
+	go func() {
+		code, _ := e.run("serve", "-listen", "unix:"+path, "-tokens", tokens, "-verify-state", "")
+		done <- code
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	req, err := http.NewRequest(http.MethodGet, "http://clotp/v1/items/github/code", nil)
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Authorization", "Bearer t1")
+
+	var resp *http.Response
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if resp, err = client.Do(req); err == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("server isn't started: %v", err)
+		}
+	}
+	defer resp.Body.Close()
+
+	var got struct{ Code string }
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	if got.Code != "07081804" {
+		t.Errorf("wrong code: %s", got.Code)
+	}
+
+	// the server has handled the request, so it's notified of signals
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		panic(err)
+	}
+
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		panic(err)
+	}
+
+	select {
+	case code := <-done:
+		assertGolden(t, strings.Replace(e.output(code), dir, "$DIR", -1))
+	case <-time.After(5 * time.Second):
+		t.Fatal("server isn't stopped")
+	}
+}
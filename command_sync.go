@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const CommandSyncName = "sync"
+
+func NewCommandSync(env *Env, cfg *vault.Config) *CommandSync {
+	return &CommandSync{env, cfg}
+}
+
+type CommandSync struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandSync) Execute(args []string) int {
+	syncCommand := c.env.FlagSet(CommandSyncName)
+
+	if err := syncCommand.Parse(args); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	if !hasHistory(c.env) {
+		return 1
+	}
+
+	// changes of different items are merged, an item changed differently on
+	// both sides aborts the rebase, local commits are kept unsynced then
+	if err := c.env.History.Sync(c.cfg.MergeFiles); err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		fmt.Fprintln(c.env.Stderr, "nothing was synced")
+		return 1
+	}
+
+	if !reloadVault(c.env, c.cfg, CommandSyncName) {
+		return 1
+	}
+
+	fmt.Fprintf(c.env.Stdout, "vault is synced, %d items\n", len(c.cfg.Items))
+
+	return 0
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/envelope"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+const (
+	CommandVaultName             = "vault"
+	commandVaultRecipients       = "recipients"
+	commandVaultRecipientsAdd    = "add"
+	commandVaultRecipientsRemove = "remove"
+)
+
+const vaultUsage = "usage: clotp vault recipients [add|remove <age1...|ssh-ed25519 key|public key file>]"
+
+func NewCommandVault(env *Env, cfg *vault.Config) *CommandVault {
+	return &CommandVault{env, cfg}
+}
+
+// CommandVault manages recipients of the shared vault, see CLOTP_SHARED_VAULT
+type CommandVault struct {
+	env *Env
+	cfg *vault.Config
+}
+
+func (c CommandVault) Execute(args []string) int {
+	if len(args) == 0 || args[0] != commandVaultRecipients {
+		fmt.Fprintln(c.env.Stderr, vaultUsage)
+		return 1
+	}
+
+	recipientsCommand := c.env.FlagSet(commandVaultRecipients)
+
+	if err := recipientsCommand.Parse(args[1:]); err != nil {
+		// flag set has already reported the error
+		return 1
+	}
+
+	args = recipientsCommand.Args()
+
+	var (
+		action string
+		r      envelope.Recipient
+	)
+
+	if len(args) > 0 {
+		action = args[0]
+		if (action != commandVaultRecipientsAdd && action != commandVaultRecipientsRemove) || len(args) < 2 {
+			fmt.Fprintln(c.env.Stderr, vaultUsage)
+			return 1
+		}
+
+		var err error
+		if r, err = parseRecipientArg(strings.Join(args[1:], " ")); err != nil {
+			fmt.Fprintln(c.env.Stderr, err)
+			return 1
+		}
+	}
+
+	m, err := openShared(c.env)
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+	defer m.Wipe()
+
+	items, err := m.Read()
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	defer func() {
+		for _, i := range items {
+			i.Wipe()
+		}
+	}()
+
+	switch action {
+	case "":
+		for _, s := range m.Recipients() {
+			fmt.Fprintln(c.env.Stdout, s)
+		}
+
+		return 0
+	case commandVaultRecipientsAdd:
+		err = m.AddRecipient(r)
+	case commandVaultRecipientsRemove:
+		err = m.RemoveRecipient(r)
+	}
+
+	if err == nil {
+		err = m.Write(items)
+	}
+
+	if err != nil {
+		fmt.Fprintln(c.env.Stderr, err)
+		return 1
+	}
+
+	if action == commandVaultRecipientsAdd {
+		c.env.record(audit.ActionRecipientAdded, "", r.String())
+		c.env.commit("Add recipient " + r.String())
+		fmt.Fprintf(c.env.Stdout, "%s is added\n", r)
+
+		return 0
+	}
+
+	c.env.record(audit.ActionRecipientRemoved, "", r.String())
+	c.env.commit("Remove recipient " + r.String())
+	fmt.Fprintf(c.env.Stdout, "%s is removed, the data key is rotated\n", r)
+
+	// the recipient may have copied the secrets
+	if len(items) > 0 {
+		fmt.Fprintf(c.env.Stdout, "secrets of %d items were readable by the recipient, rotate them\n", len(items))
+	}
+
+	return 0
+}
+
+// parseRecipientArg parses the recipient key or the public key file, e.g.
+// ~/.ssh/id_ed25519.pub
+func parseRecipientArg(arg string) (envelope.Recipient, error) {
+	r, err := envelope.ParseRecipient(arg)
+	if err == nil {
+		return r, nil
+	}
+
+	if _, statErr := os.Stat(arg); statErr != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err = envelope.ParseRecipient(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", arg, err)
+	}
+
+	return r, nil
+}
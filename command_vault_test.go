@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	// testAgeIdentity is age identity of age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj
+	testAgeIdentity = "AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"
+	// testSSHIdentity is ssh-ed25519 key protected by testSSHPassphrase
+	testSSHIdentity   = "envelope/testdata/id_ed25519"
+	testSSHPassphrase = "correct horse"
+)
+
+func TestCommandVault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ageIdentity := filepath.Join(dir, "age.txt")
+	if err := ioutil.WriteFile(ageIdentity, []byte("# created: 2005-03-18T01:58:29Z\n"+testAgeIdentity+"\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	defer setEnv(sharedVaultEnv, filepath.Join(dir, "team.vault"))()
+	defer setEnv(identityEnv, testSSHIdentity)()
+
+	for _, c := range []struct {
+		name       string
+		identity   string
+		passphrase string
+		args       []string
+		answers    []interface{}
+	}{
+		{
+			name:    "missing vault",
+			args:    []string{"vault", "recipients"},
+			answers: []interface{}{testSSHPassphrase},
+		},
+		{
+			name:    "add",
+			args:    []string{"vault", "recipients", "add", "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"},
+			answers: []interface{}{testSSHPassphrase},
+		},
+		{
+			name:       "passphrase command",
+			passphrase: "echo '" + testSSHPassphrase + "'",
+			args:       []string{"vault", "recipients"},
+		},
+		{
+			name:     "add existing",
+			identity: ageIdentity,
+			args:     []string{"vault", "recipients", "add", testSSHIdentity + ".pub"},
+		},
+		{
+			name:     "list",
+			identity: ageIdentity,
+			args:     []string{"vault", "recipients"},
+		},
+		{
+			name:     "remove",
+			identity: ageIdentity,
+			args:     []string{"vault", "recipients", "remove", "ssh-ed25519", "AAAAC3NzaC1lZDI1NTE5AAAAIP+P5z3rNMzKdWGZtymkzrgcc0bJizSYD0iAuuDZOt5V"},
+		},
+		{
+			name:    "removed recipient",
+			args:    []string{"vault", "recipients"},
+			answers: []interface{}{testSSHPassphrase},
+		},
+		{
+			name:     "remove last",
+			identity: ageIdentity,
+			args:     []string{"vault", "recipients", "remove", "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"},
+		},
+		{
+			name: "invalid key",
+			args: []string{"vault", "recipients", "add", "ssh-rsa", "AAAAB3NzaC1yc2EAAAADAQABAAABAQ"},
+		},
+		{
+			name: "invalid input",
+			args: []string{"vault", "members"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if c.identity != "" {
+				defer setEnv(identityEnv, c.identity)()
+			}
+
+			if c.passphrase != "" {
+				defer setEnv(passphraseCommandEnv, c.passphrase)()
+			}
+
+			e := newTestEnv(nil, c.answers...)
+			_, out := e.run(c.args...)
+			assertGolden(t, strings.Replace(out, dir, "$DIR", -1))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/history"
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/usage"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// Prompter asks user for input, see survey.Ask and survey.AskOne
+type Prompter interface {
+	Ask(qs []*survey.Question, response interface{}, opts ...survey.AskOpt) error
+	AskOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error
+}
+
+// Agent generates codes of the items it holds, see agent.Client
+type Agent interface {
+	Code(name string, at time.Time, offset int) (totp.Code, error)
+}
+
+// Env is the environment commands are executed in
+type Env struct {
+	Stdin    io.Reader
+	Stdout   io.Writer
+	Stderr   io.Writer
+	Prompter Prompter
+	Clock    totp.Clock
+	Sleep    func(time.Duration)
+	// Agent is nil if agent isn't running
+	Agent Agent
+	// Audit is nil if audit log is off
+	Audit *audit.Log
+	// Usage is nil if usage statistics aren't kept
+	Usage *usage.Store
+	// History is nil if the vault isn't kept in a git repository
+	History *history.Repo
+
+	// openVault opens the vault once for commands run without it, e.g. for
+	// get of an item added after the agent was started
+	openVault func() (*vault.Config, error)
+}
+
+// NewEnv returns environment of the process: standard streams, interactive
+// terminal prompts and the system clock
+func NewEnv() *Env {
+	return &Env{
+		Stdin:    os.Stdin,
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Prompter: surveyPrompter{},
+		Clock:    totp.SystemClock,
+		Sleep:    time.Sleep,
+	}
+}
+
+// FlagSet returns flag set which reports errors to env's stderr instead of
+// exiting the process
+func (e *Env) FlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.Stderr)
+
+	return fs
+}
+
+// record appends event to the audit log if it's on, failed writes are
+// reported but don't fail the command
+func (e *Env) record(action, item, detail string) {
+	if e.Audit == nil {
+		return
+	}
+
+	if err := e.Audit.Record(action, item, detail); err != nil {
+		fmt.Fprintf(e.Stderr, "failed to write audit log: %v\n", err)
+	}
+}
+
+// codeUsed records generation of the item code by the command to the audit
+// log and to usage statistics
+func (e *Env) codeUsed(name, command string) {
+	e.record(audit.ActionCode, name, command)
+
+	if e.Usage == nil {
+		return
+	}
+
+	if err := e.Usage.Use(name, e.Clock.Now()); err != nil {
+		fmt.Fprintf(e.Stderr, "failed to write usage statistics: %v\n", err)
+	}
+}
+
+// commit commits the vault to its git repository if it's kept in one
+func (e *Env) commit(message string) {
+	if e.History == nil {
+		return
+	}
+
+	if err := e.History.Commit(message); err != nil {
+		fmt.Fprintf(e.Stderr, "failed to commit vault changes: %v\n", err)
+	}
+}
+
+// recordChanges records items added, edited and removed by the command
+// replacing items before with items after to the audit log
+func (e *Env) recordChanges(before, after []*vault.Item, command string) {
+	old := make(map[string]*vault.Item, len(before))
+	for _, i := range before {
+		old[i.Name] = i
+	}
+
+	for _, i := range after {
+		prev, ok := old[i.Name]
+		delete(old, i.Name)
+
+		switch {
+		case !ok:
+			e.record(audit.ActionAdded, i.Name, command)
+		case !prev.Equal(i):
+			e.record(audit.ActionEdited, i.Name, command)
+		}
+	}
+
+	for _, i := range before {
+		if _, ok := old[i.Name]; ok {
+			e.record(audit.ActionRemoved, i.Name, command)
+		}
+	}
+}
+
+type surveyPrompter struct{}
+
+func (surveyPrompter) Ask(qs []*survey.Question, response interface{}, opts ...survey.AskOpt) error {
+	return survey.Ask(qs, response, opts...)
+}
+
+func (surveyPrompter) AskOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+	return survey.AskOne(p, response, opts...)
+}
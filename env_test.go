@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/core"
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testTime is the frozen time of the test environment, RFC 6238 test vector
+var testTime = time.Unix(1111111109, 0).UTC()
+
+// testSecret is RFC 6238 test secret "12345678901234567890" encoded with base32
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// scriptedPrompter answers prompts with predefined answers in order and
+// writes a transcript of prompts and answers to out
+type scriptedPrompter struct {
+	answers []interface{}
+	out     io.Writer
+}
+
+func (p *scriptedPrompter) Ask(qs []*survey.Question, response interface{}, _ ...survey.AskOpt) error {
+	for _, q := range qs {
+		a, err := p.answer(q.Prompt)
+		if err != nil {
+			return err
+		}
+
+		if q.Validate != nil {
+			if err := q.Validate(a); err != nil {
+				return err
+			}
+		}
+
+		if err := core.WriteAnswer(response, q.Name, a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *scriptedPrompter) AskOne(prompt survey.Prompt, response interface{}, _ ...survey.AskOpt) error {
+	a, err := p.answer(prompt)
+	if err != nil {
+		return err
+	}
+
+	return core.WriteAnswer(response, "", a)
+}
+
+func (p *scriptedPrompter) answer(prompt survey.Prompt) (interface{}, error) {
+	var message string
+
+	switch pr := prompt.(type) {
+	case *survey.Input:
+		message = pr.Message
+	case *survey.Password:
+		message = pr.Message
+	case *survey.Select:
+		message = pr.Message + " [" + strings.Join(pr.Options, ", ") + "]"
+	case *survey.Confirm:
+		message = pr.Message
+	}
+
+	if len(p.answers) == 0 {
+		return nil, fmt.Errorf("unexpected prompt: %s", message)
+	}
+
+	a := p.answers[0]
+	p.answers = p.answers[1:]
+
+	if _, ok := prompt.(*survey.Password); ok {
+		fmt.Fprintf(p.out, "? %s ********\n", message)
+	} else {
+		fmt.Fprintf(p.out, "? %s %v\n", message, a)
+	}
+
+	return a, nil
+}
+
+// memMapper keeps vault items in memory
+type memMapper struct {
+	items   []*vault.Item
+	written []*vault.Item
+}
+
+func (m *memMapper) Read() ([]*vault.Item, error) {
+	return m.items, nil
+}
+
+func (m *memMapper) Write(items []*vault.Item) error {
+	m.written = items
+	return nil
+}
+
+type testEnv struct {
+	*Env
+	now            time.Time
+	stdout, stderr bytes.Buffer
+	mapper         *memMapper
+	cfg            *vault.Config
+}
+
+func newTestEnv(items []*vault.Item, answers ...interface{}) *testEnv {
+	e := &testEnv{mapper: &memMapper{items: items}, now: testTime}
+	e.Env = &Env{
+		Stdin:    strings.NewReader(""),
+		Stdout:   &e.stdout,
+		Stderr:   &e.stderr,
+		Prompter: &scriptedPrompter{answers: answers, out: &e.stdout},
+		Clock:    totp.ClockFunc(func() time.Time { return e.now }),
+		Sleep:    func(d time.Duration) { e.now = e.now.Add(d) },
+	}
+
+	cfg, err := vault.NewConfigWithMapper(e.mapper)
+	if err != nil {
+		panic(err)
+	}
+
+	e.cfg = cfg
+
+	return e
+}
+
+// run runs command line and returns its exit code and output
+func (e *testEnv) run(args ...string) (int, string) {
+	code := run(e.Env, func() (*vault.Config, error) { return e.cfg, nil }, args)
+
+	return code, e.output(code)
+}
+
+func (e *testEnv) output(code int) string {
+	return fmt.Sprintf("exit code: %d\n--- stdout\n%s--- stderr\n%s", code, e.stdout.String(), e.stderr.String())
+}
+
+// assertGolden compares got with testdata/<test name>.golden file content,
+// run tests with -update flag to rewrite golden files
+func assertGolden(t *testing.T, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", filepath.FromSlash(t.Name())+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			panic(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(got), 0600); err != nil {
+			panic(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(want) != got {
+		t.Errorf("output differs from %s, want:\n%s\ngot:\n%s", path, want, got)
+	}
+}
+
+func TestScriptedPrompter(t *testing.T) {
+	var out bytes.Buffer
+	p := &scriptedPrompter{answers: []interface{}{"n", "k"}, out: &out}
+
+	var answers newAnswers
+	if err := p.Ask(shortQs, &answers); err != nil {
+		panic(err)
+	}
+
+	if answers.Name != "n" || answers.Key != "k" {
+		t.Errorf("wrong answers written: %+v", answers)
+	}
+
+	var s string
+	if err := p.AskOne(&survey.Input{Message: "one more"}, &s); err == nil {
+		t.Error("error shouldn't be nil when answers are over")
+	}
+
+	want := "? Enter service name n\n? Enter secret key ********\n"
+	if out.String() != want {
+		t.Errorf("wrong transcript, want: %q != got: %q", want, out.String())
+	}
+}
+
+// setEnv sets environment variable and returns function restoring it
+func setEnv(key, value string) func() {
+	prev, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+
+	return func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package envelope
+
+import (
+	"errors"
+	"strings"
+)
+
+// bech32 encoding of age keys, see BIP 173. age doesn't limit the length of
+// encoded strings.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+var errInvalidBech32 = errors.New("invalid bech32 string")
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+
+		for i, g := range bech32Generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	res := make([]byte, 0, len(hrp)*2+1)
+	for _, c := range hrp {
+		res = append(res, byte(c>>5))
+	}
+
+	res = append(res, 0)
+
+	for _, c := range hrp {
+		res = append(res, byte(c&31))
+	}
+
+	return res
+}
+
+// convertBits regroups bits of data, see BIP 173 reference implementation
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var (
+		acc  uint32
+		bits uint
+		res  []byte
+	)
+
+	maxv := uint32(1)<<to - 1
+
+	for _, b := range data {
+		if uint32(b)>>from != 0 {
+			return nil, errInvalidBech32
+		}
+
+		acc = acc<<from | uint32(b)
+		bits += from
+
+		for bits >= to {
+			bits -= to
+			res = append(res, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			res = append(res, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxv != 0 {
+		return nil, errInvalidBech32
+	}
+
+	return res, nil
+}
+
+// bech32Encode encodes data with lowercase hrp
+func bech32Encode(hrp string, data []byte) string {
+	values, _ := convertBits(data, 8, 5, true)
+
+	polymod := bech32Polymod(append(append(bech32HRPExpand(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+
+	for _, v := range values {
+		b.WriteByte(bech32Charset[v])
+	}
+
+	for i := 0; i < 6; i++ {
+		b.WriteByte(bech32Charset[polymod>>uint(5*(5-i))&31])
+	}
+
+	return b.String()
+}
+
+// bech32Decode returns lowercase hrp and data of s, s may be all uppercase
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errInvalidBech32
+	}
+
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errInvalidBech32
+	}
+
+	hrp := s[:pos]
+
+	values := make([]byte, 0, len(s)-pos-1)
+	for _, c := range s[pos+1:] {
+		v := strings.IndexRune(bech32Charset, c)
+		if v < 0 {
+			return "", nil, errInvalidBech32
+		}
+
+		values = append(values, byte(v))
+	}
+
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, errInvalidBech32
+	}
+
+	data, err := convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return hrp, data, nil
+}
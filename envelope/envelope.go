@@ -0,0 +1,277 @@
+// Package envelope implements vault files shared by several recipients. The
+// data is sealed with XChaCha20-Poly1305 under a random data key, the data
+// key is wrapped for every recipient public key, so any of their private
+// keys opens the file.
+//
+// Recipients are age X25519 keys made by age-keygen or OpenSSH ed25519 keys.
+// The key is wrapped the way age does it: X25519 with an ephemeral key, HKDF
+// of the shared secret and ChaCha20-Poly1305, though the file format isn't
+// age one. Recipients are listed in the file, so they can be managed.
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mullakhmetov/clotp/securemem"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	format        = "clotp-shared-vault"
+	version       = 1
+	cipherXChaCha = "xchacha20-poly1305"
+)
+
+var (
+	ErrInvalidVault     = errors.New("invalid shared vault")
+	ErrNoIdentity       = errors.New("none of the identities is a recipient of the vault")
+	ErrRecipientExists  = errors.New("recipient already exists")
+	ErrUnknownRecipient = errors.New("unknown recipient")
+	ErrLastRecipient    = errors.New("the last recipient can't be removed")
+)
+
+// Stanza is the data key wrapped for a recipient
+type Stanza struct {
+	Type      string `json:"type"`
+	Recipient string `json:"recipient"`
+	// Share is the ephemeral public key
+	Share []byte `json:"share"`
+	Key   []byte `json:"key"`
+}
+
+// header is the unencrypted part of the file, it is authenticated as
+// additional data of the ciphertext
+type header struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	Recipients []*Stanza `json:"recipients"`
+	Cipher     string    `json:"cipher"`
+	Nonce      []byte    `json:"nonce"`
+}
+
+type file struct {
+	header
+	Data []byte `json:"data"`
+}
+
+// Envelope is an opened file, it keeps the data key to seal new data
+type Envelope struct {
+	stanzas []*Stanza
+	key     []byte
+}
+
+// New returns envelope with new data key wrapped for the recipients
+func New(recipients []Recipient) (*Envelope, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	e := &Envelope{key: key}
+
+	for _, r := range recipients {
+		if err := e.Add(r); err != nil {
+			e.Wipe()
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// IsVault reports whether data looks like a shared vault file, it isn't
+// validated
+func IsVault(data []byte) bool {
+	var h struct {
+		Format string `json:"format"`
+	}
+
+	return json.Unmarshal(data, &h) == nil && h.Format == format
+}
+
+// Open unwraps the data key of the file with one of identities and returns
+// the envelope and the data, the data should be wiped after use
+func Open(data []byte, identities []Identity) (*Envelope, []byte, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidVault, err)
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	e := &Envelope{stanzas: f.Recipients}
+
+	for _, s := range f.Recipients {
+		for _, id := range identities {
+			key, err := id.unwrap(s)
+			if errors.Is(err, errNoMatch) {
+				continue
+			}
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			e.key = key
+
+			break
+		}
+
+		if e.key != nil {
+			break
+		}
+	}
+
+	if e.key == nil {
+		return nil, nil, ErrNoIdentity
+	}
+
+	aead, err := chacha20poly1305.NewX(e.key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ad, err := json.Marshal(f.header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := aead.Open(nil, f.Nonce, f.Data, ad)
+	if err != nil {
+		e.Wipe()
+		return nil, nil, fmt.Errorf("%w: data is corrupted", ErrInvalidVault)
+	}
+
+	return e, plaintext, nil
+}
+
+// Seal encrypts data with the data key and returns the file content
+func (e *Envelope) Seal(data []byte) ([]byte, error) {
+	h := header{
+		Format:     format,
+		Version:    version,
+		Recipients: e.stanzas,
+		Cipher:     cipherXChaCha,
+		Nonce:      make([]byte, chacha20poly1305.NonceSizeX),
+	}
+
+	if _, err := io.ReadFull(rand.Reader, h.Nonce); err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.NewX(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	ad, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(file{header: h, Data: aead.Seal(nil, h.Nonce, data, ad)}, "", "  ")
+}
+
+// Recipients returns recipients of the data key as ParseRecipient accepts them
+func (e *Envelope) Recipients() []string {
+	res := make([]string, 0, len(e.stanzas))
+	for _, s := range e.stanzas {
+		res = append(res, s.Recipient)
+	}
+
+	return res
+}
+
+// Add wraps the data key for the recipient
+func (e *Envelope) Add(r Recipient) error {
+	for _, s := range e.stanzas {
+		if s.Recipient == r.String() {
+			return fmt.Errorf("%w: %s", ErrRecipientExists, r)
+		}
+	}
+
+	s, err := r.wrap(e.key)
+	if err != nil {
+		return err
+	}
+
+	e.stanzas = append(e.stanzas, s)
+
+	return nil
+}
+
+// Remove removes the recipient and rotates the data key, since the removed
+// recipient knows the old one. Other recipients get the new key wrapped.
+func (e *Envelope) Remove(r Recipient) error {
+	var rest []Recipient
+
+	found := false
+
+	for _, s := range e.stanzas {
+		if s.Recipient == r.String() {
+			found = true
+			continue
+		}
+
+		other, err := ParseRecipient(s.Recipient)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidVault, err)
+		}
+
+		rest = append(rest, other)
+	}
+
+	switch {
+	case !found:
+		return fmt.Errorf("%w: %s", ErrUnknownRecipient, r)
+	case len(rest) == 0:
+		return ErrLastRecipient
+	}
+
+	rotated, err := New(rest)
+	if err != nil {
+		return err
+	}
+
+	e.Wipe()
+	*e = *rotated
+
+	return nil
+}
+
+// Wipe zeroes the data key, the envelope can't be used after that
+func (e *Envelope) Wipe() {
+	securemem.Wipe(e.key)
+}
+
+func (f file) validate() error {
+	switch {
+	case f.Format != format:
+		return fmt.Errorf("%w: not a clotp shared vault", ErrInvalidVault)
+	case f.Version != version:
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidVault, f.Version)
+	case f.Cipher != cipherXChaCha:
+		return fmt.Errorf("%w: unsupported cipher %s", ErrInvalidVault, f.Cipher)
+	case len(f.Nonce) != chacha20poly1305.NonceSizeX:
+		return fmt.Errorf("%w: invalid nonce", ErrInvalidVault)
+	}
+
+	for _, s := range f.Recipients {
+		if len(s.Share) != curve25519.PointSize {
+			return fmt.Errorf("%w: invalid stanza of %s", ErrInvalidVault, s.Recipient)
+		}
+	}
+
+	return nil
+}
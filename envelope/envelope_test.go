@@ -0,0 +1,249 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "correct horse"
+
+func newAgeIdentity(t *testing.T) Identity {
+	t.Helper()
+
+	scalar := make([]byte, 32)
+	if _, err := rand.Read(scalar); err != nil {
+		panic(err)
+	}
+
+	line := strings.ToUpper(bech32Encode(ageIdentityHRP, scalar))
+
+	ids, err := ParseIdentities([]byte("# created: now\n"+line+"\n"), nil)
+	if err != nil {
+		t.Fatalf("failed to parse age identity: %v", err)
+	}
+
+	return ids[0]
+}
+
+func newSSHIdentity(t *testing.T) Identity {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		panic(err)
+	}
+
+	ids, err := ParseIdentities(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil)
+	if err != nil {
+		t.Fatalf("failed to parse SSH identity: %v", err)
+	}
+
+	return ids[0]
+}
+
+func TestBech32(t *testing.T) {
+	data := []byte("shared vault data key")
+
+	for _, s := range []string{bech32Encode("age", data), strings.ToUpper(bech32Encode("age", data))} {
+		hrp, got, err := bech32Decode(s)
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", s, err)
+		}
+
+		if hrp != "age" || !bytes.Equal(got, data) {
+			t.Errorf("wrong decoded %s: %s %q", s, hrp, got)
+		}
+	}
+
+	valid := bech32Encode("age", data)
+	for _, s := range []string{valid[:len(valid)-1] + "q", "Age" + valid[3:], "age1", "age1b"} {
+		if _, _, err := bech32Decode(s); err == nil {
+			t.Errorf("invalid %s is decoded", s)
+		}
+	}
+}
+
+func TestAgeIdentity(t *testing.T) {
+	// age test vector of 0x42 scalar
+	ids, err := ParseIdentities([]byte("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"), nil)
+	if err != nil {
+		t.Fatalf("failed to parse age identity: %v", err)
+	}
+
+	if want := "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"; ids[0].Recipient().String() != want {
+		t.Errorf("wrong recipient, want: %s != got: %s", want, ids[0].Recipient())
+	}
+}
+
+func TestParseRecipient(t *testing.T) {
+	id := newAgeIdentity(t)
+
+	r, err := ParseRecipient(" " + id.Recipient().String() + "\n")
+	if err != nil {
+		t.Fatalf("failed to parse age recipient: %v", err)
+	}
+
+	if r.String() != id.Recipient().String() {
+		t.Errorf("wrong age recipient, want: %s != got: %s", id.Recipient(), r)
+	}
+
+	pub, err := ioutil.ReadFile("testdata/id_ed25519.pub")
+	if err != nil {
+		panic(err)
+	}
+
+	r, err = ParseRecipient(string(pub))
+	if err != nil {
+		t.Fatalf("failed to parse SSH recipient: %v", err)
+	}
+
+	// the comment is dropped
+	if want := strings.Join(strings.Fields(string(pub))[:2], " "); r.String() != want {
+		t.Errorf("wrong SSH recipient, want: %s != got: %s", want, r)
+	}
+
+	for _, s := range []string{"", "age1", "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQ", "ssh-ed25519 AAAA"} {
+		if _, err := ParseRecipient(s); err == nil {
+			t.Errorf("invalid recipient %q is parsed", s)
+		}
+	}
+}
+
+func TestParseSSHIdentity(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/id_ed25519")
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := ParseIdentities(data, nil); err == nil {
+		t.Errorf("protected key is parsed without passphrase")
+	}
+
+	asked := 0
+	ids, err := ParseIdentities(data, func() ([]byte, error) {
+		asked++
+		return []byte(testPassphrase), nil
+	})
+
+	if err != nil {
+		t.Fatalf("failed to parse protected key: %v", err)
+	}
+
+	if asked != 1 {
+		t.Errorf("passphrase is asked %d times", asked)
+	}
+
+	pub, err := ioutil.ReadFile("testdata/id_ed25519.pub")
+	if err != nil {
+		panic(err)
+	}
+
+	if !strings.HasPrefix(string(pub), ids[0].Recipient().String()+" ") {
+		t.Errorf("wrong recipient of the key: %s", ids[0].Recipient())
+	}
+
+	if _, err := ParseIdentities(data, func() ([]byte, error) { return []byte("wrong"), nil }); err == nil {
+		t.Errorf("protected key is parsed with wrong passphrase")
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	alice := newAgeIdentity(t)
+	bob := newSSHIdentity(t)
+	carol := newSSHIdentity(t)
+
+	e, err := New([]Recipient{alice.Recipient(), bob.Recipient()})
+	if err != nil {
+		t.Fatalf("failed to create envelope: %v", err)
+	}
+
+	data := []byte("[github]\nsecret = GE\n")
+
+	sealed, err := e.Seal(data)
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+
+	if bytes.Contains(sealed, data) {
+		t.Errorf("data isn't encrypted")
+	}
+
+	for _, id := range []Identity{alice, bob} {
+		opened, plaintext, err := Open(sealed, []Identity{carol, id})
+		if err != nil {
+			t.Fatalf("failed to open by %s: %v", id.Recipient(), err)
+		}
+
+		if !bytes.Equal(plaintext, data) {
+			t.Errorf("wrong data opened by %s: %q", id.Recipient(), plaintext)
+		}
+
+		if got := opened.Recipients(); len(got) != 2 || got[0] != alice.Recipient().String() || got[1] != bob.Recipient().String() {
+			t.Errorf("wrong recipients: %v", got)
+		}
+	}
+
+	if _, _, err := Open(sealed, []Identity{carol}); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("want: %v != got: %v", ErrNoIdentity, err)
+	}
+
+	tampered := bytes.Replace(sealed, []byte(`"version": 1`), []byte(`"version": 2`), 1)
+	if _, _, err := Open(tampered, []Identity{alice}); !errors.Is(err, ErrInvalidVault) {
+		t.Errorf("want: %v != got: %v", ErrInvalidVault, err)
+	}
+
+	if err := e.Add(carol.Recipient()); err != nil {
+		t.Fatalf("failed to add recipient: %v", err)
+	}
+
+	if err := e.Add(carol.Recipient()); !errors.Is(err, ErrRecipientExists) {
+		t.Errorf("want: %v != got: %v", ErrRecipientExists, err)
+	}
+
+	key := append([]byte(nil), e.key...)
+
+	if err := e.Remove(alice.Recipient()); err != nil {
+		t.Fatalf("failed to remove recipient: %v", err)
+	}
+
+	if bytes.Equal(key, e.key) {
+		t.Errorf("data key isn't rotated")
+	}
+
+	if err := e.Remove(alice.Recipient()); !errors.Is(err, ErrUnknownRecipient) {
+		t.Errorf("want: %v != got: %v", ErrUnknownRecipient, err)
+	}
+
+	sealed, err = e.Seal(data)
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+
+	if _, _, err := Open(sealed, []Identity{alice}); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("removed recipient opens the vault: %v", err)
+	}
+
+	if _, _, err := Open(sealed, []Identity{carol}); err != nil {
+		t.Errorf("failed to open by added recipient: %v", err)
+	}
+
+	if err := e.Remove(bob.Recipient()); err != nil {
+		t.Fatalf("failed to remove recipient: %v", err)
+	}
+
+	if err := e.Remove(carol.Recipient()); !errors.Is(err, ErrLastRecipient) {
+		t.Errorf("want: %v != got: %v", ErrLastRecipient, err)
+	}
+}
@@ -0,0 +1,331 @@
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/mullakhmetov/clotp/securemem"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// TypeX25519 is the type of age X25519 keys, age1... recipients and
+	// AGE-SECRET-KEY-1... identities made by age-keygen
+	TypeX25519 = "X25519"
+	// TypeSSHEd25519 is the type of OpenSSH ed25519 keys, they are converted
+	// to X25519 keys
+	TypeSSHEd25519 = "ssh-ed25519"
+
+	ageRecipientHRP = "age"
+	ageIdentityHRP  = "age-secret-key-"
+
+	// wrapInfo is HKDF info of the key wrapping the data key, it's followed
+	// by the key type
+	wrapInfo = "clotp.shared-vault.v1/"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported key, use age X25519 or SSH ed25519 key")
+
+// errNoMatch is returned by Identity.unwrap if the stanza is wrapped for
+// other recipient
+var errNoMatch = errors.New("stanza isn't wrapped for the identity")
+
+// Recipient is a public key the data key of the vault is wrapped for
+type Recipient interface {
+	// String is the canonical form of the key, ParseRecipient accepts it
+	String() string
+
+	wrap(key []byte) (*Stanza, error)
+}
+
+// Identity is a private key unwrapping the data key of the vault
+type Identity interface {
+	// Recipient returns the public key of the identity
+	Recipient() Recipient
+
+	unwrap(s *Stanza) ([]byte, error)
+}
+
+// ParseRecipient parses age X25519 recipient, e.g. age1..., or OpenSSH
+// ed25519 public key in authorized_keys format
+func ParseRecipient(s string) (Recipient, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, TypeSSHEd25519+" ") {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSH public key: %w", err)
+		}
+
+		pub, ok := key.(ssh.CryptoPublicKey).CryptoPublicKey().(ed25519.PublicKey)
+		if !ok {
+			return nil, ErrUnsupportedKey
+		}
+
+		return newSSHRecipient(pub)
+	}
+
+	hrp, data, err := bech32Decode(s)
+	if err != nil || hrp != ageRecipientHRP || len(data) != curve25519.PointSize {
+		return nil, ErrUnsupportedKey
+	}
+
+	return &x25519Recipient{typ: TypeX25519, public: data, str: bech32Encode(ageRecipientHRP, data)}, nil
+}
+
+// ParseIdentities parses age identity file with AGE-SECRET-KEY-1... lines
+// or OpenSSH ed25519 private key. passphrase is called for the passphrase
+// of protected SSH key.
+func ParseIdentities(data []byte, passphrase func() ([]byte, error)) ([]Identity, error) {
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		id, err := parseSSHIdentity(data, passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Identity{id}, nil
+	}
+
+	var ids []Identity
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hrp, scalar, err := bech32Decode(line)
+		if err != nil || hrp != ageIdentityHRP || len(scalar) != curve25519.ScalarSize {
+			return nil, fmt.Errorf("invalid identity on line %d: %w", n, ErrUnsupportedKey)
+		}
+
+		id, err := newX25519Identity(TypeX25519, scalar, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, errors.New("no identities found")
+	}
+
+	return ids, nil
+}
+
+func parseSSHIdentity(data []byte, passphrase func() ([]byte, error)) (Identity, error) {
+	key, err := ssh.ParseRawPrivateKey(data)
+
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && passphrase != nil {
+		p, perr := passphrase()
+		if perr != nil {
+			return nil, perr
+		}
+
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(data, p)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH private key: %w", err)
+	}
+
+	var priv ed25519.PrivateKey
+
+	switch k := key.(type) {
+	case *ed25519.PrivateKey:
+		priv = *k
+	case ed25519.PrivateKey:
+		priv = k
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	r, err := newSSHRecipient(priv.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	// X25519 scalar of ed25519 key, see RFC 8032 section 5.1.5
+	h := sha512.Sum512(priv.Seed())
+	defer securemem.Wipe(h[:])
+
+	return newX25519Identity(TypeSSHEd25519, h[:curve25519.ScalarSize], r)
+}
+
+func newSSHRecipient(pub ed25519.PublicKey) (*x25519Recipient, error) {
+	sshKey, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := ed25519PublicToX25519(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	str := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshKey)))
+
+	return &x25519Recipient{typ: TypeSSHEd25519, public: public, str: str}, nil
+}
+
+// ed25519PublicToX25519 converts Edwards point to Montgomery one,
+// u = (1 + y) / (1 - y), see RFC 7748 section 4.1
+func ed25519PublicToX25519(pub ed25519.PublicKey) ([]byte, error) {
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+	// y is little-endian with the sign of x in the top bit
+	le := make([]byte, len(pub))
+	copy(le, pub)
+	le[len(le)-1] &= 0x7f
+
+	y := new(big.Int).SetBytes(reverse(le))
+	if y.Cmp(p) >= 0 {
+		return nil, ErrUnsupportedKey
+	}
+
+	one := big.NewInt(1)
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, p)
+
+	if denominator.Sign() == 0 {
+		return nil, ErrUnsupportedKey
+	}
+
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, new(big.Int).ModInverse(denominator, p))
+	u.Mod(u, p)
+
+	res := make([]byte, curve25519.PointSize)
+	b := u.Bytes()
+	copy(res[len(res)-len(b):], b)
+
+	return reverse(res), nil
+}
+
+func reverse(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return b
+}
+
+type x25519Recipient struct {
+	typ    string
+	public []byte
+	str    string
+}
+
+func (r *x25519Recipient) String() string {
+	return r.str
+}
+
+func (r *x25519Recipient) wrap(key []byte) (*Stanza, error) {
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, ephemeral); err != nil {
+		return nil, err
+	}
+
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := curve25519.X25519(ephemeral, r.public)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := wrapAEAD(r.typ, shared, share, r.public)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+
+	return &Stanza{Type: r.typ, Recipient: r.str, Share: share, Key: aead.Seal(nil, nonce, key, nil)}, nil
+}
+
+type x25519Identity struct {
+	scalar    []byte
+	recipient *x25519Recipient
+}
+
+// newX25519Identity returns identity of the scalar, its recipient is derived
+// from the scalar if r is nil
+func newX25519Identity(typ string, scalar []byte, r *x25519Recipient) (*x25519Identity, error) {
+	if r == nil {
+		public, err := curve25519.X25519(scalar, curve25519.Basepoint)
+		if err != nil {
+			return nil, err
+		}
+
+		r = &x25519Recipient{typ: typ, public: public, str: bech32Encode(ageRecipientHRP, public)}
+	}
+
+	return &x25519Identity{scalar: append([]byte(nil), scalar...), recipient: r}, nil
+}
+
+func (i *x25519Identity) Recipient() Recipient {
+	return i.recipient
+}
+
+func (i *x25519Identity) unwrap(s *Stanza) ([]byte, error) {
+	if s.Type != i.recipient.typ || s.Recipient != i.recipient.str {
+		return nil, errNoMatch
+	}
+
+	shared, err := curve25519.X25519(i.scalar, s.Share)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := wrapAEAD(s.Type, shared, s.Share, i.recipient.public)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+
+	key, err := aead.Open(nil, nonce, s.Key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data key of %s can't be unwrapped", ErrInvalidVault, s.Recipient)
+	}
+
+	return key, nil
+}
+
+// wrapAEAD returns cipher wrapping the data key, its key is derived from the
+// shared secret, both public keys are its salt as in age
+func wrapAEAD(typ string, shared, share, public []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(share)+len(public))
+	salt = append(salt, share...)
+	salt = append(salt, public...)
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(wrapInfo+typ)), key); err != nil {
+		return nil, err
+	}
+
+	return chacha20poly1305.New(key)
+}
@@ -0,0 +1,150 @@
+// Package export writes vault items in formats other authenticators can
+// import. Formats can't represent everything clotp items can, such items are
+// reported as warnings.
+package export
+
+import (
+	"crypto/sha1" //nolint:gosec // used for name-based UUIDs only
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+type Format string
+
+const (
+	Aegis     Format = "aegis"
+	AndOTP    Format = "andotp"
+	URIList   Format = "uri-list"
+	Bitwarden Format = "bitwarden"
+	CSV       Format = "csv"
+)
+
+// Formats lists names of supported formats
+var Formats = []string{
+	string(Aegis),
+	string(AndOTP),
+	string(URIList),
+	string(Bitwarden),
+	string(CSV),
+}
+
+// ParseFormat returns format by its name
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if f == s {
+			return Format(s), nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown export format: %s, supported formats: %s", s, strings.Join(Formats, ", "))
+}
+
+// Warning tells which item can't be represented in the format exactly
+type Warning struct {
+	Item    string
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Item + ": " + w.Message
+}
+
+// capabilities is what a format can represent besides name, issuer, secret,
+// algorithm, digits and step. All formats support every vault algorithm.
+type capabilities struct {
+	t0   bool
+	ocra bool
+}
+
+var formats = map[Format]struct {
+	capabilities
+	write func(io.Writer, []entry) error
+}{
+	Aegis:     {capabilities{}, writeAegis},
+	AndOTP:    {capabilities{}, writeAndOTP},
+	URIList:   {capabilities{}, writeURIList},
+	Bitwarden: {capabilities{}, writeBitwarden},
+	CSV:       {capabilities{t0: true, ocra: true}, writeCSV},
+}
+
+// entry is an item with defaults applied
+type entry struct {
+	*vault.Item
+	secret    string
+	algorithm string
+	digits    int
+	step      int
+}
+
+// Write writes items in the format. Items the format can't represent at all,
+// e.g. OCRA ones, are skipped, all such items are returned as warnings.
+func Write(w io.Writer, format Format, items []*vault.Item) ([]Warning, error) {
+	f, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format: %s", format)
+	}
+
+	var warnings []Warning
+
+	entries := make([]entry, 0, len(items))
+	for _, i := range items {
+		if i.Suite != "" && !f.ocra {
+			warnings = append(warnings, Warning{i.Name, fmt.Sprintf("OCRA items aren't supported by %s, skipped", format)})
+			continue
+		}
+
+		e := newEntry(i)
+
+		if i.T0 != 0 && !f.t0 {
+			warnings = append(warnings, Warning{i.Name, fmt.Sprintf("T0 %d isn't supported by %s, exported codes will be wrong", i.T0, format)})
+		}
+
+		entries = append(entries, e)
+	}
+
+	return warnings, f.write(w, entries)
+}
+
+func newEntry(i *vault.Item) entry {
+	e := entry{
+		Item:      i,
+		secret:    strings.ToUpper(strings.TrimRight(string(i.Key), "=")),
+		algorithm: i.Algorithm,
+		digits:    i.Digits,
+		step:      i.Step,
+	}
+
+	if e.algorithm == "" {
+		e.algorithm = vault.DefaultAlgorithm
+	}
+
+	if e.digits == 0 {
+		e.digits = vault.DefaultDigits
+	}
+
+	if e.step == 0 {
+		e.step = vault.DefaultStep
+	}
+
+	return e
+}
+
+// uuidNamespace is a random namespace of name-based item UUIDs
+var uuidNamespace = []byte{0x6f, 0x0e, 0x3b, 0x52, 0x8d, 0x1c, 0x4a, 0x27, 0x9b, 0x3e, 0x51, 0xd2, 0x7a, 0x60, 0xc4, 0x19}
+
+// uuid returns version 5 UUID of the name, see RFC 4122 4.3, so exporting the
+// same vault twice gives the same identifiers
+func uuid(name string) string {
+	h := sha1.New() //nolint:gosec // required by RFC 4122
+	h.Write(uuidNamespace)
+	h.Write([]byte(name))
+
+	u := h.Sum(nil)[:16]
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
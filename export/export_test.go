@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+var testItems = []*vault.Item{
+	{Name: "github", Key: []byte("gezdgnbvgy3tqojq====")},
+	{Name: "bank", Issuer: "Bank", Key: []byte("GE"), Algorithm: "sha256", Digits: 8, Step: 60, T0: 100},
+	{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 42},
+}
+
+func TestWrite_Warnings(t *testing.T) {
+	for _, c := range []struct {
+		format Format
+		want   []Warning
+	}{
+		{
+			format: Aegis,
+			want: []Warning{
+				{"bank", "T0 100 isn't supported by aegis, exported codes will be wrong"},
+				{"vpn", "OCRA items aren't supported by aegis, skipped"},
+			},
+		},
+		{
+			format: URIList,
+			want: []Warning{
+				{"bank", "T0 100 isn't supported by uri-list, exported codes will be wrong"},
+				{"vpn", "OCRA items aren't supported by uri-list, skipped"},
+			},
+		},
+		{
+			format: CSV,
+		},
+	} {
+		c := c
+		t.Run(string(c.format), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			warnings, err := Write(&buf, c.format, testItems)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+
+			if !reflect.DeepEqual(warnings, c.want) {
+				t.Errorf("wrong warnings, want: %v != got: %v", c.want, warnings)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	for _, c := range []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: URIList,
+			want: "otpauth://totp/github?secret=GEZDGNBVGY3TQOJQ\n" +
+				"otpauth://totp/Bank:bank?algorithm=SHA256&digits=8&issuer=Bank&period=60&secret=GE\n",
+		},
+		{
+			format: CSV,
+			want: "name,issuer,secret,algorithm,digits,period,t0,ocra,counter\n" +
+				"github,,GEZDGNBVGY3TQOJQ,sha1,6,30,0,,0\n" +
+				"bank,Bank,GE,sha256,8,60,100,,0\n" +
+				"vpn,,GE,sha1,6,30,0,OCRA-1:HOTP-SHA1-6:QN08,42\n",
+		},
+	} {
+		c := c
+		t.Run(string(c.format), func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if _, err := Write(&buf, c.format, testItems); err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+
+			if buf.String() != c.want {
+				t.Errorf("wrong output, want:\n%s\ngot:\n%s", c.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range Formats {
+		if _, err := ParseFormat(f); err != nil {
+			t.Errorf("unwanted error for %s: %v", f, err)
+		}
+	}
+
+	if _, err := ParseFormat("keepass"); err == nil {
+		t.Error("error shouldn't be nil")
+	}
+}
+
+func TestUUID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	u := uuid("github")
+	if !re.MatchString(u) {
+		t.Errorf("invalid version 5 UUID: %s", u)
+	}
+
+	if uuid("github") != u {
+		t.Error("UUID should be stable")
+	}
+
+	if uuid("gitlab") == u {
+		t.Error("UUIDs of different names should differ")
+	}
+}
@@ -0,0 +1,186 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// aegis is unencrypted Aegis Authenticator vault,
+// see https://github.com/beemdevelopment/Aegis/blob/master/docs/vault.md
+type aegis struct {
+	Version int         `json:"version"`
+	Header  aegisHeader `json:"header"`
+	DB      aegisDB     `json:"db"`
+}
+
+type aegisHeader struct {
+	Slots  interface{} `json:"slots"`
+	Params interface{} `json:"params"`
+}
+
+type aegisDB struct {
+	Version int          `json:"version"`
+	Entries []aegisEntry `json:"entries"`
+}
+
+type aegisEntry struct {
+	Type   string      `json:"type"`
+	UUID   string      `json:"uuid"`
+	Name   string      `json:"name"`
+	Issuer string      `json:"issuer"`
+	Note   string      `json:"note"`
+	Icon   interface{} `json:"icon"`
+	Info   aegisInfo   `json:"info"`
+}
+
+type aegisInfo struct {
+	Secret string `json:"secret"`
+	Algo   string `json:"algo"`
+	Digits int    `json:"digits"`
+	Period int    `json:"period"`
+}
+
+func writeAegis(w io.Writer, entries []entry) error {
+	v := aegis{Version: 1, DB: aegisDB{Version: 2, Entries: make([]aegisEntry, 0, len(entries))}}
+	for _, e := range entries {
+		v.DB.Entries = append(v.DB.Entries, aegisEntry{
+			Type:   "totp",
+			UUID:   uuid(e.Name),
+			Name:   e.Name,
+			Issuer: e.Issuer,
+			Info: aegisInfo{
+				Secret: e.secret,
+				Algo:   strings.ToUpper(e.algorithm),
+				Digits: e.digits,
+				Period: e.step,
+			},
+		})
+	}
+
+	return writeJSON(w, v)
+}
+
+// andOTPEntry is an entry of unencrypted andOTP backup
+type andOTPEntry struct {
+	Secret    string   `json:"secret"`
+	Issuer    string   `json:"issuer"`
+	Label     string   `json:"label"`
+	Digits    int      `json:"digits"`
+	Type      string   `json:"type"`
+	Algorithm string   `json:"algorithm"`
+	Thumbnail string   `json:"thumbnail"`
+	Period    int      `json:"period"`
+	Tags      []string `json:"tags"`
+}
+
+func writeAndOTP(w io.Writer, entries []entry) error {
+	v := make([]andOTPEntry, 0, len(entries))
+	for _, e := range entries {
+		v = append(v, andOTPEntry{
+			Secret:    e.secret,
+			Issuer:    e.Issuer,
+			Label:     e.Name,
+			Digits:    e.digits,
+			Type:      "TOTP",
+			Algorithm: strings.ToUpper(e.algorithm),
+			Thumbnail: "Default",
+			Period:    e.step,
+			Tags:      []string{},
+		})
+	}
+
+	return writeJSON(w, v)
+}
+
+func writeURIList(w io.Writer, entries []entry) error {
+	for _, e := range entries {
+		if _, err := fmt.Fprintln(w, e.URI()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bitwarden is unencrypted Bitwarden JSON export with a login item per entry
+type bitwarden struct {
+	Encrypted bool            `json:"encrypted"`
+	Folders   []interface{}   `json:"folders"`
+	Items     []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	ID       string         `json:"id"`
+	Type     int            `json:"type"`
+	Name     string         `json:"name"`
+	Favorite bool           `json:"favorite"`
+	Login    bitwardenLogin `json:"login"`
+}
+
+type bitwardenLogin struct {
+	URIs []interface{} `json:"uris"`
+	TOTP string        `json:"totp"`
+}
+
+const bitwardenLoginType = 1
+
+func writeBitwarden(w io.Writer, entries []entry) error {
+	v := bitwarden{Folders: []interface{}{}, Items: make([]bitwardenItem, 0, len(entries))}
+	for _, e := range entries {
+		name := e.Name
+		if e.Issuer != "" {
+			name = e.Issuer + " (" + e.Name + ")"
+		}
+
+		v.Items = append(v.Items, bitwardenItem{
+			ID:    uuid(e.Name),
+			Type:  bitwardenLoginType,
+			Name:  name,
+			Login: bitwardenLogin{URIs: []interface{}{}, TOTP: e.URI()},
+		})
+	}
+
+	return writeJSON(w, v)
+}
+
+var csvHeader = []string{"name", "issuer", "secret", "algorithm", "digits", "period", "t0", "ocra", "counter"}
+
+func writeCSV(w io.Writer, entries []entry) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := cw.Write([]string{
+			e.Name,
+			e.Issuer,
+			e.secret,
+			e.algorithm,
+			strconv.Itoa(e.digits),
+			strconv.Itoa(e.step),
+			strconv.FormatInt(e.T0, 10),
+			e.Suite,
+			strconv.FormatUint(e.Counter, 10),
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(v)
+}
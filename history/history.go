@@ -0,0 +1,314 @@
+// Package history keeps the vault file in a git repository: vault changes
+// are committed, may be listed and reverted, and synced with the upstream of
+// the repository. It runs git binary.
+package history
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGit is git binary used if Opts.Git is empty
+const DefaultGit = "git"
+
+// MergeFunc merges base, ours and theirs versions of a file, the result is
+// written to ours file, the way git merge drivers work
+type MergeFunc func(base, ours, theirs string) error
+
+// Opts are repository options
+type Opts struct {
+	// Git is git binary, DefaultGit if empty
+	Git string
+}
+
+// Commit is a commit changing the file
+type Commit struct {
+	Hash    string
+	Time    time.Time
+	Author  string
+	Subject string
+}
+
+// IsRepository reports whether dir is the top directory of a git repository,
+// repositories of parent directories, e.g. of dotfiles, don't count
+func IsRepository(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// Open returns repository in dir keeping the file of given name, the
+// repository should already exist, see IsRepository
+func Open(dir, file string, opts Opts) *Repo {
+	if opts.Git == "" {
+		opts.Git = DefaultGit
+	}
+
+	return &Repo{dir: dir, file: file, opts: opts}
+}
+
+type Repo struct {
+	dir  string
+	file string
+	opts Opts
+}
+
+// Commit commits the file with given message if it has changed, other
+// changes of the repository aren't committed
+func (r *Repo) Commit(message string) error {
+	if _, err := r.git("add", "--", r.file); err != nil {
+		return err
+	}
+
+	if r.clean() {
+		return nil
+	}
+
+	_, err := r.git("commit", "--quiet", "--message", message, "--", r.file)
+
+	return err
+}
+
+// Log returns commits changing the file, the latest first
+func (r *Repo) Log() ([]Commit, error) {
+	if !r.hasCommits() {
+		return nil, nil
+	}
+
+	out, err := r.git("log", "--format=%h%x09%ct%x09%an%x09%s", "--", r.file)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git log line: %s", line)
+		}
+
+		ts, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git log line: %s", line)
+		}
+
+		commits = append(commits, Commit{Hash: fields[0], Time: time.Unix(ts, 0), Author: fields[2], Subject: fields[3]})
+	}
+
+	return commits, nil
+}
+
+// Revert commits changes of the file undoing the commit, other files the
+// commit has changed are left as they are. Revert is aborted if it conflicts
+// with later changes of the file.
+func (r *Repo) Revert(rev string) error {
+	out, err := r.git("log", "-1", "--format=%H%x09%s", rev, "--")
+	if err != nil {
+		return err
+	}
+
+	commit := strings.SplitN(strings.TrimSpace(string(out)), "\t", 2)
+	if len(commit) != 2 {
+		return fmt.Errorf("unexpected git log line: %s", out)
+	}
+
+	patch, err := r.git("show", "--format=", "--binary", "--no-color", "--no-ext-diff", commit[0], "--", r.file)
+	if err != nil {
+		return err
+	}
+
+	if len(patch) == 0 {
+		return fmt.Errorf("%s doesn't change %s", rev, r.file)
+	}
+
+	dir, err := ioutil.TempDir("", "clotp-revert")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "patch")
+	if err := ioutil.WriteFile(path, patch, 0600); err != nil {
+		return err
+	}
+
+	if _, err := r.git("apply", "--reverse", "--3way", "--index", path); err != nil {
+		// conflicting file is left unmerged, it's restored as committed
+		if conflicts, cerr := r.conflicts(); cerr == nil && len(conflicts) > 0 {
+			_, _ = r.git("checkout", "HEAD", "--", r.file)
+		}
+
+		return err
+	}
+
+	return r.Commit(fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", commit[1], commit[0]))
+}
+
+// Sync rebases local commits onto the upstream, i.e. pulls with rebase, and
+// pushes them. Conflicts in the file are resolved by merge, the rebase is
+// aborted if merge fails or other files conflict.
+func (r *Repo) Sync(merge MergeFunc) error {
+	// the vault of a fresh clone would keep the upstream from being checked
+	// out, it's merged with the upstream one when committed
+	if _, err := os.Stat(filepath.Join(r.dir, r.file)); err == nil && !r.hasCommits() {
+		if err := r.Commit("Add vault"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.git("fetch", "--quiet"); err != nil {
+		return err
+	}
+
+	// the upstream branch doesn't exist until the first push
+	var err error
+	if _, uerr := r.git("rev-parse", "--verify", "--quiet", "@{upstream}"); uerr == nil {
+		if r.hasCommits() {
+			_, err = r.git("rebase", "--quiet", "@{upstream}")
+		} else {
+			// there is nothing to rebase in a fresh clone
+			_, err = r.git("merge", "--quiet", "--ff-only", "@{upstream}")
+		}
+	}
+
+	for err != nil {
+		conflicts, cerr := r.conflicts()
+		if cerr != nil || len(conflicts) == 0 {
+			// nothing to resolve, e.g. uncommitted changes are in the way
+			if r.rebasing() {
+				_, _ = r.git("rebase", "--abort")
+			}
+
+			return err
+		}
+
+		if err := r.resolve(conflicts, merge); err != nil {
+			_, _ = r.git("rebase", "--abort")
+			return err
+		}
+
+		// merged changes may be already upstream
+		if r.clean() {
+			_, err = r.git("rebase", "--skip")
+		} else {
+			_, err = r.git("rebase", "--continue")
+		}
+	}
+
+	_, err = r.git("push", "--quiet")
+
+	return err
+}
+
+// resolve merges the file, the only conflicting file allowed
+func (r *Repo) resolve(conflicts []string, merge MergeFunc) error {
+	if len(conflicts) != 1 || conflicts[0] != r.file {
+		return fmt.Errorf("can't resolve conflicts in %s", strings.Join(conflicts, ", "))
+	}
+
+	dir, err := ioutil.TempDir("", "clotp-merge")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// stages of the conflicting file: 1 is the base, 2 is the upstream and
+	// 3 is the local commit being rebased
+	paths := make([]string, 3)
+
+	for n, name := range []string{"base", "ours", "theirs"} {
+		// the stage is missing if the file was added on both sides
+		content, _ := r.git("show", fmt.Sprintf(":%d:%s", n+1, r.file))
+
+		paths[n] = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(paths[n], content, 0600); err != nil {
+			return err
+		}
+	}
+
+	if err := merge(paths[0], paths[1], paths[2]); err != nil {
+		return err
+	}
+
+	merged, err := ioutil.ReadFile(paths[1])
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(r.dir, r.file), merged, 0600); err != nil {
+		return err
+	}
+
+	_, err = r.git("add", "--", r.file)
+
+	return err
+}
+
+// conflicts returns unmerged files
+func (r *Repo) conflicts() ([]string, error) {
+	out, err := r.git("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(out)), nil
+}
+
+func (r *Repo) hasCommits() bool {
+	_, err := r.git("rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
+
+// clean reports whether the index matches HEAD, i.e. there is nothing to commit
+func (r *Repo) clean() bool {
+	_, err := r.git("diff", "--cached", "--quiet")
+	return err == nil
+}
+
+func (r *Repo) rebasing() bool {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		out, err := r.git("rev-parse", "--git-path", dir)
+		if err != nil {
+			continue
+		}
+
+		path := strings.TrimSpace(string(out))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.dir, path)
+		}
+
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Repo) git(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(r.opts.Git, args...)
+	cmd.Dir = r.dir
+	// rebase --continue must not open an editor
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), fmt.Errorf("%s %s: %w: %s", r.opts.Git, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,356 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testFile = "config.ini"
+
+// gitEnv makes commits of the tests reproducible
+var gitEnv = map[string]string{
+	"GIT_AUTHOR_NAME":     "Alice",
+	"GIT_AUTHOR_EMAIL":    "alice@example.com",
+	"GIT_AUTHOR_DATE":     "1111111109 +0000",
+	"GIT_COMMITTER_NAME":  "Alice",
+	"GIT_COMMITTER_EMAIL": "alice@example.com",
+	"GIT_COMMITTER_DATE":  "1111111109 +0000",
+}
+
+// setGitEnv sets gitEnv variables and returns function restoring them
+func setGitEnv(t *testing.T) func() {
+	if _, err := exec.LookPath(DefaultGit); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	prev := make(map[string]*string)
+
+	for k, v := range gitEnv {
+		if p, ok := os.LookupEnv(k); ok {
+			prev[k] = &p
+		} else {
+			prev[k] = nil
+		}
+
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		for k, p := range prev {
+			if p == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *p)
+			}
+		}
+	}
+}
+
+func git(dir string, args ...string) {
+	cmd := exec.Command(DefaultGit, args...)
+	cmd.Dir = dir
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		panic(string(out))
+	}
+}
+
+func newRepo() (*Repo, string) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		panic(err)
+	}
+
+	git(dir, "init", "--quiet")
+
+	return Open(dir, testFile, Opts{}), dir
+}
+
+func writeFile(dir, content string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, testFile), []byte(content), 0600); err != nil {
+		panic(err)
+	}
+}
+
+func readFile(dir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, testFile))
+	if err != nil {
+		panic(err)
+	}
+
+	return string(content)
+}
+
+func subjects(t *testing.T, r *Repo) []string {
+	commits, err := r.Log()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	var res []string
+	for _, c := range commits {
+		res = append(res, c.Subject)
+	}
+
+	return res
+}
+
+func TestRepo(t *testing.T) {
+	defer setGitEnv(t)()
+
+	r, dir := newRepo()
+	defer os.RemoveAll(dir)
+
+	if !IsRepository(dir) {
+		t.Error("directory should be a repository")
+	}
+
+	if commits, err := r.Log(); err != nil || len(commits) != 0 {
+		t.Errorf("log of empty repository should be empty, got: %v, %v", commits, err)
+	}
+
+	writeFile(dir, "a\n")
+	if err := r.Commit("Add a"); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	// other files aren't committed
+	if err := ioutil.WriteFile(filepath.Join(dir, "audit.log"), []byte("event\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	writeFile(dir, "a\nb\n")
+	if err := r.Commit("Add b"); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	// unchanged file makes no commit
+	if err := r.Commit("Nothing"); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	commits, err := r.Log()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if len(commits) != 2 || commits[0].Subject != "Add b" || commits[0].Author != "Alice" || commits[0].Time.Unix() != 1111111109 {
+		t.Fatalf("wrong commits: %+v", commits)
+	}
+
+	if err := r.Revert(commits[0].Hash); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if got := readFile(dir); got != "a\n" {
+		t.Errorf("wrong reverted file: %q", got)
+	}
+
+	if got := subjects(t, r); got[0] != `Revert "Add b"` {
+		t.Errorf("wrong revert commit: %v", got)
+	}
+
+	if err := r.Revert("unknown"); err == nil {
+		t.Error("error shouldn't be nil for unknown revision")
+	}
+
+	if IsRepository(filepath.Join(dir, "sub")) {
+		t.Error("subdirectory shouldn't be a repository")
+	}
+}
+
+func TestRepo_Revert(t *testing.T) {
+	defer setGitEnv(t)()
+
+	r, dir := newRepo()
+	defer os.RemoveAll(dir)
+
+	writeFile(dir, "1\n2\n3\n4\n5\n6\n7\n8\n")
+	if err := r.Commit("Add lines"); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	// the commit changes other files of the repository too
+	writeFile(dir, "1\nb\n3\n4\n5\n6\n7\n8\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes"), []byte("b\n"), 0600); err != nil {
+		panic(err)
+	}
+	git(dir, "add", "--all")
+	git(dir, "commit", "--quiet", "--message", "Set b")
+
+	writeFile(dir, "1\nb\n3\n4\n5\n6\ng\n8\n")
+	if err := r.Commit("Set g"); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	commits, err := r.Log()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if err := r.Revert(commits[1].Hash); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if got := readFile(dir); got != "1\n2\n3\n4\n5\n6\ng\n8\n" {
+		t.Errorf("wrong reverted file: %q", got)
+	}
+
+	if notes, _ := ioutil.ReadFile(filepath.Join(dir, "notes")); string(notes) != "b\n" {
+		t.Errorf("other files shouldn't be reverted, got: %q", notes)
+	}
+
+	if got := subjects(t, r); got[0] != `Revert "Set b"` {
+		t.Errorf("wrong revert commit: %v", got)
+	}
+
+	// f is set next to g, so g can't be reverted
+	writeFile(dir, "1\n2\n3\n4\n5\nf\ng\n8\n")
+	if err := r.Commit("Set f"); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if err := r.Revert(commits[0].Hash); err == nil {
+		t.Error("error shouldn't be nil for conflicting revert")
+	}
+
+	if conflicts, _ := r.conflicts(); len(conflicts) != 0 || !r.clean() {
+		t.Errorf("conflicting revert should be aborted, conflicts: %v", conflicts)
+	}
+
+	if got := readFile(dir); got != "1\n2\n3\n4\n5\nf\ng\n8\n" {
+		t.Errorf("conflicting revert should restore the file, got: %q", got)
+	}
+
+	if got := subjects(t, r); got[0] != "Set f" {
+		t.Errorf("conflicting revert shouldn't be committed: %v", got)
+	}
+}
+
+// unionMerge writes sorted lines of both sides
+func unionMerge(base, ours, theirs string) error {
+	lines := make(map[string]struct{})
+
+	for _, path := range []string{ours, theirs} {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range strings.Fields(string(content)) {
+			lines[l] = struct{}{}
+		}
+	}
+
+	res := make([]string, 0, len(lines))
+	for l := range lines {
+		res = append(res, l)
+	}
+
+	sort.Strings(res)
+
+	return ioutil.WriteFile(ours, []byte(strings.Join(res, "\n")+"\n"), 0600)
+}
+
+func TestRepo_Sync(t *testing.T) {
+	defer setGitEnv(t)()
+
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	remote := filepath.Join(dir, "remote.git")
+	git(dir, "init", "--quiet", "--bare", remote)
+
+	clones := make([]*Repo, 2)
+	for n, name := range []string{"alice", "bob"} {
+		git(dir, "clone", "--quiet", remote, name)
+		clones[n] = Open(filepath.Join(dir, name), testFile, Opts{})
+	}
+
+	alice, bob := clones[0], clones[1]
+
+	writeFile(alice.dir, "a\n")
+	if err := alice.Commit("Add a"); err != nil {
+		panic(err)
+	}
+
+	if err := alice.Sync(unionMerge); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if err := bob.Sync(unionMerge); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	// both sides change the same line
+	writeFile(alice.dir, "a\nb\n")
+	if err := alice.Commit("Add b"); err != nil {
+		panic(err)
+	}
+
+	writeFile(bob.dir, "a\nc\n")
+	if err := bob.Commit("Add c"); err != nil {
+		panic(err)
+	}
+
+	if err := alice.Sync(unionMerge); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if err := bob.Sync(unionMerge); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if got := readFile(bob.dir); got != "a\nb\nc\n" {
+		t.Errorf("wrong merged file: %q", got)
+	}
+
+	if err := alice.Sync(unionMerge); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if got := readFile(alice.dir); got != "a\nb\nc\n" {
+		t.Errorf("merged file should be pulled: %q", got)
+	}
+
+	want := "Add c, Add b, Add a"
+	if got := strings.Join(subjects(t, alice), ", "); got != want {
+		t.Errorf("wrong history, want: %s != got: %s", want, got)
+	}
+
+	// failed merge keeps local commits unsynced
+	writeFile(alice.dir, "d\n")
+	if err := alice.Commit("Replace with d"); err != nil {
+		panic(err)
+	}
+
+	writeFile(bob.dir, "e\n")
+	if err := bob.Commit("Replace with e"); err != nil {
+		panic(err)
+	}
+
+	if err := bob.Sync(unionMerge); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	failing := func(base, ours, theirs string) error { return os.ErrInvalid }
+	if err := alice.Sync(failing); err != os.ErrInvalid {
+		t.Errorf("merge error should be returned, got: %v", err)
+	}
+
+	if got := readFile(alice.dir); got != "d\n" {
+		t.Errorf("local file should be kept after failed sync: %q", got)
+	}
+
+	if alice.rebasing() {
+		t.Error("rebase should be aborted")
+	}
+}
@@ -0,0 +1,272 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+	"golang.org/x/crypto/twofish"
+)
+
+var (
+	cipherAES256   = []byte{0x31, 0xc1, 0xf2, 0xe6, 0xbf, 0x71, 0x43, 0x50, 0xbe, 0x58, 0x05, 0x21, 0x6a, 0xfc, 0x5a, 0xff}
+	cipherChaCha20 = []byte{0xd6, 0x03, 0x8a, 0x2b, 0x8b, 0x6f, 0x4c, 0xb5, 0xa5, 0x24, 0x33, 0x9a, 0x31, 0xdb, 0xb5, 0x9a}
+	cipherTwofish  = []byte{0xad, 0x68, 0xf2, 0x9f, 0x57, 0x6f, 0x4b, 0xb9, 0xa3, 0x6a, 0xd4, 0x7a, 0xf9, 0x65, 0x34, 0x6c}
+)
+
+const (
+	blockSize = 1 << 20
+
+	// inner random stream ids
+	streamSalsa20  = 2
+	streamChaCha20 = 3
+)
+
+// hmacBlockIndexHeader is the block index of the header HMAC key
+const hmacBlockIndexHeader = ^uint64(0)
+
+// keys are keys derived for a master seed
+type keys struct {
+	cipher []byte
+	hmac   []byte
+}
+
+func deriveKeys(masterSeed, transformed []byte) keys {
+	c := sha256.New()
+	c.Write(masterSeed)
+	c.Write(transformed)
+
+	h := sha512.New()
+	h.Write(masterSeed)
+	h.Write(transformed)
+	h.Write([]byte{1})
+
+	return keys{cipher: c.Sum(nil), hmac: h.Sum(nil)}
+}
+
+// blockMAC returns HMAC of the data block, it authenticates the block index
+// and size besides the data
+func (k keys) blockMAC(index uint64, data []byte) []byte {
+	mac := hmac.New(sha256.New, k.blockKey(index))
+	_ = binary.Write(mac, binary.LittleEndian, index)
+	_ = binary.Write(mac, binary.LittleEndian, uint32(len(data)))
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// headerMAC returns HMAC of the raw header, only the key is derived for the
+// header block index, the index isn't authenticated unlike in data blocks
+func (k keys) headerMAC(raw []byte) []byte {
+	mac := hmac.New(sha256.New, k.blockKey(hmacBlockIndexHeader))
+	mac.Write(raw)
+
+	return mac.Sum(nil)
+}
+
+func (k keys) blockKey(index uint64) []byte {
+	h := sha512.New()
+	_ = binary.Write(h, binary.LittleEndian, index)
+	h.Write(k.hmac)
+
+	return h.Sum(nil)
+}
+
+// readBlocks reads HMAC-authenticated block stream
+func (k keys) readBlocks(r io.Reader) ([]byte, error) {
+	var out bytes.Buffer
+
+	for index := uint64(0); ; index++ {
+		mac := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(r, mac); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		if size > maxFieldSize {
+			return nil, fmt.Errorf("%w: block is too large", ErrInvalidFile)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		if !hmac.Equal(mac, k.blockMAC(index, data)) {
+			return nil, fmt.Errorf("%w: block %d is corrupted", ErrInvalidFile, index)
+		}
+
+		if size == 0 {
+			return out.Bytes(), nil
+		}
+
+		out.Write(data)
+	}
+}
+
+func (k keys) writeBlocks(w io.Writer, data []byte) error {
+	for index := uint64(0); ; index++ {
+		n := len(data)
+		if n > blockSize {
+			n = blockSize
+		}
+
+		if _, err := w.Write(k.blockMAC(index, data[:n])); err != nil {
+			return err
+		}
+
+		if err := binary.Write(w, binary.LittleEndian, uint32(n)); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(data[:n]); err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return nil
+		}
+
+		data = data[n:]
+	}
+}
+
+func ivSize(cipherID []byte) (int, error) {
+	switch {
+	case bytes.Equal(cipherID, cipherAES256), bytes.Equal(cipherID, cipherTwofish):
+		return 16, nil
+	case bytes.Equal(cipherID, cipherChaCha20):
+		return chacha20.NonceSize, nil
+	default:
+		return 0, fmt.Errorf("%w: cipher %x", ErrUnsupported, cipherID)
+	}
+}
+
+func blockCipher(cipherID, key []byte) (cipher.Block, error) {
+	if bytes.Equal(cipherID, cipherTwofish) {
+		return twofish.NewCipher(key)
+	}
+
+	return aes.NewCipher(key)
+}
+
+func decrypt(cipherID, key, iv, data []byte) ([]byte, error) {
+	if n, err := ivSize(cipherID); err != nil {
+		return nil, err
+	} else if len(iv) != n {
+		return nil, fmt.Errorf("%w: invalid IV", ErrInvalidFile)
+	}
+
+	if bytes.Equal(cipherID, cipherChaCha20) {
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+
+		return out, nil
+	}
+
+	b, err := blockCipher(cipherID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 || len(data)%b.BlockSize() != 0 {
+		return nil, fmt.Errorf("%w: invalid ciphertext size", ErrInvalidFile)
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(b, iv).CryptBlocks(out, data)
+
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > b.BlockSize() {
+		return nil, fmt.Errorf("%w: invalid padding", ErrInvalidFile)
+	}
+
+	return out[:len(out)-pad], nil
+}
+
+func encrypt(cipherID, key, iv, data []byte) ([]byte, error) {
+	if bytes.Equal(cipherID, cipherChaCha20) {
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+
+		return out, nil
+	}
+
+	b, err := blockCipher(cipherID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	pad := b.BlockSize() - len(data)%b.BlockSize()
+	out := append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(b, iv).CryptBlocks(out, out)
+
+	return out, nil
+}
+
+// innerStream is the key stream protected values are XORed with
+type innerStream interface {
+	XORKeyStream(dst, src []byte)
+}
+
+// salsa20Nonce is the fixed nonce of Salsa20 inner stream
+var salsa20Nonce = []byte{0xe8, 0x30, 0x09, 0x4b, 0x97, 0x20, 0x5d, 0x2a}
+
+func newInnerStream(id uint32, key []byte) (innerStream, error) {
+	switch id {
+	case streamChaCha20:
+		h := sha512.Sum512(key)
+		return chacha20.NewUnauthenticatedCipher(h[:32], h[32:44])
+	case streamSalsa20:
+		h := sha256.Sum256(key)
+		return &salsaStream{key: h}, nil
+	default:
+		return nil, fmt.Errorf("%w: inner stream %d", ErrUnsupported, id)
+	}
+}
+
+// salsaStream is continuous Salsa20 key stream generated block by block
+type salsaStream struct {
+	key     [32]byte
+	counter uint64
+	block   []byte
+}
+
+func (s *salsaStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if len(s.block) == 0 {
+			var in [16]byte
+			copy(in[:8], salsa20Nonce)
+			binary.LittleEndian.PutUint64(in[8:], s.counter)
+
+			s.block = make([]byte, 64)
+			salsa.XORKeyStream(s.block, s.block, &in, &s.key)
+			s.counter++
+		}
+
+		dst[i] = src[i] ^ s.block[0]
+		s.block = s.block[1:]
+	}
+}
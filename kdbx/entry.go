@@ -0,0 +1,280 @@
+package kdbx
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"time"
+)
+
+const (
+	// TitleKey is the entry title string field
+	TitleKey = "Title"
+
+	// kdbxEpoch is 0001-01-01 in Unix seconds, KDBX 4 times are seconds since it
+	kdbxEpoch = -62135596800
+)
+
+// timeNow is replaced in tests
+var timeNow = time.Now
+
+// Entry is a database entry outside of the recycle bin
+type Entry struct {
+	// Groups are names of the groups the entry is in, the root group excluded
+	Groups []string
+
+	node  *node
+	group *node
+}
+
+// Entries returns entries of all groups except the recycle bin in document
+// order, history entries aren't included
+func (db *Database) Entries() []*Entry {
+	root := db.root.path("Root", "Group")
+	if root == nil {
+		return nil
+	}
+
+	var (
+		entries []*Entry
+		walk    func(g *node, groups []string)
+	)
+
+	recycleBin := db.recycleBin()
+
+	walk = func(g *node, groups []string) {
+		for _, e := range g.children("Entry") {
+			entries = append(entries, &Entry{Groups: groups, node: e, group: g})
+		}
+
+		for _, sub := range g.children("Group") {
+			if recycleBin != "" && textOf(sub.child("UUID")) == recycleBin {
+				continue
+			}
+
+			walk(sub, append(append([]string(nil), groups...), textOf(sub.child("Name"))))
+		}
+	}
+
+	walk(root, nil)
+
+	return entries
+}
+
+// AddEntry adds entry with the title to the groups path, missing groups are created
+func (db *Database) AddEntry(groups []string, title string) (*Entry, error) {
+	g := db.root.path("Root", "Group")
+	if g == nil {
+		return nil, ErrInvalidFile
+	}
+
+	now := formatTime(timeNow())
+
+	for _, name := range groups {
+		var found *node
+
+		for _, sub := range g.children("Group") {
+			if textOf(sub.child("Name")) == name {
+				found = sub
+				break
+			}
+		}
+
+		if found == nil {
+			found = newNode("Group", "",
+				newNode("UUID", newUUID()),
+				newNode("Name", name),
+				newTimes(now),
+				newNode("IsExpanded", "True"),
+			)
+			insertBeforeGroups(g, found, true)
+		}
+
+		g = found
+	}
+
+	n := newNode("Entry", "",
+		newNode("UUID", newUUID()),
+		newTimes(now),
+	)
+
+	insertBeforeGroups(g, n, false)
+
+	e := &Entry{Groups: groups, node: n, group: g}
+	e.Set(TitleKey, title, false)
+
+	return e, nil
+}
+
+// RemoveEntry removes the entry and records its deletion, so other copies
+// of the database forget it on synchronization
+func (db *Database) RemoveEntry(e *Entry) {
+	e.group.remove(e.node)
+
+	root := db.root.child("Root")
+
+	deleted := root.child("DeletedObjects")
+	if deleted == nil {
+		deleted = newNode("DeletedObjects", "")
+		root.Nodes = append(root.Nodes, deleted)
+	}
+
+	deleted.Nodes = append(deleted.Nodes, newNode("DeletedObject", "",
+		newNode("UUID", textOf(e.node.child("UUID"))),
+		newNode("DeletionTime", formatTime(timeNow())),
+	))
+}
+
+func (db *Database) recycleBin() string {
+	meta := db.root.child("Meta")
+	if meta == nil || textOf(meta.child("RecycleBinEnabled")) != "True" {
+		return ""
+	}
+
+	return textOf(meta.child("RecycleBinUUID"))
+}
+
+// Title returns the entry title
+func (e *Entry) Title() string {
+	v, _ := e.Get(TitleKey)
+	return v
+}
+
+// Keys returns names of the string fields
+func (e *Entry) Keys() []string {
+	var keys []string
+
+	for _, s := range e.node.children("String") {
+		keys = append(keys, textOf(s.child("Key")))
+	}
+
+	return keys
+}
+
+// Get returns value of the string field
+func (e *Entry) Get(key string) (string, bool) {
+	if s := e.field(key); s != nil {
+		return textOf(s.child("Value")), true
+	}
+
+	return "", false
+}
+
+// Set sets value of the string field and updates modification time
+func (e *Entry) Set(key, value string, protected bool) {
+	s := e.field(key)
+	if s == nil {
+		s = newNode("String", "", newNode("Key", key), newNode("Value", ""))
+		insertAfterLast(e.node, s, "String", "Times")
+	}
+
+	v := s.child("Value")
+	if v == nil {
+		v = newNode("Value", "")
+		s.Nodes = append(s.Nodes, v)
+	}
+
+	v.Text = value
+
+	if protected {
+		v.setAttr("Protected", "True")
+	} else {
+		v.removeAttr("Protected")
+	}
+
+	e.touch()
+}
+
+// Delete deletes the string field
+func (e *Entry) Delete(key string) {
+	if s := e.field(key); s != nil {
+		e.node.remove(s)
+		e.touch()
+	}
+}
+
+func (e *Entry) field(key string) *node {
+	for _, s := range e.node.children("String") {
+		if textOf(s.child("Key")) == key {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (e *Entry) touch() {
+	if t := e.node.path("Times", "LastModificationTime"); t != nil {
+		t.Text = formatTime(timeNow())
+	}
+}
+
+func textOf(n *node) string {
+	if n == nil {
+		return ""
+	}
+
+	return n.Text
+}
+
+// insertBeforeGroups inserts entries before subgroups and groups after the
+// last element, the order KeePass writes them in
+func insertBeforeGroups(parent, n *node, group bool) {
+	if group {
+		parent.Nodes = append(parent.Nodes, n)
+		return
+	}
+
+	for i, c := range parent.Nodes {
+		if c.XMLName.Local == "Group" {
+			parent.Nodes = append(parent.Nodes[:i], append([]*node{n}, parent.Nodes[i:]...)...)
+			return
+		}
+	}
+
+	parent.Nodes = append(parent.Nodes, n)
+}
+
+// insertAfterLast inserts n after the last child with the first of the names
+// found, or appends it if there are none
+func insertAfterLast(parent, n *node, names ...string) {
+	pos := len(parent.Nodes)
+
+outer:
+	for _, name := range names {
+		for i := len(parent.Nodes) - 1; i >= 0; i-- {
+			if parent.Nodes[i].XMLName.Local == name {
+				pos = i + 1
+				break outer
+			}
+		}
+	}
+
+	parent.Nodes = append(parent.Nodes[:pos], append([]*node{n}, parent.Nodes[pos:]...)...)
+}
+
+func newTimes(now string) *node {
+	return newNode("Times", "",
+		newNode("CreationTime", now),
+		newNode("LastModificationTime", now),
+		newNode("LastAccessTime", now),
+		newNode("ExpiryTime", now),
+		newNode("Expires", "False"),
+		newNode("UsageCount", "0"),
+		newNode("LocationChanged", now),
+	)
+}
+
+func formatTime(t time.Time) string {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(t.Unix()-kdbxEpoch))
+
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return base64.StdEncoding.EncodeToString(b)
+}
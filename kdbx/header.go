@@ -0,0 +1,289 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	signature1 = 0x9AA2D903
+	signature2 = 0xB54BFB67
+
+	majorVersion4 = 4
+
+	// outer header field ids
+	hdrEnd         = 0
+	hdrCipherID    = 2
+	hdrCompression = 3
+	hdrMasterSeed  = 4
+	hdrIV          = 7
+	hdrKDF         = 11
+
+	// inner header field ids
+	innerEnd       = 0
+	innerStreamID  = 1
+	innerStreamKey = 2
+
+	compressionNone = 0
+	compressionGzip = 1
+
+	// maxFieldSize limits memory a corrupted file makes us allocate
+	maxFieldSize = 64 << 20
+)
+
+// field is a type-length-value header field, unknown fields are kept as is
+type field struct {
+	id   byte
+	data []byte
+}
+
+type header struct {
+	version uint32
+	fields  []field
+}
+
+func (h *header) get(id byte) []byte {
+	for _, f := range h.fields {
+		if f.id == id {
+			return f.data
+		}
+	}
+
+	return nil
+}
+
+func (h *header) set(id byte, data []byte) {
+	for n, f := range h.fields {
+		if f.id == id {
+			h.fields[n].data = data
+			return
+		}
+	}
+
+	h.fields = append(h.fields, field{id, data})
+}
+
+// readHeader reads outer header and returns it with its raw bytes, which are
+// hashed and authenticated
+func readHeader(r io.Reader) (*header, []byte, error) {
+	var raw bytes.Buffer
+	tr := io.TeeReader(r, &raw)
+
+	var sig [3]uint32
+	if err := binary.Read(tr, binary.LittleEndian, &sig); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	if sig[0] != signature1 || sig[1] != signature2 {
+		return nil, nil, fmt.Errorf("%w: not a KeePass database", ErrInvalidFile)
+	}
+
+	if major := sig[2] >> 16; major != majorVersion4 {
+		return nil, nil, fmt.Errorf("%w: KDBX %d.%d", ErrUnsupported, major, sig[2]&0xFFFF)
+	}
+
+	h := &header{version: sig[2]}
+
+	fields, err := readFields(tr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.fields = fields
+
+	return h, raw.Bytes(), nil
+}
+
+// readFields reads fields up to the end field, which isn't returned
+func readFields(r io.Reader) ([]field, error) {
+	var fields []field
+
+	for {
+		var (
+			id   byte
+			size uint32
+		)
+
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		if size > maxFieldSize {
+			return nil, fmt.Errorf("%w: header field %d is too large", ErrInvalidFile, id)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		if id == hdrEnd {
+			return fields, nil
+		}
+
+		fields = append(fields, field{id, data})
+	}
+}
+
+func (h *header) bytes() []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.LittleEndian, [3]uint32{signature1, signature2, h.version})
+	writeFields(&buf, h.fields, []byte{'\r', '\n', '\r', '\n'})
+
+	return buf.Bytes()
+}
+
+func writeFields(buf *bytes.Buffer, fields []field, end []byte) {
+	for _, f := range append(fields, field{hdrEnd, end}) {
+		buf.WriteByte(f.id)
+		_ = binary.Write(buf, binary.LittleEndian, uint32(len(f.data)))
+		buf.Write(f.data)
+	}
+}
+
+// variant dictionary value types
+const (
+	vdVersion   = 0x0100
+	vdUInt32    = 0x04
+	vdUInt64    = 0x05
+	vdBool      = 0x08
+	vdInt32     = 0x0C
+	vdInt64     = 0x0D
+	vdString    = 0x18
+	vdByteArray = 0x42
+)
+
+// variantDict is KDBX 4 key-value map used for KDF parameters
+type variantDict map[string]interface{}
+
+func parseVariantDict(data []byte) (variantDict, error) {
+	r := bytes.NewReader(data)
+
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+
+	if version>>8 != vdVersion>>8 {
+		return nil, fmt.Errorf("unsupported variant dictionary version %x", version)
+	}
+
+	d := make(variantDict)
+
+	for {
+		t, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		if t == 0 {
+			return d, nil
+		}
+
+		name, err := readSized(r)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readSized(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch t {
+		case vdUInt32, vdInt32:
+			if len(value) != 4 {
+				return nil, errors.New("invalid 32-bit value")
+			}
+
+			d[string(name)] = uint64(binary.LittleEndian.Uint32(value))
+		case vdUInt64, vdInt64:
+			if len(value) != 8 {
+				return nil, errors.New("invalid 64-bit value")
+			}
+
+			d[string(name)] = binary.LittleEndian.Uint64(value)
+		case vdBool:
+			d[string(name)] = len(value) == 1 && value[0] != 0
+		case vdString:
+			d[string(name)] = string(value)
+		case vdByteArray:
+			d[string(name)] = value
+		default:
+			return nil, fmt.Errorf("unknown variant type %x", t)
+		}
+	}
+}
+
+func readSized(r *bytes.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+
+	if int64(size) > int64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	data := make([]byte, size)
+	_, err := io.ReadFull(r, data)
+
+	return data, err
+}
+
+func (d variantDict) uint(name string) (uint64, bool) {
+	v, ok := d[name].(uint64)
+	return v, ok
+}
+
+func (d variantDict) bytes(name string) ([]byte, bool) {
+	v, ok := d[name].([]byte)
+	return v, ok
+}
+
+// variantEntry is a typed entry for building dictionaries in a stable order
+type variantEntry struct {
+	name  string
+	vtype byte
+	value []byte
+}
+
+func buildVariantDict(entries []variantEntry) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(vdVersion))
+
+	for _, e := range entries {
+		buf.WriteByte(e.vtype)
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(e.name)))
+		buf.WriteString(e.name)
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(len(e.value)))
+		buf.Write(e.value)
+	}
+
+	buf.WriteByte(0)
+
+	return buf.Bytes()
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+
+	return b
+}
+
+func uint64Bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+
+	return b
+}
@@ -0,0 +1,268 @@
+// Package kdbx reads and writes KeePass KDBX 4 databases. Elements of the
+// database XML which aren't used by clotp are kept and written back as is.
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	ErrInvalidFile = errors.New("invalid KeePass database")
+	ErrUnsupported = errors.New("unsupported KeePass database")
+	ErrInvalidKey  = errors.New("wrong password or key file")
+)
+
+// Database is a decrypted KDBX 4 database
+type Database struct {
+	header *header
+	// inner are inner header fields besides the random stream ones, e.g.
+	// attachments, which are written back as is
+	inner       []field
+	root        *node
+	transformed []byte
+}
+
+// Open reads and decrypts database with the key
+func Open(r io.Reader, key *Key) (*Database, error) {
+	h, raw, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var sums [2][sha256.Size]byte
+	if err := binary.Read(r, binary.LittleEndian, &sums); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	if sha256.Sum256(raw) != sums[0] {
+		return nil, fmt.Errorf("%w: header is corrupted", ErrInvalidFile)
+	}
+
+	seed := h.get(hdrMasterSeed)
+	if len(seed) != 32 {
+		return nil, fmt.Errorf("%w: invalid master seed", ErrInvalidFile)
+	}
+
+	transformed, err := transformKey(h.get(hdrKDF), key)
+	if err != nil {
+		return nil, err
+	}
+
+	k := deriveKeys(seed, transformed)
+	if !hmac.Equal(sums[1][:], k.headerMAC(raw)) {
+		return nil, ErrInvalidKey
+	}
+
+	encrypted, err := k.readBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := decrypt(h.get(hdrCipherID), k.cipher, h.get(hdrIV), encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	if compression := h.get(hdrCompression); len(compression) == 4 && binary.LittleEndian.Uint32(compression) == compressionGzip {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		if payload, err = ioutil.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+	}
+
+	pr := bytes.NewReader(payload)
+
+	fields, err := readFields(pr)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &Database{header: h, transformed: transformed}
+
+	var (
+		streamID  uint32
+		streamKey []byte
+	)
+
+	for _, f := range fields {
+		switch f.id {
+		case innerStreamID:
+			if len(f.data) != 4 {
+				return nil, fmt.Errorf("%w: invalid inner stream id", ErrInvalidFile)
+			}
+
+			streamID = binary.LittleEndian.Uint32(f.data)
+		case innerStreamKey:
+			streamKey = f.data
+		default:
+			db.inner = append(db.inner, f)
+		}
+	}
+
+	stream, err := newInnerStream(streamID, streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rest, _ := ioutil.ReadAll(pr)
+
+	if db.root, err = parseXML(rest); err != nil {
+		return nil, err
+	}
+
+	if err := db.root.unprotect(stream); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// New returns empty database protected with the key, it's encrypted with
+// AES-256 and the key is derived with Argon2d
+func New(key *Key) (*Database, error) {
+	params, err := newArgon2Params()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &header{version: majorVersion4 << 16}
+	h.set(hdrCipherID, cipherAES256)
+	h.set(hdrCompression, uint32Bytes(compressionGzip))
+	h.set(hdrKDF, params)
+
+	transformed, err := transformKey(params, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := formatTime(timeNow())
+	root := newNode("KeePassFile", "",
+		newNode("Meta", "",
+			newNode("Generator", "clotp"),
+			newNode("DatabaseName", ""),
+			newNode("MemoryProtection", "",
+				newNode("ProtectTitle", "False"),
+				newNode("ProtectUserName", "False"),
+				newNode("ProtectPassword", "True"),
+				newNode("ProtectURL", "False"),
+				newNode("ProtectNotes", "False"),
+			),
+			newNode("RecycleBinEnabled", "False"),
+		),
+		newNode("Root", "",
+			newNode("Group", "",
+				newNode("UUID", newUUID()),
+				newNode("Name", "Root"),
+				newTimes(now),
+				newNode("IsExpanded", "True"),
+			),
+			newNode("DeletedObjects", ""),
+		),
+	)
+
+	return &Database{header: h, root: root, transformed: transformed}, nil
+}
+
+// Write encrypts database with a new master seed and writes it to w. The
+// key can't be changed, the database is written with the key it's opened with.
+func (db *Database) Write(w io.Writer) error {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return err
+	}
+
+	n, err := ivSize(db.header.get(hdrCipherID))
+	if err != nil {
+		return err
+	}
+
+	iv := make([]byte, n)
+	if _, err := rand.Read(iv); err != nil {
+		return err
+	}
+
+	streamKey := make([]byte, 64)
+	if _, err := rand.Read(streamKey); err != nil {
+		return err
+	}
+
+	db.header.set(hdrMasterSeed, seed)
+	db.header.set(hdrIV, iv)
+
+	stream, err := newInnerStream(streamChaCha20, streamKey)
+	if err != nil {
+		return err
+	}
+
+	restore := db.root.protect(stream)
+	content, err := db.root.marshal()
+	restore()
+
+	if err != nil {
+		return err
+	}
+
+	var payload bytes.Buffer
+
+	inner := append([]field{
+		{innerStreamID, uint32Bytes(streamChaCha20)},
+		{innerStreamKey, streamKey},
+	}, db.inner...)
+
+	writeFields(&payload, inner, nil)
+	payload.Write(content)
+
+	plain := payload.Bytes()
+
+	if compression := db.header.get(hdrCompression); len(compression) == 4 && binary.LittleEndian.Uint32(compression) == compressionGzip {
+		var zbuf bytes.Buffer
+
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := zw.Write(plain); err != nil {
+			return err
+		}
+
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		plain = zbuf.Bytes()
+	}
+
+	k := deriveKeys(seed, db.transformed)
+
+	encrypted, err := encrypt(db.header.get(hdrCipherID), k.cipher, iv, plain)
+	if err != nil {
+		return err
+	}
+
+	raw := db.header.bytes()
+	sum := sha256.Sum256(raw)
+
+	var out bytes.Buffer
+
+	out.Write(raw)
+	out.Write(sum[:])
+	out.Write(k.headerMAC(raw))
+
+	if err := k.writeBlocks(&out, encrypted); err != nil {
+		return err
+	}
+
+	_, err = w.Write(out.Bytes())
+
+	return err
+}
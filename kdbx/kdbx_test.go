@@ -0,0 +1,345 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// keep tests fast, parameters are read from the database anyway
+	argon2Iterations = 1
+	argon2Memory = 64 << 10
+
+	os.Exit(m.Run())
+}
+
+func mustKey(password, keyFile []byte) *Key {
+	k, err := NewKey(password, keyFile)
+	if err != nil {
+		panic(err)
+	}
+
+	return k
+}
+
+func roundTrip(t *testing.T, db *Database, key *Key) *Database {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := db.Write(&buf); err != nil {
+		t.Fatalf("unwanted write error: %v", err)
+	}
+
+	opened, err := Open(&buf, key)
+	if err != nil {
+		t.Fatalf("unwanted open error: %v", err)
+	}
+
+	return opened
+}
+
+func TestDatabase_RoundTrip(t *testing.T) {
+	aesKDF := buildVariantDict([]variantEntry{
+		{"$UUID", vdByteArray, kdfAES},
+		{"S", vdByteArray, bytes.Repeat([]byte{7}, 32)},
+		{"R", vdUInt64, uint64Bytes(100)},
+	})
+
+	for _, c := range []struct {
+		name   string
+		cipher []byte
+		kdf    []byte
+	}{
+		{name: "aes argon2d", cipher: cipherAES256},
+		{name: "chacha20", cipher: cipherChaCha20},
+		{name: "twofish", cipher: cipherTwofish},
+		{name: "aes-kdf", cipher: cipherAES256, kdf: aesKDF},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			key := mustKey([]byte("password"), nil)
+
+			db, err := New(key)
+			if err != nil {
+				panic(err)
+			}
+
+			db.header.set(hdrCipherID, c.cipher)
+
+			if c.kdf != nil {
+				db.header.set(hdrKDF, c.kdf)
+				if db.transformed, err = transformKey(c.kdf, key); err != nil {
+					panic(err)
+				}
+			}
+
+			e, err := db.AddEntry([]string{"Work", "VPN"}, "gateway")
+			if err != nil {
+				panic(err)
+			}
+
+			e.Set("Password", "hunter2", true)
+			e.Set("otp", "otpauth://totp/gateway?secret=GE", true)
+
+			opened := roundTrip(t, db, key)
+
+			entries := opened.Entries()
+			if len(entries) != 1 {
+				t.Fatalf("wrong entries number: %d", len(entries))
+			}
+
+			got := entries[0]
+			if !reflect.DeepEqual(got.Groups, []string{"Work", "VPN"}) || got.Title() != "gateway" {
+				t.Errorf("wrong entry: %v %s", got.Groups, got.Title())
+			}
+
+			for key, want := range map[string]string{"Password": "hunter2", "otp": "otpauth://totp/gateway?secret=GE"} {
+				if v, _ := got.Get(key); v != want {
+					t.Errorf("wrong %s, want: %q != got: %q", key, want, v)
+				}
+			}
+		})
+	}
+}
+
+func TestDatabase_KeepsUnknownElements(t *testing.T) {
+	key := mustKey([]byte("password"), nil)
+
+	db, err := New(key)
+	if err != nil {
+		panic(err)
+	}
+
+	meta := db.root.child("Meta")
+	meta.Nodes = append(meta.Nodes, &node{XMLName: newNode("CustomData", "").XMLName, Nodes: []*node{
+		newNode("Item", "", newNode("Key", "KPXC_DECRYPTION_TIME_PREFERENCE"), newNode("Value", "1000")),
+	}})
+	db.inner = append(db.inner, field{3, []byte{1, 'a', 't', 't'}})
+
+	opened := roundTrip(t, db, key)
+
+	if v := textOf(opened.root.path("Meta", "CustomData", "Item", "Value")); v != "1000" {
+		t.Errorf("custom data is lost, got: %q", v)
+	}
+
+	if !reflect.DeepEqual(opened.inner, db.inner) {
+		t.Errorf("attachments are lost, want: %v != got: %v", db.inner, opened.inner)
+	}
+}
+
+func TestDatabase_RecycleBinAndRemove(t *testing.T) {
+	key := mustKey([]byte("password"), nil)
+
+	db, err := New(key)
+	if err != nil {
+		panic(err)
+	}
+
+	keep, _ := db.AddEntry(nil, "keep")
+	removed, _ := db.AddEntry(nil, "removed")
+	binned, _ := db.AddEntry([]string{"Recycle Bin"}, "binned")
+
+	meta := db.root.child("Meta")
+	meta.child("RecycleBinEnabled").Text = "True"
+	meta.Nodes = append(meta.Nodes, newNode("RecycleBinUUID", textOf(binned.group.child("UUID"))))
+
+	db.RemoveEntry(removed)
+
+	var titles []string
+	for _, e := range roundTrip(t, db, key).Entries() {
+		titles = append(titles, e.Title())
+	}
+
+	if !reflect.DeepEqual(titles, []string{keep.Title()}) {
+		t.Errorf("wrong entries: %v", titles)
+	}
+
+	deleted := db.root.path("Root", "DeletedObjects", "DeletedObject", "UUID")
+	if textOf(deleted) != textOf(removed.node.child("UUID")) {
+		t.Error("removed entry should be recorded as deleted object")
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	key := mustKey([]byte("password"), nil)
+
+	db, err := New(key)
+	if err != nil {
+		panic(err)
+	}
+
+	var buf bytes.Buffer
+	if err := db.Write(&buf); err != nil {
+		panic(err)
+	}
+
+	valid := buf.Bytes()
+
+	corrupted := append([]byte(nil), valid...)
+	corrupted[len(corrupted)-50] ^= 1
+
+	for _, c := range []struct {
+		name string
+		data []byte
+		key  *Key
+		want error
+	}{
+		{"wrong password", valid, mustKey([]byte("wrong"), nil), ErrInvalidKey},
+		{"key file required", valid, mustKey([]byte("password"), []byte("key")), ErrInvalidKey},
+		{"corrupted block", corrupted, key, ErrInvalidFile},
+		{"not kdbx", []byte("[github]\nsecret=GE\n"), key, ErrInvalidFile},
+		{"kdbx 3", append([]byte{0x03, 0xd9, 0xa2, 0x9a, 0x67, 0xfb, 0x4b, 0xb5, 0x01, 0x00, 0x03, 0x00}, valid[12:]...), key, ErrUnsupported},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := Open(bytes.NewReader(c.data), c.key); !errors.Is(err, c.want) {
+				t.Errorf("wrong error, want: %v != got: %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+
+	for _, c := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{
+			name: "xml v2",
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<KeyFile>
+	<Meta><Version>2.0</Version></Meta>
+	<Key>
+		<Data Hash="00E98867">
+			A0A1A2A3 A4A5A6A7 A8A9AAAB ACADAEAF
+			B0B1B2B3 B4B5B6B7 B8B9BABB BCBDBEBF
+		</Data>
+	</Key>
+</KeyFile>`,
+			want: "a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf",
+		},
+		{
+			name: "xml v1",
+			data: `<?xml version="1.0" encoding="utf-8"?><KeyFile><Meta><Version>1.00</Version></Meta>` +
+				`<Key><Data>oKGio6SlpqeoqaqrrK2ur7CxsrO0tba3uLm6u7y9vr8=</Data></Key></KeyFile>`,
+			want: "a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf",
+		},
+		{
+			name: "hex",
+			data: "a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf",
+			want: "a0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf",
+		},
+		{
+			name: "other",
+			data: "any file",
+			want: fmt.Sprintf("%x", sha256.Sum256([]byte("any file"))),
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := keyFileHash([]byte(c.data))
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+
+			if hex.EncodeToString(got) != c.want {
+				t.Errorf("wrong key, want: %s != got: %x", c.want, got)
+			}
+
+		})
+	}
+
+	if got, _ := keyFileHash(raw); !bytes.Equal(got, raw) {
+		t.Error("32 bytes key file should be the key itself")
+	}
+}
+
+// referenceFixture is KDBX 4 database written by testdata/genkdbx.py, an
+// implementation of the format independent of this package, with KeePassXC's
+// layout: AES-256, Argon2d, gzip and ChaCha20 inner stream. Password is
+// "clotp-test", it has Work/GitHub entry with password "hunter2", otp field
+// with secret GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ, an attachment and a history
+// entry with password "hunter1".
+const referenceFixture = "testdata/reference.kdbx"
+
+func TestOpen_Reference(t *testing.T) {
+	data, err := ioutil.ReadFile(referenceFixture)
+	if err != nil {
+		panic(err)
+	}
+
+	key := mustKey([]byte("clotp-test"), nil)
+
+	check := func(db *Database, otp string) {
+		t.Helper()
+
+		var github *Entry
+		for _, e := range db.Entries() {
+			if e.Title() == "GitHub" {
+				github = e
+			}
+		}
+
+		if github == nil || len(db.Entries()) != 1 {
+			t.Fatalf("wrong entries: %v", db.Entries())
+		}
+
+		if !reflect.DeepEqual(github.Groups, []string{"Work"}) {
+			t.Errorf("wrong groups: %v", github.Groups)
+		}
+
+		// protected values of history entries follow the entry ones in the stream
+		var history string
+		for _, s := range github.node.path("History", "Entry").children("String") {
+			if textOf(s.child("Key")) == "Password" {
+				history = textOf(s.child("Value"))
+			}
+		}
+
+		if history != "hunter1" {
+			t.Errorf("wrong history password: %q", history)
+		}
+
+		if want := []field{{3, []byte("\x011234-5678\n")}}; !reflect.DeepEqual(db.inner, want) {
+			t.Errorf("wrong attachments, want: %q != got: %q", want, db.inner)
+		}
+
+		if got, _ := github.Get("Password"); got != "hunter2" {
+			t.Errorf("wrong password: %q", got)
+		}
+
+		if got, _ := github.Get("otp"); !strings.Contains(got, otp) {
+			t.Errorf("wrong otp field, want: %s in %s", otp, got)
+		}
+	}
+
+	db, err := Open(bytes.NewReader(data), key)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	check(db, "secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+
+	for _, e := range db.Entries() {
+		if e.Title() == "GitHub" {
+			e.Set("otp", "otpauth://totp/GitHub?secret=GEZA&period=30&digits=8", true)
+		}
+	}
+
+	check(roundTrip(t, db, key), "secret=GEZA&period=30&digits=8")
+}
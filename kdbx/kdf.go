@@ -0,0 +1,125 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/mullakhmetov/clotp/kdbx/internal/argon2"
+)
+
+var (
+	kdfAES      = []byte{0xc9, 0xd9, 0xf3, 0x9a, 0x62, 0x8a, 0x44, 0x60, 0xbf, 0x74, 0x0d, 0x08, 0xc1, 0x8a, 0x4f, 0xea}
+	kdfArgon2d  = []byte{0xef, 0x63, 0x6d, 0xdf, 0x8c, 0x29, 0x44, 0x4b, 0x91, 0xf7, 0xa9, 0xa4, 0x03, 0xe3, 0x0a, 0x0c}
+	kdfArgon2id = []byte{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+)
+
+const (
+	maxArgon2Memory = 4 << 30
+	maxArgon2Lanes  = 255
+)
+
+// Argon2d parameters of new databases, KeePassXC defaults
+var (
+	argon2Iterations  uint64 = 10
+	argon2Memory      uint64 = 64 << 20
+	argon2Parallelism uint64 = 2
+)
+
+// transformKey derives the key the database is encrypted with from the
+// composite key using KDF parameters of the header
+func transformKey(params []byte, key *Key) ([]byte, error) {
+	d, err := parseVariantDict(params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: KDF parameters: %v", ErrInvalidFile, err)
+	}
+
+	uuid, _ := d.bytes("$UUID")
+
+	switch {
+	case bytes.Equal(uuid, kdfAES):
+		return aesKDF(d, key.hash())
+	case bytes.Equal(uuid, kdfArgon2d), bytes.Equal(uuid, kdfArgon2id):
+		return argon2KDF(d, key.hash(), bytes.Equal(uuid, kdfArgon2id))
+	default:
+		return nil, fmt.Errorf("%w: KDF %x", ErrUnsupported, uuid)
+	}
+}
+
+func aesKDF(d variantDict, key []byte) ([]byte, error) {
+	seed, ok := d.bytes("S")
+	rounds, ok2 := d.uint("R")
+
+	if !ok || !ok2 || len(seed) != 32 {
+		return nil, fmt.Errorf("%w: invalid AES-KDF parameters", ErrInvalidFile)
+	}
+
+	c, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(nil), key...)
+	for i := uint64(0); i < rounds; i++ {
+		c.Encrypt(out[:16], out[:16])
+		c.Encrypt(out[16:], out[16:])
+	}
+
+	h := sha256.Sum256(out)
+
+	return h[:], nil
+}
+
+func argon2KDF(d variantDict, key []byte, id bool) ([]byte, error) {
+	salt, ok := d.bytes("S")
+	iterations, ok2 := d.uint("I")
+	memory, ok3 := d.uint("M")
+	parallelism, ok4 := d.uint("P")
+
+	if !ok || !ok2 || !ok3 || !ok4 {
+		return nil, fmt.Errorf("%w: invalid Argon2 parameters", ErrInvalidFile)
+	}
+
+	if version, ok := d.uint("V"); ok && version != argon2.Version {
+		return nil, fmt.Errorf("%w: Argon2 version %x", ErrUnsupported, version)
+	}
+
+	if iterations < 1 || iterations > 1<<32-1 || memory < 8<<10 || memory > maxArgon2Memory ||
+		parallelism < 1 || parallelism > maxArgon2Lanes {
+		return nil, fmt.Errorf("%w: Argon2 parameters are out of range", ErrUnsupported)
+	}
+
+	if _, ok := d.bytes("K"); ok {
+		return nil, fmt.Errorf("%w: Argon2 secret key", ErrUnsupported)
+	}
+
+	if _, ok := d.bytes("A"); ok {
+		return nil, fmt.Errorf("%w: Argon2 associated data", ErrUnsupported)
+	}
+
+	derive := argon2.DKey
+	if id {
+		derive = argon2.IDKey
+	}
+
+	return derive(key, salt, uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+}
+
+// newArgon2Params returns Argon2d parameters with a random salt
+func newArgon2Params() ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return buildVariantDict([]variantEntry{
+		{"$UUID", vdByteArray, kdfArgon2d},
+		{"S", vdByteArray, salt},
+		{"P", vdUInt32, uint32Bytes(uint32(argon2Parallelism))},
+		{"M", vdUInt64, uint64Bytes(argon2Memory)},
+		{"I", vdUInt64, uint64Bytes(argon2Iterations)},
+		{"V", vdUInt32, uint32Bytes(argon2.Version)},
+	}), nil
+}
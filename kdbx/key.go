@@ -0,0 +1,111 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Key is a composite key of a password and/or a key file
+type Key struct {
+	components [][]byte
+}
+
+// NewKey returns composite key of the password and key file content, nil
+// password or key file means the database doesn't use it
+func NewKey(password, keyFile []byte) (*Key, error) {
+	k := &Key{}
+
+	if password != nil {
+		h := sha256.Sum256(password)
+		k.components = append(k.components, h[:])
+	}
+
+	if keyFile != nil {
+		h, err := keyFileHash(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		k.components = append(k.components, h)
+	}
+
+	if len(k.components) == 0 {
+		return nil, errors.New("password or key file is required")
+	}
+
+	return k, nil
+}
+
+func (k *Key) hash() []byte {
+	h := sha256.New()
+	for _, c := range k.components {
+		h.Write(c)
+	}
+
+	return h.Sum(nil)
+}
+
+type xmlKeyFile struct {
+	Version string `xml:"Meta>Version"`
+	Data    struct {
+		Hash  string `xml:"Hash,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"Key>Data"`
+}
+
+// keyFileHash returns key of the key file: KeePass XML key files of version 1
+// and 2 hold the key, 32 bytes binary and 64 hex digits files are the key
+// itself, any other file is hashed
+func keyFileHash(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<?xml")) || bytes.HasPrefix(bytes.TrimSpace(data), []byte("<KeyFile")) {
+		var kf xmlKeyFile
+		if err := xml.Unmarshal(data, &kf); err == nil && kf.Data.Value != "" {
+			return xmlKeyFileHash(kf)
+		}
+	}
+
+	if len(data) == 32 {
+		return data, nil
+	}
+
+	if len(data) == 64 {
+		if key, err := hex.DecodeString(string(data)); err == nil {
+			return key, nil
+		}
+	}
+
+	h := sha256.Sum256(data)
+
+	return h[:], nil
+}
+
+func xmlKeyFileHash(kf xmlKeyFile) ([]byte, error) {
+	value := strings.Join(strings.Fields(kf.Data.Value), "")
+
+	switch {
+	case strings.HasPrefix(kf.Version, "1."):
+		return base64.StdEncoding.DecodeString(value)
+	case strings.HasPrefix(kf.Version, "2."):
+		key, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file: %w", err)
+		}
+
+		if kf.Data.Hash != "" {
+			sum := sha256.Sum256(key)
+			if !strings.EqualFold(hex.EncodeToString(sum[:4]), kf.Data.Hash) {
+				return nil, errors.New("invalid key file: hash mismatch")
+			}
+		}
+
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key file version %s", kf.Version)
+	}
+}
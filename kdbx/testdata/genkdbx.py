@@ -0,0 +1,540 @@
+#!/usr/bin/env python3
+"""Writes reference.kdbx, a KDBX 4 database for the kdbx package tests.
+
+It's a standalone implementation of the format following the KeePass KDBX 4
+specification and KeePassXC's layout and settings: AES-256 cipher, Argon2d
+KDF, gzip compression and ChaCha20 inner stream. It shares no code with the
+package, so the package is tested against a file it didn't write. Argon2d,
+AES and ChaCha20 are checked against RFC 9106, FIPS-197 and RFC 8439 test
+vectors before the database is written.
+
+Only Python standard library is used. All random values are fixed, so the
+output is the same on every run:
+
+    python3 genkdbx.py
+"""
+
+import base64
+import gzip
+import hashlib
+import hmac
+import os
+import struct
+
+PASSWORD = b"clotp-test"
+
+# Argon2 parameters are lowered from KeePassXC defaults, pure Python is slow
+ARGON2_MEMORY = 1 << 20
+ARGON2_ITERATIONS = 2
+ARGON2_PARALLELISM = 2
+
+MASK64 = (1 << 64) - 1
+
+
+def fixed(label, n):
+    """Returns n fixed pseudo-random bytes for the label"""
+    out = b""
+    i = 0
+    while len(out) < n:
+        out += hashlib.sha256(b"%s-%d" % (label.encode(), i)).digest()
+        i += 1
+    return out[:n]
+
+
+# Argon2d, RFC 9106
+
+
+def blake2b_long(data, n):
+    prefix = struct.pack("<I", n)
+    if n <= 64:
+        return hashlib.blake2b(prefix + data, digest_size=n).digest()
+
+    v = hashlib.blake2b(prefix + data).digest()
+    out = v[:32]
+    while n - len(out) > 64:
+        v = hashlib.blake2b(v).digest()
+        out += v[:32]
+
+    return out + hashlib.blake2b(v, digest_size=n - len(out)).digest()
+
+
+def blamka(a, b):
+    return (a + b + 2 * (a & 0xFFFFFFFF) * (b & 0xFFFFFFFF)) & MASK64
+
+
+def rotr64(x, n):
+    return ((x >> n) | (x << (64 - n))) & MASK64
+
+
+def permute(v, idx):
+    def gb(a, b, c, d):
+        va, vb, vc, vd = v[idx[a]], v[idx[b]], v[idx[c]], v[idx[d]]
+        va = blamka(va, vb)
+        vd = rotr64(vd ^ va, 32)
+        vc = blamka(vc, vd)
+        vb = rotr64(vb ^ vc, 24)
+        va = blamka(va, vb)
+        vd = rotr64(vd ^ va, 16)
+        vc = blamka(vc, vd)
+        vb = rotr64(vb ^ vc, 63)
+        v[idx[a]], v[idx[b]], v[idx[c]], v[idx[d]] = va, vb, vc, vd
+
+    gb(0, 4, 8, 12)
+    gb(1, 5, 9, 13)
+    gb(2, 6, 10, 14)
+    gb(3, 7, 11, 15)
+    gb(0, 5, 10, 15)
+    gb(1, 6, 11, 12)
+    gb(2, 7, 8, 13)
+    gb(3, 4, 9, 14)
+
+
+ROWS = [list(range(16 * i, 16 * i + 16)) for i in range(8)]
+COLUMNS = [[16 * r + 2 * i + k for r in range(8) for k in range(2)] for i in range(8)]
+
+
+def compress(x, y):
+    r = [a ^ b for a, b in zip(x, y)]
+    z = list(r)
+    for idx in ROWS:
+        permute(z, idx)
+    for idx in COLUMNS:
+        permute(z, idx)
+    return [a ^ b for a, b in zip(z, r)]
+
+
+def to_words(data):
+    return list(struct.unpack("<128Q", data))
+
+
+def argon2d(password, salt, iterations, memory_kib, lanes, n, secret=b"", ad=b""):
+    h0 = hashlib.blake2b(
+        struct.pack("<IIIIII", lanes, n, memory_kib, iterations, 0x13, 0)
+        + struct.pack("<I", len(password)) + password
+        + struct.pack("<I", len(salt)) + salt
+        + struct.pack("<I", len(secret)) + secret
+        + struct.pack("<I", len(ad)) + ad
+    ).digest()
+
+    lane_length = memory_kib // (4 * lanes) * 4
+    segment_length = lane_length // 4
+
+    b = [[None] * lane_length for _ in range(lanes)]
+    for lane in range(lanes):
+        for j in range(2):
+            b[lane][j] = to_words(blake2b_long(h0 + struct.pack("<II", j, lane), 1024))
+
+    for p in range(iterations):
+        for s in range(4):
+            for lane in range(lanes):
+                start = 2 if p == 0 and s == 0 else 0
+                for index in range(start, segment_length):
+                    j = s * segment_length + index
+                    prev = b[lane][j - 1]
+
+                    j1 = prev[0] & 0xFFFFFFFF
+                    ref_lane = (prev[0] >> 32) % lanes
+                    if p == 0 and s == 0:
+                        ref_lane = lane
+
+                    if p == 0:
+                        area = s * segment_length
+                    else:
+                        area = lane_length - segment_length
+
+                    if ref_lane == lane:
+                        area += index - 1
+                    elif index == 0:
+                        area -= 1
+
+                    x = (j1 * j1) >> 32
+                    rel = area - 1 - ((area * x) >> 32)
+                    begin = 0 if p == 0 or s == 3 else (s + 1) * segment_length
+                    ref = b[ref_lane][(begin + rel) % lane_length]
+
+                    block = compress(prev, ref)
+                    if p > 0:
+                        block = [a ^ c for a, c in zip(block, b[lane][j])]
+                    b[lane][j] = block
+
+    final = b[0][-1]
+    for lane in range(1, lanes):
+        final = [a ^ c for a, c in zip(final, b[lane][-1])]
+
+    return blake2b_long(struct.pack("<128Q", *final), n)
+
+
+# AES-256, FIPS-197
+
+
+def gmul(a, b):
+    p = 0
+    while b:
+        if b & 1:
+            p ^= a
+        a = ((a << 1) ^ 0x11B) if a & 0x80 else a << 1
+        b >>= 1
+    return p
+
+
+def make_sbox():
+    sbox = [0] * 256
+    for i in range(256):
+        inv = next((c for c in range(1, 256) if gmul(i, c) == 1), 0)
+        s = inv
+        for k in range(1, 5):
+            s ^= ((inv << k) | (inv >> (8 - k))) & 0xFF
+        sbox[i] = s ^ 0x63
+    return sbox
+
+
+SBOX = make_sbox()
+
+
+def aes256_expand(key):
+    w = [list(key[i:i + 4]) for i in range(0, 32, 4)]
+    rcon = 1
+    for i in range(8, 60):
+        t = list(w[i - 1])
+        if i % 8 == 0:
+            t = [SBOX[c] for c in t[1:] + t[:1]]
+            t[0] ^= rcon
+            rcon = gmul(rcon, 2)
+        elif i % 8 == 4:
+            t = [SBOX[c] for c in t]
+        w.append([a ^ c for a, c in zip(w[i - 8], t)])
+    return [sum(w[4 * r:4 * r + 4], []) for r in range(15)]
+
+
+def aes256_encrypt_block(round_keys, block):
+    s = [a ^ k for a, k in zip(block, round_keys[0])]
+    for r in range(1, 15):
+        s = [SBOX[c] for c in s]
+        # state is column-major, byte i is row i % 4 of column i // 4
+        s = [s[(i + 4 * (i % 4)) % 16] for i in range(16)]
+        if r != 14:
+            mixed = []
+            for c in range(4):
+                a = s[4 * c:4 * c + 4]
+                mixed += [
+                    gmul(a[0], 2) ^ gmul(a[1], 3) ^ a[2] ^ a[3],
+                    a[0] ^ gmul(a[1], 2) ^ gmul(a[2], 3) ^ a[3],
+                    a[0] ^ a[1] ^ gmul(a[2], 2) ^ gmul(a[3], 3),
+                    gmul(a[0], 3) ^ a[1] ^ a[2] ^ gmul(a[3], 2),
+                ]
+            s = mixed
+        s = [a ^ k for a, k in zip(s, round_keys[r])]
+    return bytes(s)
+
+
+def aes256_cbc_encrypt(key, iv, data):
+    round_keys = aes256_expand(key)
+    pad = 16 - len(data) % 16
+    data += bytes([pad]) * pad
+
+    out = b""
+    prev = iv
+    for i in range(0, len(data), 16):
+        prev = aes256_encrypt_block(round_keys, [a ^ c for a, c in zip(data[i:i + 16], prev)])
+        out += prev
+    return out
+
+
+# ChaCha20, RFC 8439
+
+
+def chacha20_block(key, counter, nonce):
+    def rotl32(x, n):
+        return ((x << n) | (x >> (32 - n))) & 0xFFFFFFFF
+
+    def qr(s, a, b, c, d):
+        s[a] = (s[a] + s[b]) & 0xFFFFFFFF
+        s[d] = rotl32(s[d] ^ s[a], 16)
+        s[c] = (s[c] + s[d]) & 0xFFFFFFFF
+        s[b] = rotl32(s[b] ^ s[c], 12)
+        s[a] = (s[a] + s[b]) & 0xFFFFFFFF
+        s[d] = rotl32(s[d] ^ s[a], 8)
+        s[c] = (s[c] + s[d]) & 0xFFFFFFFF
+        s[b] = rotl32(s[b] ^ s[c], 7)
+
+    init = (
+        [0x61707865, 0x3320646E, 0x79622D32, 0x6B206574]
+        + list(struct.unpack("<8I", key))
+        + [counter]
+        + list(struct.unpack("<3I", nonce))
+    )
+    s = list(init)
+    for _ in range(10):
+        qr(s, 0, 4, 8, 12)
+        qr(s, 1, 5, 9, 13)
+        qr(s, 2, 6, 10, 14)
+        qr(s, 3, 7, 11, 15)
+        qr(s, 0, 5, 10, 15)
+        qr(s, 1, 6, 11, 12)
+        qr(s, 2, 7, 8, 13)
+        qr(s, 3, 4, 9, 14)
+    return struct.pack("<16I", *[(a + c) & 0xFFFFFFFF for a, c in zip(s, init)])
+
+
+class ChaCha20:
+    def __init__(self, key, nonce, counter=0):
+        self.key, self.nonce, self.counter = key, nonce, counter
+        self.stream = b""
+
+    def xor(self, data):
+        while len(self.stream) < len(data):
+            self.stream += chacha20_block(self.key, self.counter, self.nonce)
+            self.counter += 1
+        out = bytes(a ^ c for a, c in zip(data, self.stream))
+        self.stream = self.stream[len(data):]
+        return out
+
+
+def self_test():
+    tag = argon2d(b"\x01" * 32, b"\x02" * 16, 3, 32, 4, 32, secret=b"\x03" * 8, ad=b"\x04" * 12)
+    assert tag.hex() == "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb", tag.hex()
+
+    ct = aes256_encrypt_block(aes256_expand(bytes(range(32))), list(bytes.fromhex("00112233445566778899aabbccddeeff")))
+    assert ct.hex() == "8ea2b7ca516745bfeafc49904b496089", ct.hex()
+
+    block = chacha20_block(bytes(range(32)), 1, bytes.fromhex("000000090000004a00000000"))
+    assert block[:16].hex() == "10f1e7e4d13b5915500fdd1fa32071c4", block.hex()
+
+    plain = b"Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it."
+    ct = ChaCha20(bytes(range(32)), bytes.fromhex("000000000000004a00000000"), 1).xor(plain)
+    assert ct[:16].hex() == "6e2e359a2568f98041ba0728dd0d6981", ct.hex()
+
+
+# KDBX 4
+
+
+def field(fid, data):
+    return struct.pack("<BI", fid, len(data)) + data
+
+
+def variant(vtype, name, value):
+    return struct.pack("<BI", vtype, len(name)) + name + struct.pack("<I", len(value)) + value
+
+
+def kdbx_time(year, month, day):
+    # seconds since 0001-01-01, base64 of little-endian int64
+    import datetime
+
+    seconds = int((datetime.datetime(year, month, day) - datetime.datetime(1, 1, 1)).total_seconds())
+    return base64.b64encode(struct.pack("<q", seconds)).decode()
+
+
+def uuid(label):
+    return base64.b64encode(fixed(label, 16)).decode()
+
+
+def times(t, indent):
+    lines = [
+        "<Times>",
+        "\t<LastModificationTime>%s</LastModificationTime>" % t,
+        "\t<CreationTime>%s</CreationTime>" % t,
+        "\t<LastAccessTime>%s</LastAccessTime>" % t,
+        "\t<ExpiryTime>%s</ExpiryTime>" % t,
+        "\t<Expires>False</Expires>",
+        "\t<UsageCount>0</UsageCount>",
+        "\t<LocationChanged>%s</LocationChanged>" % t,
+        "</Times>",
+    ]
+    return "\n".join(indent + line for line in lines)
+
+
+def entry_xml(label, strings, indent, stream, history=""):
+    t = kdbx_time(2020, 1, 2)
+    lines = [
+        "<Entry>",
+        "\t<UUID>%s</UUID>" % uuid(label),
+        "\t<IconID>0</IconID>",
+        "\t<ForegroundColor/>",
+        "\t<BackgroundColor/>",
+        "\t<OverrideURL/>",
+        "\t<Tags/>",
+    ]
+    out = "\n".join(indent + line for line in lines) + "\n"
+    out += times(t, indent + "\t") + "\n"
+
+    for key, value, protected in strings:
+        if protected:
+            value = base64.b64encode(stream.xor(value.encode())).decode()
+            out += indent + '\t<String>\n%s\t\t<Key>%s</Key>\n%s\t\t<Value Protected="True">%s</Value>\n%s\t</String>\n' % (
+                indent, key, indent, value, indent)
+        elif value:
+            out += indent + "\t<String>\n%s\t\t<Key>%s</Key>\n%s\t\t<Value>%s</Value>\n%s\t</String>\n" % (
+                indent, key, indent, value, indent)
+        else:
+            out += indent + "\t<String>\n%s\t\t<Key>%s</Key>\n%s\t\t<Value/>\n%s\t</String>\n" % (indent, key, indent, indent)
+
+    out += indent + "\t<AutoType>\n"
+    out += indent + "\t\t<Enabled>True</Enabled>\n"
+    out += indent + "\t\t<DataTransferObfuscation>0</DataTransferObfuscation>\n"
+    out += indent + "\t\t<DefaultSequence/>\n"
+    out += indent + "\t</AutoType>\n"
+
+    if history is None:
+        pass
+    elif history:
+        out += indent + "\t<History>\n" + history + indent + "\t</History>\n"
+    else:
+        out += indent + "\t<History/>\n"
+
+    return out + indent + "</Entry>\n"
+
+
+def database_xml(stream):
+    t = kdbx_time(2020, 1, 2)
+    zero = base64.b64encode(bytes(16)).decode()
+    otp = "otpauth://totp/GitHub:octocat?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&period=30&digits=6&issuer=GitHub"
+
+    # protected values are XORed with the stream in document order, the
+    # history entry comes after the fields of its entry
+    strings = [
+        ("Notes", "", False),
+        ("Password", "hunter2", True),
+        ("Title", "GitHub", False),
+        ("URL", "https://github.com", False),
+        ("UserName", "octocat", False),
+        ("otp", otp, True),
+    ]
+    github = entry_xml("github", strings, "\t\t\t\t", stream, history=None)
+    old = entry_xml("github", [
+        ("Notes", "", False),
+        ("Password", "hunter1", True),
+        ("Title", "GitHub", False),
+        ("URL", "https://github.com", False),
+        ("UserName", "octocat", False),
+    ], "\t\t\t\t\t\t", stream, history=None)
+    github = github.replace(
+        "\t\t\t\t</Entry>\n",
+        '\t\t\t\t\t<Binary>\n\t\t\t\t\t\t<Key>recovery-codes.txt</Key>\n\t\t\t\t\t\t<Value Ref="0"/>\n\t\t\t\t\t</Binary>\n'
+        "\t\t\t\t\t<History>\n" + old + "\t\t\t\t\t</History>\n\t\t\t\t</Entry>\n")
+
+    meta = """\t<Meta>
+\t\t<Generator>KeePassXC</Generator>
+\t\t<DatabaseName>Passwords</DatabaseName>
+\t\t<DatabaseNameChanged>{t}</DatabaseNameChanged>
+\t\t<DatabaseDescription/>
+\t\t<DatabaseDescriptionChanged>{t}</DatabaseDescriptionChanged>
+\t\t<DefaultUserName/>
+\t\t<DefaultUserNameChanged>{t}</DefaultUserNameChanged>
+\t\t<MaintenanceHistoryDays>365</MaintenanceHistoryDays>
+\t\t<Color/>
+\t\t<MasterKeyChanged>{t}</MasterKeyChanged>
+\t\t<MasterKeyChangeRec>-1</MasterKeyChangeRec>
+\t\t<MasterKeyChangeForce>-1</MasterKeyChangeForce>
+\t\t<MemoryProtection>
+\t\t\t<ProtectTitle>False</ProtectTitle>
+\t\t\t<ProtectUserName>False</ProtectUserName>
+\t\t\t<ProtectPassword>True</ProtectPassword>
+\t\t\t<ProtectURL>False</ProtectURL>
+\t\t\t<ProtectNotes>False</ProtectNotes>
+\t\t</MemoryProtection>
+\t\t<CustomIcons/>
+\t\t<RecycleBinEnabled>True</RecycleBinEnabled>
+\t\t<RecycleBinUUID>{zero}</RecycleBinUUID>
+\t\t<RecycleBinChanged>{t}</RecycleBinChanged>
+\t\t<EntryTemplatesGroup>{zero}</EntryTemplatesGroup>
+\t\t<EntryTemplatesGroupChanged>{t}</EntryTemplatesGroupChanged>
+\t\t<LastSelectedGroup>{zero}</LastSelectedGroup>
+\t\t<LastTopVisibleGroup>{zero}</LastTopVisibleGroup>
+\t\t<HistoryMaxItems>10</HistoryMaxItems>
+\t\t<HistoryMaxSize>6291456</HistoryMaxSize>
+\t\t<SettingsChanged>{t}</SettingsChanged>
+\t\t<CustomData>
+\t\t\t<Item>
+\t\t\t\t<Key>KPXC_DECRYPTION_TIME_PREFERENCE</Key>
+\t\t\t\t<Value>1000</Value>
+\t\t\t</Item>
+\t\t</CustomData>
+\t</Meta>
+""".format(t=t, zero=zero)
+
+    def group(label, name, indent, content):
+        lines = [
+            "<Group>",
+            "\t<UUID>%s</UUID>" % uuid(label),
+            "\t<Name>%s</Name>" % name,
+            "\t<Notes/>",
+            "\t<IconID>48</IconID>",
+        ]
+        out = "\n".join(indent + line for line in lines) + "\n"
+        out += times(t, indent + "\t") + "\n"
+        lines = [
+            "\t<IsExpanded>True</IsExpanded>",
+            "\t<DefaultAutoTypeSequence/>",
+            "\t<EnableAutoType>null</EnableAutoType>",
+            "\t<EnableSearching>null</EnableSearching>",
+            "\t<LastTopVisibleEntry>%s</LastTopVisibleEntry>" % zero,
+        ]
+        out += "\n".join(indent + line for line in lines) + "\n"
+        return out + content + indent + "</Group>\n"
+
+    work = group("work", "Work", "\t\t\t", github)
+    root = "\t<Root>\n" + group("root", "Root", "\t\t", work) + "\t\t<DeletedObjects/>\n\t</Root>\n"
+
+    return ('<?xml version="1.0" encoding="UTF-8" standalone="yes"?>\n<KeePassFile>\n' + meta + root + "</KeePassFile>\n").encode()
+
+
+def write(path):
+    master_seed = fixed("master seed", 32)
+    iv = fixed("iv", 16)
+    salt = fixed("salt", 32)
+    stream_key = fixed("stream key", 64)
+
+    kdf = struct.pack("<H", 0x0100)
+    kdf += variant(0x42, b"$UUID", bytes.fromhex("ef636ddf8c29444b91f7a9a403e30a0c"))
+    kdf += variant(0x05, b"I", struct.pack("<Q", ARGON2_ITERATIONS))
+    kdf += variant(0x05, b"M", struct.pack("<Q", ARGON2_MEMORY))
+    kdf += variant(0x04, b"P", struct.pack("<I", ARGON2_PARALLELISM))
+    kdf += variant(0x42, b"S", salt)
+    kdf += variant(0x04, b"V", struct.pack("<I", 0x13))
+    kdf += b"\x00"
+
+    header = struct.pack("<III", 0x9AA2D903, 0xB54BFB67, 0x00040000)
+    header += field(2, bytes.fromhex("31c1f2e6bf714350be5805216afc5aff"))
+    header += field(3, struct.pack("<I", 1))
+    header += field(4, master_seed)
+    header += field(7, iv)
+    header += field(11, kdf)
+    header += field(0, b"\r\n\r\n")
+
+    composite = hashlib.sha256(hashlib.sha256(PASSWORD).digest()).digest()
+    transformed = argon2d(composite, salt, ARGON2_ITERATIONS, ARGON2_MEMORY // 1024, ARGON2_PARALLELISM, 32)
+
+    cipher_key = hashlib.sha256(master_seed + transformed).digest()
+    hmac_key = hashlib.sha512(master_seed + transformed + b"\x01").digest()
+
+    def block_key(index):
+        return hashlib.sha512(struct.pack("<Q", index) + hmac_key).digest()
+
+    # the header HMAC key is derived for the maximum block index, the index
+    # isn't part of the authenticated data unlike in data blocks
+    header_hmac = hmac.new(block_key(MASK64), header, hashlib.sha256).digest()
+
+    digest = hashlib.sha512(stream_key).digest()
+    stream = ChaCha20(digest[:32], digest[32:44])
+
+    inner = field(1, struct.pack("<I", 3))
+    inner += field(2, stream_key)
+    inner += field(3, b"\x01" + b"1234-5678\n")
+    inner += field(0, b"")
+
+    payload = gzip.compress(inner + database_xml(stream), mtime=0)
+    encrypted = aes256_cbc_encrypt(cipher_key, iv, payload)
+
+    out = header + hashlib.sha256(header).digest() + header_hmac
+    for index, data in enumerate([encrypted, b""]):
+        size = struct.pack("<I", len(data))
+        out += hmac.new(block_key(index), struct.pack("<Q", index) + size + data, hashlib.sha256).digest()
+        out += size + data
+
+    with open(path, "wb") as f:
+        f.write(out)
+
+
+if __name__ == "__main__":
+    self_test()
+    write(os.path.join(os.path.dirname(os.path.abspath(__file__)), "reference.kdbx"))
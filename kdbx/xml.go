@@ -0,0 +1,192 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const xmlHeader = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>` + "\n"
+
+// node is a generic XML element, so elements clotp doesn't know about are
+// written back unchanged. KeePass XML has no mixed content, only leaves have
+// text.
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []*node    `xml:",any"`
+}
+
+func newNode(name, text string, children ...*node) *node {
+	return &node{XMLName: xml.Name{Local: name}, Text: text, Nodes: children}
+}
+
+func parseXML(data []byte) (*node, error) {
+	var root node
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	root.walk(func(n *node) {
+		if len(n.Nodes) > 0 {
+			n.Text = ""
+		}
+	})
+
+	return &root, nil
+}
+
+func (n *node) marshal() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(xmlHeader)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "\t")
+
+	if err := enc.Encode(n); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// walk calls fn for the node and its descendants in document order
+func (n *node) walk(fn func(*node)) {
+	fn(n)
+
+	for _, c := range n.Nodes {
+		c.walk(fn)
+	}
+}
+
+// child returns the first child with given name
+func (n *node) child(name string) *node {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// path returns the descendant at the path of child names
+func (n *node) path(names ...string) *node {
+	for _, name := range names {
+		if n = n.child(name); n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+func (n *node) children(name string) []*node {
+	var res []*node
+
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
+
+func (n *node) remove(c *node) {
+	for i, cc := range n.Nodes {
+		if cc == c {
+			n.Nodes = append(n.Nodes[:i], n.Nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+func (n *node) setAttr(name, value string) {
+	for i, a := range n.Attrs {
+		if a.Name.Local == name {
+			n.Attrs[i].Value = value
+			return
+		}
+	}
+
+	n.Attrs = append(n.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (n *node) removeAttr(name string) {
+	for i, a := range n.Attrs {
+		if a.Name.Local == name {
+			n.Attrs = append(n.Attrs[:i], n.Attrs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (n *node) protected() bool {
+	return strings.EqualFold(n.attr("Protected"), "True")
+}
+
+// unprotect replaces protected values with their plain text, values are
+// XORed with the inner stream in document order
+func (n *node) unprotect(s innerStream) error {
+	var err error
+
+	n.walk(func(c *node) {
+		if err != nil || !c.protected() {
+			return
+		}
+
+		data, derr := base64.StdEncoding.DecodeString(c.Text)
+		if derr != nil {
+			err = fmt.Errorf("%w: protected value: %v", ErrInvalidFile, derr)
+			return
+		}
+
+		s.XORKeyStream(data, data)
+		c.Text = string(data)
+	})
+
+	return err
+}
+
+// protect is the reverse of unprotect, it returns function restoring the
+// plain text values
+func (n *node) protect(s innerStream) (restore func()) {
+	var (
+		nodes []*node
+		texts []string
+	)
+
+	n.walk(func(c *node) {
+		if !c.protected() {
+			return
+		}
+
+		nodes = append(nodes, c)
+		texts = append(texts, c.Text)
+
+		data := []byte(c.Text)
+		s.XORKeyStream(data, data)
+		c.Text = base64.StdEncoding.EncodeToString(data)
+	})
+
+	return func() {
+		for i, c := range nodes {
+			c.Text = texts[i]
+		}
+	}
+}
@@ -1,8 +1,57 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mullakhmetov/clotp/agent"
+	"github.com/mullakhmetov/clotp/audit"
+	"github.com/mullakhmetov/clotp/envelope"
+	"github.com/mullakhmetov/clotp/history"
+	"github.com/mullakhmetov/clotp/securemem"
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/usage"
+	"github.com/mullakhmetov/clotp/vault"
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// backendEnv chooses the vault backend: ini (default), pass, keepass or shared
+	backendEnv = "CLOTP_BACKEND"
+	// passPrefixEnv is the pass store subdirectory items are kept in
+	passPrefixEnv = "CLOTP_PASS_PREFIX"
+	// gpgEnv is gpg binary used by pass backend
+	gpgEnv = "CLOTP_GPG"
+	// keePassDBEnv is the KeePass database path used by keepass backend
+	keePassDBEnv = "CLOTP_KEEPASS_DB"
+	// keePassKeyFileEnv is the key file of the KeePass database
+	keePassKeyFileEnv = "CLOTP_KEEPASS_KEYFILE"
+	// timeOffsetEnv is the default of --time-offset option
+	timeOffsetEnv = "CLOTP_TIME_OFFSET"
+	// auditEnv sets audit log mode: chain (default), plain or off
+	auditEnv = "CLOTP_AUDIT"
+	// auditLogEnv overrides audit log path
+	auditLogEnv = "CLOTP_AUDIT_LOG"
+	// gitEnv is git binary used if the vault directory is a git repository
+	gitEnv = "CLOTP_GIT"
+	// mlockEnv set to 1 locks secrets of the vault into RAM
+	mlockEnv = "CLOTP_MLOCK"
+	// sharedVaultEnv overrides the path of the vault used by shared backend
+	sharedVaultEnv = "CLOTP_SHARED_VAULT"
+	// identityEnv is age identity file or SSH ed25519 private key opening the
+	// shared vault, ~/.ssh/id_ed25519 by default
+	identityEnv = "CLOTP_IDENTITY"
+
+	// settingsName is the file in the config directory setting CLOTP_*
+	// variables, e.g. passphrase_command = pass show clotp
+	settingsName = "settings.ini"
 )
 
 type Command interface {
@@ -10,28 +59,358 @@ type Command interface {
 }
 
 func main() {
-	cfg, err := NewConfig(Opts{})
+	env := NewEnv()
+
+	if err := loadSettings(filepath.Join(vault.DefaultDir(), settingsName)); err != nil {
+		fmt.Fprintln(env.Stderr, err)
+		os.Exit(1)
+	}
+
+	// core dumps would contain decrypted secrets
+	if err := securemem.DisableCoreDumps(); err != nil && !errors.Is(err, securemem.ErrUnsupported) {
+		fmt.Fprintf(env.Stderr, "warning: failed to disable core dumps: %v\n", err)
+	}
+
+	if c := agent.NewClient(agent.SocketPath()); c.Ping() == nil {
+		env.Agent = c
+	}
+
+	log, err := openAudit()
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(env.Stderr, err)
 		os.Exit(1)
 	}
 
-	var cmd Command
-	if len(os.Args) < 2 {
-		cmd = NewCommandList(cfg)
-		os.Exit(cmd.Execute([]string{}))
+	env.Audit = log
+	env.Usage = usage.Open(filepath.Join(vault.DefaultDir(), usage.DefaultName))
+	env.History = openHistory()
+
+	var cfg *vault.Config
+
+	code := run(env, func() (*vault.Config, error) {
+		var err error
+		cfg, err = openVault(env)
+
+		return cfg, err
+	}, os.Args[1:])
+
+	if cfg != nil {
+		cfg.Wipe()
+	}
+
+	os.Exit(code)
+}
+
+// loadSettings sets CLOTP_* variables from keys of the settings file, e.g.
+// backend = keepass sets CLOTP_BACKEND. Variables of the environment take
+// precedence, the file is optional.
+func loadSettings(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	f, err := ini.Load(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, key := range f.Section(ini.DefaultSection).Keys() {
+		name := "CLOTP_" + strings.ToUpper(key.Name())
+		if _, ok := os.LookupEnv(name); ok {
+			continue
+		}
+
+		if err := os.Setenv(name, key.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// openAudit opens the audit log chosen by the environment, nil if it's off
+func openAudit() (*audit.Log, error) {
+	path := os.Getenv(auditLogEnv)
+	if path == "" {
+		path = filepath.Join(vault.DefaultDir(), audit.DefaultName)
+	}
+
+	switch mode := os.Getenv(auditEnv); mode {
+	case "", "chain":
+		return audit.Open(path, audit.Opts{Chain: true}), nil
+	case "plain":
+		return audit.Open(path, audit.Opts{}), nil
+	case "off":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown %s: %s, use chain, plain or off", auditEnv, mode)
+	}
+}
+
+// openHistory opens git repository of the vault directory, nil if it isn't
+// a repository or the backend keeps items elsewhere. The shared vault is kept
+// in the repository of its own directory.
+func openHistory() *history.Repo {
+	var path string
+
+	switch os.Getenv(backendEnv) {
+	case "", "ini":
+		path = filepath.Join(vault.DefaultDir(), vault.DefaultConfigName)
+	case "shared":
+		path = sharedVaultPath()
+	default:
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if !history.IsRepository(dir) {
+		return nil
+	}
+
+	return history.Open(dir, filepath.Base(path), history.Opts{Git: os.Getenv(gitEnv)})
+}
+
+// openVault opens the vault of the backend chosen by the environment, its
+// secrets are locked into RAM if $CLOTP_MLOCK is 1
+func openVault(env *Env) (*vault.Config, error) {
+	cfg, err := openBackend(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Getenv(mlockEnv) == "1" {
+		if err := cfg.Lock(); err != nil {
+			fmt.Fprintf(env.Stderr, "warning: secrets aren't locked in memory: %v\n", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+func openBackend(env *Env) (*vault.Config, error) {
+	switch backend := os.Getenv(backendEnv); backend {
+	case "", "ini":
+		return vault.NewConfig(vault.Opts{})
+	case "pass":
+		return vault.NewConfigWithMapper(vault.NewPassMapper(vault.PassOpts{
+			Prefix: os.Getenv(passPrefixEnv),
+			GPG:    os.Getenv(gpgEnv),
+		}))
+	case "keepass":
+		return openKeePass(env)
+	case "shared":
+		m, err := openShared(env)
+		if err != nil {
+			return nil, err
+		}
+
+		return vault.NewConfigWithMapper(m)
+	default:
+		return nil, fmt.Errorf("unknown %s: %s, use ini, pass, keepass or shared", backendEnv, backend)
+	}
+}
+
+// sharedVaultPath returns path of the shared vault, $CLOTP_SHARED_VAULT or
+// the default one in the config directory
+func sharedVaultPath() string {
+	if path := os.Getenv(sharedVaultEnv); path != "" {
+		return path
+	}
+
+	return filepath.Join(vault.DefaultDir(), vault.DefaultSharedName)
+}
+
+// openShared returns mapper of the shared vault opened by the identity of
+// the environment, passphrase of SSH key is asked or read from the passphrase
+// source
+func openShared(env *Env) (*vault.SharedMapper, error) {
+	path := sharedVaultPath()
+
+	identity := os.Getenv(identityEnv)
+	if identity == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("%s is required by shared vault: %w", identityEnv, err)
+		}
+
+		identity = filepath.Join(home, ".ssh", "id_ed25519")
+	}
+
+	data, err := ioutil.ReadFile(identity)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(data)
+
+	ids, err := envelope.ParseIdentities(data, func() ([]byte, error) {
+		return askVaultPassphrase(env, "Identity passphrase", true)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", identity, err)
+	}
+
+	return vault.NewSharedMapper(vault.SharedOpts{Path: path, Identities: ids}), nil
+}
+
+// openKeePass asks the database password or reads it from the passphrase
+// source, it may be left empty if the database is unlocked with a key file only
+func openKeePass(env *Env) (*vault.Config, error) {
+	opts := vault.KeePassOpts{
+		Path:    os.Getenv(keePassDBEnv),
+		KeyFile: os.Getenv(keePassKeyFileEnv),
+	}
+
+	if opts.Path == "" {
+		return nil, fmt.Errorf("%s is required by keepass backend", keePassDBEnv)
+	}
+
+	password, err := askVaultPassphrase(env, "Database password", opts.KeyFile == "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(password) > 0 {
+		opts.Password = password
+	}
+
+	return vault.NewConfigWithMapper(vault.NewKeePassMapper(opts))
+}
+
+// run executes command given by args in the environment, the vault is opened
+// only if the command needs it
+func run(env *Env, open func() (*vault.Config, error), args []string) int {
+	args, err := applyTimeOffset(env, args)
+	if errors.Is(err, flag.ErrHelp) {
+		args = []string{"help"}
+	} else if err != nil {
+		// the error has already been reported
+		return 1
+	}
+
+	if len(args) < 1 {
+		args = []string{CommandListName}
+	}
+
+	open = openOnce(open)
+	env.openVault = open
+
+	// merge driver is given the files to merge
+	if args[0] == CommandMergeName {
+		return NewCommandMerge(env, nil).Execute(args[1:])
+	}
+
+	// recipients are kept by the shared vault file itself
+	if args[0] == CommandVaultName {
+		return NewCommandVault(env, nil).Execute(args[1:])
+	}
+
+	// the vault is opened by the agent only when it's started
+	if args[0] == CommandAgentName {
+		return NewCommandAgent(env, nil).Execute(args[1:])
+	}
+
+	// the agent generates codes without the vault
+	if env.Agent != nil {
+		switch args[0] {
+		case CommandGetName:
+			return NewCommandGet(env, nil).Execute(args[1:])
+		case CommandExecName:
+			return NewCommandExec(env, nil).Execute(args[1:])
+		}
 	}
 
-	switch os.Args[1] {
+	cfg, err := open()
+	if err != nil {
+		fmt.Fprintln(env.Stderr, err)
+		return 1
+	}
+
+	var cmd Command
+
+	switch args[0] {
 	case CommandNewName:
-		cmd = NewCommandNewItem(cfg)
+		cmd = NewCommandNewItem(env, cfg)
 	case CommandListName:
-		cmd = NewCommandList(cfg)
+		cmd = NewCommandList(env, cfg)
 	case CommandGetName:
-		cmd = NewCommandGet(cfg)
+		cmd = NewCommandGet(env, cfg)
+	case CommandPickName:
+		cmd = NewCommandPick(env, cfg)
+	case CommandProvisionName:
+		cmd = NewCommandProvision(env, cfg)
+	case CommandSecretName:
+		cmd = NewCommandSecret(env, cfg)
+	case CommandOCRAName:
+		cmd = NewCommandOCRA(env, cfg)
+	case CommandExecName:
+		cmd = NewCommandExec(env, cfg)
+	case CommandServeName:
+		cmd = NewCommandServe(env, cfg)
+	case CommandExportName:
+		cmd = NewCommandExport(env, cfg)
+	case CommandRestoreName:
+		cmd = NewCommandRestore(env, cfg)
+	case CommandDoctorName:
+		cmd = NewCommandDoctor(env, cfg)
+	case CommandLogName:
+		cmd = NewCommandLog(env, cfg)
+	case CommandHistoryName:
+		cmd = NewCommandHistory(env, cfg)
+	case CommandRevertName:
+		cmd = NewCommandRevert(env, cfg)
+	case CommandSyncName:
+		cmd = NewCommandSync(env, cfg)
 	default:
-		cmd = NewHelpCommand(cfg)
+		cmd = NewHelpCommand(env, cfg)
+	}
+
+	return cmd.Execute(args[1:])
+}
+
+// openOnce returns function opening the vault on the first call only, later
+// calls return the same vault or error
+func openOnce(open func() (*vault.Config, error)) func() (*vault.Config, error) {
+	var (
+		once sync.Once
+		cfg  *vault.Config
+		err  error
+	)
+
+	return func() (*vault.Config, error) {
+		once.Do(func() { cfg, err = open() })
+
+		return cfg, err
+	}
+}
+
+// applyTimeOffset shifts env clock by --time-offset option preceding the
+// command or by $CLOTP_TIME_OFFSET, e.g. by the offset doctor command measured.
+// It returns the command and its arguments, errors are reported to stderr.
+func applyTimeOffset(env *Env, args []string) ([]string, error) {
+	var offset time.Duration
+
+	if v := os.Getenv(timeOffsetEnv); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "invalid %s: %s\n", timeOffsetEnv, v)
+			return nil, err
+		}
+
+		offset = d
+	}
+
+	fs := env.FlagSet("clotp")
+	fs.Usage = func() {}
+	fs.DurationVar(&offset, "time-offset", offset, "Shift the clock codes are generated for")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if offset != 0 {
+		clock := env.Clock
+		env.Clock = totp.ClockFunc(func() time.Time { return clock.Now().Add(offset) })
 	}
 
-	os.Exit(cmd.Execute(os.Args[2:]))
+	return fs.Args(), nil
 }
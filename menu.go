@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var errNothingSelected = errors.New("nothing was selected")
+
+// menus are argument lists of known menu programs reading options from stdin
+// and printing the selected one
+var menus = map[string][]string{
+	"dmenu": {"dmenu", "-i", "-p", "clotp"},
+	"rofi":  {"rofi", "-dmenu", "-i", "-p", "clotp"},
+	"fzf":   {"fzf", "--prompt", "clotp> "},
+}
+
+// clipboards are commands copying stdin to the clipboard, the first one found
+// in PATH is used
+var clipboards = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"pbcopy"},
+	{"clip.exe"},
+}
+
+// menuCommand returns command of a known menu or a shell command line
+func menuCommand(menu string) *exec.Cmd {
+	if args, ok := menus[menu]; ok {
+		return exec.Command(args[0], args[1:]...)
+	}
+
+	return shellCommand(menu)
+}
+
+func shellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+
+	return exec.Command("sh", "-c", line)
+}
+
+// selectOption runs menu with options on its stdin and returns the selected
+// option, menu programs exit with non-zero status when selection is cancelled
+func selectOption(cmd *exec.Cmd, options []string, stderr io.Writer) (string, error) {
+	var out bytes.Buffer
+
+	cmd.Stdin = strings.NewReader(strings.Join(options, "\n") + "\n")
+	cmd.Stdout = &out
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", errNothingSelected
+		}
+
+		return "", err
+	}
+
+	selected := strings.TrimRight(out.String(), "\r\n")
+	if selected == "" {
+		return "", errNothingSelected
+	}
+
+	return selected, nil
+}
+
+// clipboardCommand returns the first available clipboard command
+func clipboardCommand() (*exec.Cmd, error) {
+	for _, args := range clipboards {
+		if args[0] == "wl-copy" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			continue
+		}
+
+		if _, err := exec.LookPath(args[0]); err == nil {
+			return exec.Command(args[0], args[1:]...), nil
+		}
+	}
+
+	return nil, errors.New("no clipboard command found, install wl-copy, xclip or xsel or use -clipboard flag")
+}
+
+func copyToClipboard(cmd *exec.Cmd, s string, stderr io.Writer) error {
+	cmd.Stdin = strings.NewReader(s)
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/mullakhmetov/clotp/securemem"
+)
+
+const (
+	// passphraseFDEnv is file descriptor the vault passphrase is read from
+	passphraseFDEnv = "CLOTP_PASSPHRASE_FD"
+	// passphraseFileEnv is file the vault passphrase is read from
+	passphraseFileEnv = "CLOTP_PASSPHRASE_FILE"
+	// passphraseEnv is the vault passphrase itself
+	passphraseEnv = "CLOTP_PASSPHRASE"
+	// passphraseCommandEnv is shell command printing the vault passphrase,
+	// e.g. pass show clotp
+	passphraseCommandEnv = "CLOTP_PASSPHRASE_COMMAND"
+)
+
+var (
+	errPassphraseMismatch = errors.New("passphrases don't match")
+	errSecretSources      = errors.New("secret can be read from one source only")
+)
+
+// askPassphrase asks passphrase, new passphrases are asked twice
+func askPassphrase(env *Env, message string, confirm bool) ([]byte, error) {
+	var passphrase string
+	if err := env.Prompter.AskOne(&survey.Password{Message: message}, &passphrase, survey.WithValidator(survey.Required)); err != nil {
+		return nil, err
+	}
+
+	if confirm {
+		var repeated string
+		if err := env.Prompter.AskOne(&survey.Password{Message: "Repeat passphrase"}, &repeated); err != nil {
+			return nil, err
+		}
+
+		if repeated != passphrase {
+			return nil, errPassphraseMismatch
+		}
+	}
+
+	return []byte(passphrase), nil
+}
+
+// askVaultPassphrase reads the vault passphrase from the source set by
+// CLOTP_PASSPHRASE_* variables, so the vault is unlocked without a terminal,
+// or asks it. Empty passphrase is accepted if it isn't required.
+func askVaultPassphrase(env *Env, message string, required bool) ([]byte, error) {
+	src := secretSource{
+		fd:      os.Getenv(passphraseFDEnv),
+		file:    os.Getenv(passphraseFileEnv),
+		command: os.Getenv(passphraseCommandEnv),
+	}
+
+	if _, ok := os.LookupEnv(passphraseEnv); ok {
+		src.env = passphraseEnv
+	}
+
+	if src.isSet() {
+		return src.read(env, required)
+	}
+
+	var askOpts []survey.AskOpt
+	if required {
+		askOpts = append(askOpts, survey.WithValidator(survey.Required))
+	}
+
+	var passphrase string
+	if err := env.Prompter.AskOne(&survey.Password{Message: message}, &passphrase, askOpts...); err != nil {
+		return nil, err
+	}
+
+	return []byte(passphrase), nil
+}
+
+// secretSource is a secret read from outside of the terminal, at most one of
+// its fields should be set
+type secretSource struct {
+	// fd is file descriptor number, e.g. 0 for stdin
+	fd   string
+	file string
+	// env is environment variable name
+	env string
+	// command is shell command line printing the secret
+	command string
+}
+
+func (s secretSource) isSet() bool {
+	return s.fd != "" || s.file != "" || s.env != "" || s.command != ""
+}
+
+// read returns the first line of the secret, like pass(1) keeps passwords,
+// empty secret is an error if it's required
+func (s secretSource) read(env *Env, required bool) ([]byte, error) {
+	set := 0
+	for _, v := range []string{s.fd, s.file, s.env, s.command} {
+		if v != "" {
+			set++
+		}
+	}
+
+	if set > 1 {
+		return nil, errSecretSources
+	}
+
+	var (
+		from string
+		data []byte
+		err  error
+	)
+
+	switch {
+	case s.fd != "":
+		from = "file descriptor " + s.fd
+		data, err = readFD(s.fd)
+	case s.file != "":
+		from = s.file
+		data, err = ioutil.ReadFile(s.file)
+	case s.env != "":
+		from = "$" + s.env
+		data = []byte(os.Getenv(s.env))
+	case s.command != "":
+		from = s.command
+		data, err = runSecretCommand(env, s.command)
+	}
+
+	defer securemem.Wipe(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret from %s: %w", from, err)
+	}
+
+	line := data
+	if n := bytes.IndexByte(line, '\n'); n >= 0 {
+		line = line[:n]
+	}
+
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 && required {
+		return nil, fmt.Errorf("empty secret is read from %s", from)
+	}
+
+	return append([]byte(nil), line...), nil
+}
+
+func readFD(fd string) ([]byte, error) {
+	n, err := strconv.Atoi(fd)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid file descriptor: %s", fd)
+	}
+
+	f := os.NewFile(uintptr(n), "fd"+fd)
+	if f == nil {
+		return nil, fmt.Errorf("invalid file descriptor: %s", fd)
+	}
+
+	// standard streams stay open
+	if n > 2 {
+		defer f.Close()
+	}
+
+	return ioutil.ReadAll(f)
+}
+
+// runSecretCommand runs shell command line and returns its output, the
+// command may ask for a password on the terminal, e.g. gpg does
+func runSecretCommand(env *Env, line string) ([]byte, error) {
+	var out bytes.Buffer
+
+	cmd := shellCommand(line)
+	cmd.Stdin = env.Stdin
+	cmd.Stdout = &out
+	cmd.Stderr = env.Stderr
+
+	defer func() { securemem.Wipe(out.Bytes()[:out.Cap()]) }()
+
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), out.Bytes()...), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAskVaultPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passphrase")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "passphrase")
+	if err := ioutil.WriteFile(file, []byte("from file\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		env      map[string]string
+		answers  []interface{}
+		optional bool
+		want     string
+		wantErr  bool
+	}{
+		{name: "asked", answers: []interface{}{"asked"}, want: "asked"},
+		{name: "file", env: map[string]string{passphraseFileEnv: file}, want: "from file"},
+		{name: "env", env: map[string]string{passphraseEnv: "from env"}, want: "from env"},
+		{name: "command", env: map[string]string{passphraseCommandEnv: "echo from command; echo second line"}, want: "from command"},
+		{name: "failed command", env: map[string]string{passphraseCommandEnv: "exit 1"}, wantErr: true},
+		{name: "empty env", env: map[string]string{passphraseEnv: ""}, wantErr: true},
+		{name: "empty optional env", env: map[string]string{passphraseEnv: ""}, optional: true},
+		{name: "empty optional command", env: map[string]string{passphraseCommandEnv: "echo"}, optional: true},
+		{name: "invalid fd", env: map[string]string{passphraseFDEnv: "stdin"}, wantErr: true},
+		{name: "several sources", env: map[string]string{passphraseEnv: "from env", passphraseFileEnv: file}, wantErr: true},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				defer setEnv(k, v)()
+			}
+
+			e := newTestEnv(nil, c.answers...)
+
+			got, err := askVaultPassphrase(e.Env, "Database password", !c.optional)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(got) != c.want {
+				t.Errorf("wrong passphrase, want: %q != got: %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, settingsName)
+
+	if err := loadSettings(path); err != nil {
+		t.Errorf("missing settings file isn't ignored: %v", err)
+	}
+
+	settings := "passphrase_command = \"pass show clotp\"\nbackend = keepass\n"
+	if err := ioutil.WriteFile(path, []byte(settings), 0600); err != nil {
+		panic(err)
+	}
+
+	defer setEnv(passphraseCommandEnv, "")()
+	os.Unsetenv(passphraseCommandEnv)
+
+	// variables of the environment take precedence
+	defer setEnv(backendEnv, "pass")()
+
+	if err := loadSettings(path); err != nil {
+		t.Fatalf("failed to load settings: %v", err)
+	}
+
+	if got := os.Getenv(passphraseCommandEnv); got != "pass show clotp" {
+		t.Errorf("wrong %s: %q", passphraseCommandEnv, got)
+	}
+
+	if got := os.Getenv(backendEnv); got != "pass" {
+		t.Errorf("wrong %s: %q", backendEnv, got)
+	}
+}
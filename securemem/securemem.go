@@ -0,0 +1,53 @@
+// Package securemem keeps secrets in memory which may be wiped after use,
+// locked into RAM so it's never swapped out, and excluded from core dumps.
+//
+// Go strings can't be wiped, so secrets should stay in byte slices from the
+// moment they are read. Copies made by libraries, e.g. by INI parsing or
+// HMAC keying, are out of reach.
+package securemem
+
+import (
+	"errors"
+	"runtime"
+)
+
+// ErrUnsupported is returned by Alloc on platforms without mlock
+var ErrUnsupported = errors.New("locked memory isn't supported on " + runtime.GOOS)
+
+// Wipe zeroes b
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+
+	// keep the writes from being optimized away
+	runtime.KeepAlive(b)
+}
+
+// Alloc returns n bytes of memory locked into RAM, it's outside of Go heap
+// and should be released by Free
+func Alloc(n int) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	return alloc(n)
+}
+
+// Free wipes and releases memory returned by Alloc
+func Free(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	Wipe(b)
+
+	return free(b[:cap(b)])
+}
+
+// DisableCoreDumps makes the process dump no core, so secrets in its memory
+// aren't written to disk if it crashes. On Linux it also keeps other
+// processes of the user from reading its memory via ptrace or /proc.
+func DisableCoreDumps() error {
+	return disableCoreDumps()
+}
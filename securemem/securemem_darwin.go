@@ -0,0 +1,5 @@
+package securemem
+
+func setNotDumpable() error {
+	return nil
+}
@@ -0,0 +1,18 @@
+package securemem
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const prSetDumpable = 4
+
+// setNotDumpable clears the dumpable flag, core dumps are disabled even if
+// RLIMIT_CORE is raised by a pipe core_pattern, and ptrace is denied
+func setNotDumpable() error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetDumpable, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to disable core dumps: %w", errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package securemem
+
+func alloc(int) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+func free([]byte) error {
+	return ErrUnsupported
+}
+
+func disableCoreDumps() error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package securemem
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWipe(t *testing.T) {
+	b := []byte("12345678901234567890")
+	Wipe(b)
+
+	if !bytes.Equal(b, make([]byte, 20)) {
+		t.Errorf("should be zeroed, got: %v", b)
+	}
+}
+
+func TestAlloc(t *testing.T) {
+	b, err := Alloc(100)
+	if err != nil {
+		// e.g. RLIMIT_MEMLOCK is zero in the sandbox
+		t.Skipf("can't allocate locked memory: %v", err)
+	}
+
+	if len(b) != 100 {
+		t.Fatalf("wrong length: %d", len(b))
+	}
+
+	copy(b, "secret")
+
+	if err := Free(b); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+
+	if b, err := Alloc(0); b != nil || err != nil {
+		t.Errorf("nothing should be allocated, got: %v, %v", b, err)
+	}
+}
+
+func TestDisableCoreDumps(t *testing.T) {
+	if err := DisableCoreDumps(); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package securemem
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func alloc(n int) ([]byte, error) {
+	b, err := syscall.Mmap(-1, 0, n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate locked memory: %w", err)
+	}
+
+	if err := syscall.Mlock(b); err != nil {
+		_ = syscall.Munmap(b)
+		return nil, fmt.Errorf("failed to lock memory, check RLIMIT_MEMLOCK (ulimit -l): %w", err)
+	}
+
+	return b, nil
+}
+
+func free(b []byte) error {
+	if err := syscall.Munlock(b); err != nil {
+		return err
+	}
+
+	return syscall.Munmap(b)
+}
+
+// disableCoreDumps lowers the soft limit only, so commands clotp runs may
+// raise it back
+func disableCoreDumps() error {
+	var l syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &l); err != nil {
+		return fmt.Errorf("failed to disable core dumps: %w", err)
+	}
+
+	l.Cur = 0
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &l); err != nil {
+		return fmt.Errorf("failed to disable core dumps: %w", err)
+	}
+
+	return setNotDumpable()
+}
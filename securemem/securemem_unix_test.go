@@ -0,0 +1,24 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package securemem
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestDisableCoreDumps_Limit(t *testing.T) {
+	if err := DisableCoreDumps(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	var l syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &l); err != nil {
+		panic(err)
+	}
+
+	if l.Cur != 0 {
+		t.Errorf("core size soft limit should be zero, got: %+v", l)
+	}
+}
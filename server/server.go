@@ -0,0 +1,239 @@
+// Package server implements local HTTP/JSON API giving access to vault codes
+// for tools which can't run clotp binary, e.g. browser extensions.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+	"github.com/mullakhmetov/clotp/verify"
+)
+
+const (
+	itemsPath = "/v1/items"
+
+	maxBodySize = 1 << 10
+)
+
+// Item is item metadata, secrets are never exposed
+type Item struct {
+	Name      string `json:"name"`
+	Issuer    string `json:"issuer,omitempty"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Step      int    `json:"step"`
+}
+
+// Code is a generated code with its validity interval
+type Code struct {
+	Code       string    `json:"code"`
+	Step       uint64    `json:"step"`
+	ValidFrom  time.Time `json:"valid_from"`
+	ValidUntil time.Time `json:"valid_until"`
+}
+
+type verifyRequest struct {
+	Code string `json:"code"`
+	// User is an optional user of the item, replay protection and lockout
+	// are tracked per item and user
+	User string `json:"user,omitempty"`
+}
+
+type verifyResponse struct {
+	Valid  bool   `json:"valid"`
+	Step   uint64 `json:"step,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New returns API handler serving items of the config to holders of tokens,
+// submitted codes are checked by the validator
+func New(cfg *vault.Config, tokens Tokens, validator *verify.Validator, clock totp.Clock) *Server {
+	return &Server{cfg: cfg, tokens: tokens, validator: validator, clock: clock}
+}
+
+type Server struct {
+	mu        sync.Mutex
+	cfg       *vault.Config
+	tokens    Tokens
+	validator *verify.Validator
+	clock     totp.Clock
+
+	// OnCode is called with the item name for every code served, e.g. to
+	// audit it. Calls are serialized.
+	OnCode func(name string)
+}
+
+// ServeHTTP routes requests:
+//
+//	GET  /v1/items               list items metadata
+//	GET  /v1/items/<name>/code   get current code
+//	POST /v1/items/<name>/verify verify {"code": "...", "user": "..."}
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	items, ok := s.authorize(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	if r.URL.Path == itemsPath {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		s.list(w, items)
+
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, itemsPath+"/"), "/")
+	if !strings.HasPrefix(r.URL.Path, itemsPath+"/") || len(parts) != 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	name, action := parts[0], parts[1]
+
+	// don't reveal existence of items the token isn't allowed to access
+	if !allowed(items, name) {
+		writeError(w, http.StatusNotFound, vault.ErrItemNotFound.Error())
+		return
+	}
+
+	switch {
+	case action == "code" && r.Method == http.MethodGet:
+		s.code(w, name)
+	case action == "verify" && r.Method == http.MethodPost:
+		s.verify(w, r, name)
+	case action == "code" || action == "verify":
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) authorize(r *http.Request) (map[string]struct{}, bool) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return nil, false
+	}
+
+	return s.tokens.lookup(strings.TrimPrefix(h, "Bearer "))
+}
+
+func (s *Server) list(w http.ResponseWriter, allowedItems map[string]struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]Item, 0, len(s.cfg.Items))
+	for _, i := range s.cfg.Items {
+		// OCRA items have no codes to serve
+		if !allowed(allowedItems, i.Name) || i.Suite != "" {
+			continue
+		}
+
+		algorithm := i.Algorithm
+		if algorithm == "" {
+			algorithm = vault.DefaultAlgorithm
+		}
+
+		items = append(items, Item{
+			Name:      i.Name,
+			Issuer:    i.Issuer,
+			Algorithm: algorithm,
+			Digits:    i.CodeDigits(),
+			Step:      i.TimeStep(),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) code(w http.ResponseWriter, name string) {
+	s.mu.Lock()
+	t, err := s.cfg.TOTP(name)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeVaultError(w, err)
+		return
+	}
+
+	c := t.CodeFor(t.CounterAt(s.clock.Now()))
+	t.Wipe()
+
+	if s.OnCode != nil {
+		s.mu.Lock()
+		s.OnCode(name)
+		s.mu.Unlock()
+	}
+
+	writeJSON(w, http.StatusOK, Code{Code: c.Value, Step: c.Counter, ValidFrom: c.ValidFrom, ValidUntil: c.ValidUntil})
+}
+
+func (s *Server) verify(w http.ResponseWriter, r *http.Request, name string) {
+	var req verifyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil || req.Code == "" {
+		writeError(w, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	s.mu.Lock()
+	t, err := s.cfg.TOTP(name)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeVaultError(w, err)
+		return
+	}
+
+	step, err := s.validator.Validate(name+"/"+req.User, t, req.Code)
+	t.Wipe()
+
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, verifyResponse{Valid: true, Step: step})
+	case errors.Is(err, verify.ErrInvalidCode), errors.Is(err, verify.ErrReplayed):
+		writeJSON(w, http.StatusOK, verifyResponse{Valid: false, Reason: err.Error()})
+	case errors.Is(err, verify.ErrLockedOut):
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeVaultError(w http.ResponseWriter, err error) {
+	if errors.Is(err, vault.ErrItemNotFound) {
+		writeError(w, http.StatusNotFound, vault.ErrItemNotFound.Error())
+		return
+	}
+
+	if errors.Is(err, vault.ErrOCRAItem) {
+		writeError(w, http.StatusBadRequest, vault.ErrOCRAItem.Error())
+		return
+	}
+
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
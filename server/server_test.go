@@ -0,0 +1,322 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+	"github.com/mullakhmetov/clotp/vault"
+	"github.com/mullakhmetov/clotp/verify"
+)
+
+func newTestServer() *Server {
+	cfg := &vault.Config{}
+	for _, i := range []*vault.Item{
+		{Name: "rfc6238", Issuer: "RFC", Key: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"), Digits: 8},
+		{Name: "secret", Key: []byte("GE"), Algorithm: "sha256"},
+		{Name: "ocra", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08"},
+	} {
+		if err := cfg.Add(i); err != nil {
+			panic(err)
+		}
+	}
+
+	tokens := Tokens{
+		"all":     {"*": {}},
+		"limited": {"rfc6238": {}},
+	}
+
+	clock := totp.ClockFunc(func() time.Time { return time.Unix(1111111109, 0).UTC() })
+	validator := verify.NewValidator(verify.NewMemoryStore(), verify.Opts{Window: 1, MaxFailures: 2, Clock: clock})
+
+	return New(cfg, tokens, validator, clock)
+}
+
+func do(s *Server, method, path, token, body string) (int, string) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	b, _ := ioutil.ReadAll(rec.Body)
+
+	return rec.Code, strings.TrimSpace(string(b))
+}
+
+func TestServer(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "no token",
+			method:     http.MethodGet,
+			path:       "/v1/items",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":"invalid token"}`,
+		},
+		{
+			name:       "wrong token",
+			method:     http.MethodGet,
+			path:       "/v1/items",
+			token:      "wrong",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":"invalid token"}`,
+		},
+		{
+			name:       "list all",
+			method:     http.MethodGet,
+			path:       "/v1/items",
+			token:      "all",
+			wantStatus: http.StatusOK,
+			wantBody: `[{"name":"rfc6238","issuer":"RFC","algorithm":"sha1","digits":8,"step":30},` +
+				`{"name":"secret","algorithm":"sha256","digits":6,"step":30}]`,
+		},
+		{
+			name:       "list allowed",
+			method:     http.MethodGet,
+			path:       "/v1/items",
+			token:      "limited",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"name":"rfc6238","issuer":"RFC","algorithm":"sha1","digits":8,"step":30}]`,
+		},
+		{
+			name:       "list wrong method",
+			method:     http.MethodPost,
+			path:       "/v1/items",
+			token:      "all",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   `{"error":"method not allowed"}`,
+		},
+		{
+			name:       "code",
+			method:     http.MethodGet,
+			path:       "/v1/items/rfc6238/code",
+			token:      "limited",
+			wantStatus: http.StatusOK,
+			wantBody: `{"code":"07081804","step":37037036,` +
+				`"valid_from":"2005-03-18T01:58:00Z","valid_until":"2005-03-18T01:58:30Z"}`,
+		},
+		{
+			name:       "code not allowed",
+			method:     http.MethodGet,
+			path:       "/v1/items/secret/code",
+			token:      "limited",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"item not found"}`,
+		},
+		{
+			name:       "code unknown item",
+			method:     http.MethodGet,
+			path:       "/v1/items/unknown/code",
+			token:      "all",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"item not found"}`,
+		},
+		{
+			name:       "code ocra item",
+			method:     http.MethodGet,
+			path:       "/v1/items/ocra/code",
+			token:      "all",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"OCRA item has no TOTP codes"}`,
+		},
+		{
+			name:       "verify current",
+			method:     http.MethodPost,
+			path:       "/v1/items/rfc6238/verify",
+			token:      "limited",
+			body:       `{"code":"07081804"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":true,"step":37037036}`,
+		},
+		{
+			name:       "verify next",
+			method:     http.MethodPost,
+			path:       "/v1/items/rfc6238/verify",
+			token:      "limited",
+			body:       `{"code":"14050471"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":true,"step":37037037}`,
+		},
+		{
+			name:       "verify wrong",
+			method:     http.MethodPost,
+			path:       "/v1/items/rfc6238/verify",
+			token:      "limited",
+			body:       `{"code":"12345678"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"valid":false,"reason":"invalid code"}`,
+		},
+		{
+			name:       "verify no code",
+			method:     http.MethodPost,
+			path:       "/v1/items/rfc6238/verify",
+			token:      "limited",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"code is required"}`,
+		},
+		{
+			name:       "verify wrong method",
+			method:     http.MethodGet,
+			path:       "/v1/items/rfc6238/verify",
+			token:      "limited",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   `{"error":"method not allowed"}`,
+		},
+		{
+			name:       "unknown path",
+			method:     http.MethodGet,
+			path:       "/v1/items/rfc6238/secret",
+			token:      "all",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"not found"}`,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			status, body := do(newTestServer(), c.method, c.path, c.token, c.body)
+
+			if status != c.wantStatus {
+				t.Errorf("wrong status, want: %d != got: %d", c.wantStatus, status)
+			}
+
+			if body != c.wantBody {
+				t.Errorf("wrong body, want: %s != got: %s", c.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestServer_VerifySequence(t *testing.T) {
+	s := newTestServer()
+	path := "/v1/items/rfc6238/verify"
+
+	for i, c := range []struct {
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{`{"code":"07081804","user":"alice"}`, http.StatusOK, `{"valid":true,"step":37037036}`},
+		{`{"code":"07081804","user":"alice"}`, http.StatusOK, `{"valid":false,"reason":"code has already been used"}`},
+		// replay protection is per user
+		{`{"code":"07081804","user":"bob"}`, http.StatusOK, `{"valid":true,"step":37037036}`},
+		// the second failure in a row locks alice out
+		{`{"code":"00000000","user":"alice"}`, http.StatusOK, `{"valid":false,"reason":"invalid code"}`},
+		{`{"code":"14050471","user":"alice"}`, http.StatusTooManyRequests, `{"error":"too many failed attempts"}`},
+		{`{"code":"14050471","user":"bob"}`, http.StatusOK, `{"valid":true,"step":37037037}`},
+	} {
+		status, body := do(s, http.MethodPost, path, "limited", c.body)
+
+		if status != c.wantStatus || body != c.wantBody {
+			t.Errorf("request %d: want: %d %s != got: %d %s", i, c.wantStatus, c.wantBody, status, body)
+		}
+	}
+}
+
+func TestServer_OnCode(t *testing.T) {
+	s := newTestServer()
+
+	var served []string
+	s.OnCode = func(name string) { served = append(served, name) }
+
+	do(s, http.MethodGet, "/v1/items/rfc6238/code", "all", "")
+	do(s, http.MethodGet, "/v1/items/secret/code", "limited", "")
+	do(s, http.MethodGet, "/v1/items", "all", "")
+
+	if len(served) != 1 || served[0] != "rfc6238" {
+		t.Errorf("only served codes should be reported, got: %v", served)
+	}
+}
+
+func TestListen(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		addr string
+		err  bool
+	}{
+		{name: "loopback", addr: "127.0.0.1:0"},
+		{name: "localhost", addr: "localhost:0"},
+		{name: "ipv6 loopback", addr: "[::1]:0"},
+		{name: "all interfaces", addr: ":0", err: true},
+		{name: "public", addr: "0.0.0.0:0", err: true},
+		{name: "no port", addr: "127.0.0.1", err: true},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			l, err := Listen(c.addr)
+			if c.err {
+				if err == nil {
+					l.Close()
+					t.Error("error shouldn't be nil")
+				}
+				return
+			}
+
+			if err != nil {
+				// e.g. no IPv6 in the sandbox
+				t.Skipf("can't listen: %v", err)
+			}
+
+			l.Close()
+		})
+	}
+}
+
+func TestListen_Unix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "clotp.sock")
+
+	l, err := Listen(unixPrefix + path)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if _, err := Listen(unixPrefix + path); err == nil {
+		t.Error("socket of a running server is replaced")
+	}
+
+	// closing unix listener removes the socket, keep it like a crash does
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	l, err = Listen(unixPrefix + path)
+	if err != nil {
+		t.Fatalf("stale socket isn't replaced: %v", err)
+	}
+	l.Close()
+
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("data"), 0600); err != nil {
+		panic(err)
+	}
+
+	if _, err := Listen(unixPrefix + file); err == nil {
+		t.Error("regular file is replaced")
+	}
+
+	if data, err := ioutil.ReadFile(file); err != nil || string(data) != "data" {
+		t.Errorf("regular file is changed: %q %v", data, err)
+	}
+}
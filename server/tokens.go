@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const allItems = "*"
+
+// Tokens maps bearer tokens to names of the items they are allowed to access
+type Tokens map[string]map[string]struct{}
+
+// ReadTokens reads tokens file. Every non-empty line which isn't a # comment
+// holds a token followed by comma separated item names or * for all items:
+//
+//	3f9c...e1  github,aws
+//	b81d...07  *
+func ReadTokens(r io.Reader) (Tokens, error) {
+	tokens := make(Tokens)
+	scanner := bufio.NewScanner(r)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("tokens line %d: token and allowed items expected", n)
+		}
+
+		items := make(map[string]struct{})
+		for _, name := range strings.Split(fields[1], ",") {
+			if name != "" {
+				items[name] = struct{}{}
+			}
+		}
+
+		tokens[fields[0]] = items
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// ReadTokensFile reads tokens file, see ReadTokens. The file shouldn't be
+// accessible by group and others.
+func ReadTokensFile(path string) (Tokens, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("tokens file %s is accessible by other users, chmod 600 it", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadTokens(f)
+}
+
+// lookup returns allowed items of the token, compares tokens in constant time
+func (t Tokens) lookup(token string) (map[string]struct{}, bool) {
+	var (
+		found map[string]struct{}
+		ok    bool
+	)
+
+	for k, items := range t {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(token)) == 1 {
+			found, ok = items, true
+		}
+	}
+
+	return found, ok
+}
+
+func allowed(items map[string]struct{}, name string) bool {
+	if _, ok := items[allItems]; ok {
+		return true
+	}
+
+	_, ok := items[name]
+
+	return ok
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTokens(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		input string
+		want  Tokens
+		err   bool
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  Tokens{},
+		},
+		{
+			name:  "comments and items",
+			input: "# comment\n\nt1 *\n  t2   github,aws,  \n",
+			want: Tokens{
+				"t1": {"*": {}},
+				"t2": {"github": {}, "aws": {}},
+			},
+		},
+		{
+			name:  "no items",
+			input: "t1\n",
+			err:   true,
+		},
+		{
+			name:  "extra fields",
+			input: "t1 github aws\n",
+			err:   true,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ReadTokens(strings.NewReader(c.input))
+			if c.err {
+				if err == nil {
+					t.Error("error shouldn't be nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unwanted error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("wrong tokens, want: %+v != got: %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestReadTokensFile_Permissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tokens")
+	if err := ioutil.WriteFile(path, []byte("t1 *\n"), 0644); err != nil {
+		panic(err)
+	}
+
+	if _, err := ReadTokensFile(path); err == nil {
+		t.Error("error shouldn't be nil for world-readable file")
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		panic(err)
+	}
+
+	if _, err := ReadTokensFile(path); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package sntp
+
+import (
+	"net"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+// NewResponder returns SNTP server answering with the clock time, it's meant
+// for tests and for machines without network access to NTP servers
+func NewResponder(clock totp.Clock) *Responder {
+	return &Responder{clock: clock, Stratum: 1}
+}
+
+type Responder struct {
+	clock totp.Clock
+	// Stratum is the server stratum, 0 makes responses kiss-of-death ones
+	Stratum uint8
+}
+
+// Serve answers requests read from conn until it's closed
+func (r *Responder) Serve(conn net.PacketConn) error {
+	buf := make([]byte, 1024)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		received := r.clock.Now()
+
+		if n < packetSize || buf[0]&0x7 != modeClient {
+			continue
+		}
+
+		resp := make([]byte, packetSize)
+		resp[0] = buf[0]&0x38 | modeServer
+		resp[1] = r.Stratum
+		resp[2] = buf[2]
+		resp[3] = 0xec // 2^-20 s precision
+
+		if r.Stratum == 0 {
+			copy(resp[12:], "RATE")
+		} else {
+			copy(resp[12:], "LOCL")
+		}
+
+		copy(resp[24:], buf[40:48])
+		putTime(resp[16:], received)
+		putTime(resp[32:], received)
+		putTime(resp[40:], r.clock.Now())
+
+		if _, err := conn.WriteTo(resp, addr); err != nil {
+			return err
+		}
+	}
+}
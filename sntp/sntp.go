@@ -0,0 +1,150 @@
+// Package sntp implements a Simple Network Time Protocol client (RFC 4330)
+// measuring local clock offset, and a responder to test it against.
+package sntp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+const (
+	// DefaultServer is the NTP pool, see https://www.ntppool.org
+	DefaultServer = "pool.ntp.org"
+	// DefaultTimeout is the default time to wait for the server response
+	DefaultTimeout = 5 * time.Second
+
+	port       = "123"
+	packetSize = 48
+
+	version    = 4
+	modeClient = 3
+	modeServer = 4
+
+	leapUnsynchronized = 3
+)
+
+var (
+	ErrInvalidResponse = errors.New("invalid SNTP response")
+	// ErrKissOfDeath is returned if the server asks to stop querying it
+	ErrKissOfDeath    = errors.New("server refused to respond")
+	ErrUnsynchronized = errors.New("server clock is not synchronized")
+)
+
+// ntpEpoch is the NTP timestamps origin
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Response is the measured offset of the local clock, positive offset means
+// the local clock is behind the server one
+type Response struct {
+	Server  string
+	Offset  time.Duration
+	Delay   time.Duration
+	Stratum uint8
+}
+
+// Opts are query options
+type Opts struct {
+	// Timeout is DefaultTimeout if zero
+	Timeout time.Duration
+	// Clock is the local clock, SystemClock if nil
+	Clock totp.Clock
+}
+
+// Query asks the server its time, the server is host[:port], port 123 is used
+// by default
+func Query(server string, opts Opts) (*Response, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = totp.SystemClock
+	}
+
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, port)
+	}
+
+	conn, err := net.DialTimeout("udp", addr, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(opts.Timeout)); err != nil {
+		return nil, err
+	}
+
+	// the transmit timestamp is random, so the local time isn't revealed and
+	// the response can be matched to the request, see RFC 4330 section 6
+	req := make([]byte, packetSize)
+	req[0] = version<<3 | modeClient
+
+	if _, err := rand.Read(req[40:]); err != nil {
+		return nil, err
+	}
+
+	t1 := opts.Clock.Now()
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, packetSize)
+
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	t4 := opts.Clock.Now()
+
+	if n < packetSize || resp[0]&0x7 != modeServer || string(resp[24:32]) != string(req[40:]) {
+		return nil, ErrInvalidResponse
+	}
+
+	stratum := resp[1]
+	if stratum == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrKissOfDeath, resp[12:16])
+	}
+
+	if resp[0]>>6 == leapUnsynchronized {
+		return nil, ErrUnsynchronized
+	}
+
+	t2, t3 := readTime(resp[32:]), readTime(resp[40:])
+	if t3.Equal(ntpEpoch) {
+		return nil, ErrInvalidResponse
+	}
+
+	return &Response{
+		Server:  server,
+		Offset:  (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		Delay:   t4.Sub(t1) - t3.Sub(t2),
+		Stratum: stratum,
+	}, nil
+}
+
+// readTime reads 64-bit NTP timestamp, seconds since 1900 in the high half
+// and a fraction of the second in the low one
+func readTime(b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(b)
+	frac := binary.BigEndian.Uint32(b[4:])
+
+	return ntpEpoch.Add(time.Duration(sec)*time.Second + time.Duration((uint64(frac)*uint64(time.Second))>>32))
+}
+
+func putTime(b []byte, t time.Time) {
+	d := t.Sub(ntpEpoch)
+	sec := d / time.Second
+	frac := (uint64(d%time.Second) << 32) / uint64(time.Second)
+
+	binary.BigEndian.PutUint32(b, uint32(sec))
+	binary.BigEndian.PutUint32(b[4:], uint32(frac))
+}
@@ -0,0 +1,115 @@
+package sntp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+// startResponder starts responder with the clock shifted by offset, it's
+// stopped by closing the returned connection
+func startResponder(r *Responder) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	go func() { _ = r.Serve(conn) }()
+
+	return conn
+}
+
+func shiftedClock(offset time.Duration) totp.Clock {
+	return totp.ClockFunc(func() time.Time { return time.Now().Add(offset) })
+}
+
+func TestQuery(t *testing.T) {
+	for _, offset := range []time.Duration{0, 42 * time.Second, -90 * time.Minute} {
+		offset := offset
+		t.Run(offset.String(), func(t *testing.T) {
+			conn := startResponder(NewResponder(shiftedClock(offset)))
+			defer conn.Close()
+
+			resp, err := Query(conn.LocalAddr().String(), Opts{})
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+
+			if d := resp.Offset - offset; d < -50*time.Millisecond || d > 50*time.Millisecond {
+				t.Errorf("wrong offset, want: %s != got: %s", offset, resp.Offset)
+			}
+
+			if resp.Delay < 0 || resp.Delay > time.Second {
+				t.Errorf("wrong delay: %s", resp.Delay)
+			}
+
+			if resp.Stratum != 1 {
+				t.Errorf("wrong stratum: %d", resp.Stratum)
+			}
+		})
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	kod := NewResponder(totp.SystemClock)
+	kod.Stratum = 0
+
+	kodConn := startResponder(kod)
+	defer kodConn.Close()
+
+	if _, err := Query(kodConn.LocalAddr().String(), Opts{}); !errors.Is(err, ErrKissOfDeath) {
+		t.Errorf("kiss-of-death error expected, got: %v", err)
+	}
+
+	// the server which doesn't copy the transmit timestamp
+	spoofing, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer spoofing.Close()
+
+	go func() {
+		buf := make([]byte, packetSize)
+		for {
+			_, addr, err := spoofing.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			resp := make([]byte, packetSize)
+			resp[0], resp[1] = version<<3|modeServer, 1
+			putTime(resp[40:], time.Now())
+
+			_, _ = spoofing.WriteTo(resp, addr)
+		}
+	}()
+
+	if _, err := Query(spoofing.LocalAddr().String(), Opts{}); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("invalid response error expected, got: %v", err)
+	}
+
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer silent.Close()
+
+	var netErr net.Error
+	if _, err := Query(silent.LocalAddr().String(), Opts{Timeout: 50 * time.Millisecond}); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("timeout error expected, got: %v", err)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	want := time.Date(2020, 6, 1, 12, 30, 15, 250000000, time.UTC)
+
+	b := make([]byte, 8)
+	putTime(b, want)
+
+	if got := readTime(b); got.Sub(want) > time.Microsecond || want.Sub(got) > time.Microsecond {
+		t.Errorf("want: %s != got: %s", want, got)
+	}
+}
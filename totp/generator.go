@@ -0,0 +1,96 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+const maxDigits = 10
+
+var pow10 = [maxDigits]uint32{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000, 1000000000}
+
+var ErrInvalidOpts = errors.New("invalid OTP options")
+
+// NewGenerator returns Generator with HMAC keyed once by the secret
+func NewGenerator(opts Opts) (*Generator, error) {
+	if opts.Digits < 1 || opts.Digits > maxDigits {
+		return nil, fmt.Errorf("%w: digits should be between 1 and %d", ErrInvalidOpts, maxDigits)
+	}
+
+	if opts.Algorithm == nil {
+		return nil, fmt.Errorf("%w: no algorithm", ErrInvalidOpts)
+	}
+
+	mac := hmac.New(opts.Algorithm, opts.Secret)
+
+	return &Generator{
+		mac:    mac,
+		digits: opts.Digits,
+		sum:    make([]byte, 0, mac.Size()),
+	}, nil
+}
+
+// Generator generates OTP values without allocations on the hot path. It's
+// meant to be reused for many counters, e.g. to check a verification window.
+// Generator isn't safe for concurrent use.
+type Generator struct {
+	mac    hash.Hash
+	digits int
+	msg    [8]byte
+	sum    []byte
+}
+
+// Generate returns otp value based on given counter
+func (g *Generator) Generate(counter uint64) (string, error) {
+	var buf [maxDigits]byte
+
+	b, err := g.AppendCode(buf[:0], counter)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// AppendCode appends otp value based on given counter to dst
+func (g *Generator) AppendCode(dst []byte, counter uint64) ([]byte, error) {
+	binary.BigEndian.PutUint64(g.msg[:], counter)
+
+	g.mac.Reset()
+	if _, err := g.mac.Write(g.msg[:]); err != nil {
+		return dst, err
+	}
+
+	g.sum = g.mac.Sum(g.sum[:0])
+
+	return appendDigits(dst, truncateValue(g.sum), g.digits), nil
+}
+
+// truncateValue performs dynamic truncation of HMAC result, see RFC 4226 section 5.3
+func truncateValue(hmacResult []byte) uint32 {
+	offset := int(hmacResult[len(hmacResult)-1] & 0xf)
+
+	return binary.BigEndian.Uint32(hmacResult[offset:offset+4]) & 0x7fffffff
+}
+
+// appendDigits appends last n decimal digits of value to dst, zero-padded
+func appendDigits(dst []byte, value uint32, n int) []byte {
+	if n < maxDigits {
+		value %= pow10[n]
+	}
+
+	l := len(dst)
+	for i := 0; i < n; i++ {
+		dst = append(dst, '0')
+	}
+
+	for i := len(dst) - 1; i >= l && value > 0; i-- {
+		dst[i] = byte('0' + value%10)
+		value /= 10
+	}
+
+	return dst
+}
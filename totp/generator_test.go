@@ -0,0 +1,128 @@
+package totp
+
+import (
+	"crypto/sha1" //nolint:gosec // used in hmac only, see RFC 4226 B.2. section
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"testing"
+)
+
+func TestGenerator_Generate(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		algorithm func() hash.Hash
+		digits    int
+	}{
+		{name: "sha1 1 digit", algorithm: sha1.New, digits: 1},
+		{name: "sha1 6 digits", algorithm: sha1.New, digits: 6},
+		{name: "sha256 8 digits", algorithm: sha256.New, digits: 8},
+		{name: "sha512 10 digits", algorithm: sha512.New, digits: 10},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			opts := Opts{Digits: c.digits, Secret: []byte("12345678901234567890"), Algorithm: c.algorithm}
+			otp := NewOTP(opts)
+
+			g, err := NewGenerator(opts)
+			if err != nil {
+				panic(err)
+			}
+
+			for _, counter := range []uint64{0, 1, 2, 37037036, 37037037, 41152263, 1<<63 + 1} {
+				got, err := g.Generate(counter)
+				if err != nil {
+					t.Errorf("unwanted error: %v", err)
+					return
+				}
+
+				if want := otp.Generate(counter); got != want {
+					t.Errorf("wrong otp value for %d counter, want: %s != got: %s", counter, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerator_AppendCode(t *testing.T) {
+	g, err := NewGenerator(Opts{Digits: 6, Secret: []byte("12345678901234567890"), Algorithm: sha1.New})
+	if err != nil {
+		panic(err)
+	}
+
+	got, err := g.AppendCode([]byte("code: "), 1)
+	if err != nil {
+		panic(err)
+	}
+
+	if string(got) != "code: 287082" {
+		t.Errorf("wrong appended value: %s", got)
+	}
+
+	buf := make([]byte, 0, maxDigits)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = g.AppendCode(buf[:0], 1)
+	})
+
+	if allocs != 0 {
+		t.Errorf("AppendCode shouldn't allocate, got %.1f allocs", allocs)
+	}
+}
+
+func TestNewGenerator_InvalidOpts(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		opts Opts
+	}{
+		{name: "zero digits", opts: Opts{Digits: 0, Algorithm: sha1.New}},
+		{name: "too many digits", opts: Opts{Digits: 11, Algorithm: sha1.New}},
+		{name: "no algorithm", opts: Opts{Digits: 6}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := NewGenerator(c.opts); !errors.Is(err, ErrInvalidOpts) {
+				t.Errorf("error should match with %v, got: %v", ErrInvalidOpts, err)
+			}
+		})
+	}
+}
+
+func BenchmarkOTP_Generate(b *testing.B) {
+	otp := NewOTP(Opts{Digits: 6, Secret: []byte("12345678901234567890"), Algorithm: sha1.New})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		otp.Generate(uint64(i))
+	}
+}
+
+func BenchmarkGenerator_Generate(b *testing.B) {
+	g, err := NewGenerator(Opts{Digits: 6, Secret: []byte("12345678901234567890"), Algorithm: sha1.New})
+	if err != nil {
+		panic(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := g.Generate(uint64(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerator_AppendCode(b *testing.B) {
+	g, err := NewGenerator(Opts{Digits: 6, Secret: []byte("12345678901234567890"), Algorithm: sha1.New})
+	if err != nil {
+		panic(err)
+	}
+
+	buf := make([]byte, 0, maxDigits)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if buf, err = g.AppendCode(buf[:0], uint64(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
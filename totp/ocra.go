@@ -0,0 +1,326 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // used in hmac only, see RFC 4226 B.2. section
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ocraQuestionLength = 128
+
+var ErrInvalidSuite = errors.New("invalid OCRA suite")
+
+// ChallengeFormat is the format of an OCRA challenge (question)
+type ChallengeFormat byte
+
+const (
+	ChallengeAlpha   ChallengeFormat = 'A'
+	ChallengeNumeric ChallengeFormat = 'N'
+	ChallengeHex     ChallengeFormat = 'H'
+)
+
+// Suite is a parsed OCRA suite, see RFC 6287 section 6
+type Suite struct {
+	raw string
+
+	Algorithm func() hash.Hash
+	Digits    int
+
+	Counter bool
+
+	ChallengeFormat ChallengeFormat
+	ChallengeLength int
+
+	PIN func() hash.Hash
+
+	SessionLength int
+
+	TimeStep time.Duration
+}
+
+// ParseSuite parses OCRA suite string like OCRA-1:HOTP-SHA1-6:QN08
+func ParseSuite(s string) (*Suite, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[0] != "OCRA-1" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSuite, s)
+	}
+
+	suite := &Suite{raw: s}
+	if err := suite.parseCryptoFunction(parts[1]); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSuite, s, err)
+	}
+
+	if err := suite.parseDataInput(parts[2]); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSuite, s, err)
+	}
+
+	return suite, nil
+}
+
+// String returns the original suite string
+func (s *Suite) String() string {
+	return s.raw
+}
+
+func (s *Suite) parseCryptoFunction(f string) error {
+	parts := strings.Split(f, "-")
+	if len(parts) != 3 || parts[0] != "HOTP" {
+		return fmt.Errorf("unknown crypto function %s", f)
+	}
+
+	h, err := ocraHash(parts[1])
+	if err != nil {
+		return err
+	}
+
+	digits, err := strconv.Atoi(parts[2])
+	if err != nil || digits < 4 || digits > 10 {
+		return fmt.Errorf("invalid truncation length %s", parts[2])
+	}
+
+	s.Algorithm = h
+	s.Digits = digits
+
+	return nil
+}
+
+func (s *Suite) parseDataInput(d string) error {
+	parts := strings.Split(d, "-")
+
+	if parts[0] == "C" {
+		s.Counter = true
+		parts = parts[1:]
+	}
+
+	if len(parts) == 0 || len(parts[0]) != 4 || parts[0][0] != 'Q' {
+		return fmt.Errorf("challenge is required")
+	}
+
+	switch f := ChallengeFormat(parts[0][1]); f {
+	case ChallengeAlpha, ChallengeNumeric, ChallengeHex:
+		s.ChallengeFormat = f
+	default:
+		return fmt.Errorf("unknown challenge format %c", f)
+	}
+
+	l, err := strconv.Atoi(parts[0][2:])
+	if err != nil || l < 4 || l > 64 {
+		return fmt.Errorf("invalid challenge length %s", parts[0][2:])
+	}
+
+	s.ChallengeLength = l
+
+	for _, p := range parts[1:] {
+		switch {
+		case strings.HasPrefix(p, "P") && s.PIN == nil && s.SessionLength == 0 && s.TimeStep == 0:
+			h, err := ocraHash(p[1:])
+			if err != nil {
+				return err
+			}
+
+			s.PIN = h
+		case strings.HasPrefix(p, "S") && len(p) == 4 && s.SessionLength == 0 && s.TimeStep == 0:
+			l, err := strconv.Atoi(p[1:])
+			if err != nil || l <= 0 {
+				return fmt.Errorf("invalid session length %s", p[1:])
+			}
+
+			s.SessionLength = l
+		case strings.HasPrefix(p, "T") && s.TimeStep == 0:
+			step, err := parseTimeStep(p[1:])
+			if err != nil {
+				return err
+			}
+
+			s.TimeStep = step
+		default:
+			return fmt.Errorf("unexpected data input %s", p)
+		}
+	}
+
+	return nil
+}
+
+func parseTimeStep(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid time step %s", s)
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid time step %s", s)
+	}
+
+	switch unit := s[len(s)-1]; {
+	case unit == 'S' && n <= 59:
+		return time.Duration(n) * time.Second, nil
+	case unit == 'M' && n <= 59:
+		return time.Duration(n) * time.Minute, nil
+	case unit == 'H' && n <= 48:
+		return time.Duration(n) * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("invalid time step %s", s)
+	}
+}
+
+func ocraHash(name string) (func() hash.Hash, error) {
+	switch name {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unknown hash function %s", name)
+	}
+}
+
+// OCRAInput holds data inputs for OCRA computation. Only the inputs declared
+// by the suite are used, the others are ignored.
+type OCRAInput struct {
+	Counter   uint64
+	Challenge string
+	// PIN is hashed with the suite's PIN hash function
+	PIN     string
+	Session []byte
+	Time    time.Time
+}
+
+// NewOCRA returns OCRA object for given suite and raw (decoded) secret
+func NewOCRA(suite *Suite, secret []byte) *OCRA {
+	return &OCRA{Suite: suite, Secret: secret}
+}
+
+type OCRA struct {
+	Suite  *Suite
+	Secret []byte
+}
+
+// Wipe zeroes the secret, the OCRA can't be used after that
+func (o *OCRA) Wipe() {
+	for i := range o.Secret {
+		o.Secret[i] = 0
+	}
+}
+
+// Generate returns OCRA response for given inputs, see RFC 6287 section 5
+func (o *OCRA) Generate(in OCRAInput) (string, error) {
+	msg, err := o.message(in)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(o.Suite.Algorithm, o.Secret)
+	if _, err := mac.Write(msg); err != nil {
+		return "", err
+	}
+
+	return truncate(mac.Sum(nil), o.Suite.Digits), nil
+}
+
+func (o *OCRA) message(in OCRAInput) ([]byte, error) {
+	s := o.Suite
+
+	msg := make([]byte, 0, len(s.raw)+1+8+ocraQuestionLength+sha512.Size+s.SessionLength+8)
+	msg = append(msg, s.raw...)
+	msg = append(msg, 0)
+
+	if s.Counter {
+		msg = appendUint64(msg, in.Counter)
+	}
+
+	q, err := s.challenge(in.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	msg = append(msg, q...)
+
+	if s.PIN != nil {
+		h := s.PIN()
+		if _, err := h.Write([]byte(in.PIN)); err != nil {
+			return nil, err
+		}
+
+		msg = h.Sum(msg)
+	}
+
+	if s.SessionLength > 0 {
+		if len(in.Session) > s.SessionLength {
+			return nil, fmt.Errorf("session information is longer than %d bytes", s.SessionLength)
+		}
+
+		session := make([]byte, s.SessionLength)
+		copy(session[s.SessionLength-len(in.Session):], in.Session)
+		msg = append(msg, session...)
+	}
+
+	if s.TimeStep > 0 {
+		msg = appendUint64(msg, uint64(in.Time.Unix()/int64(s.TimeStep/time.Second)))
+	}
+
+	return msg, nil
+}
+
+// challenge returns 128-bytes right-padded question. Mutual challenge-response
+// mode concatenates client and server challenges, so up to twice the suite's
+// challenge length is accepted.
+func (s *Suite) challenge(q string) ([]byte, error) {
+	if len(q) < 4 || len(q) > 2*s.ChallengeLength {
+		return nil, fmt.Errorf("challenge length should be between 4 and %d", 2*s.ChallengeLength)
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+
+	switch s.ChallengeFormat {
+	case ChallengeAlpha:
+		b = []byte(q)
+	case ChallengeNumeric:
+		n, ok := new(big.Int).SetString(q, 10)
+		if !ok {
+			return nil, fmt.Errorf("numeric challenge expected: %s", q)
+		}
+
+		b, err = hexRightPadded(n.Text(16))
+	case ChallengeHex:
+		b, err = hexRightPadded(q)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid challenge %s: %w", q, err)
+	}
+
+	out := make([]byte, ocraQuestionLength)
+	copy(out, b)
+
+	return out, nil
+}
+
+func hexRightPadded(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		s += "0"
+	}
+
+	return hex.DecodeString(s)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+
+	return append(b, buf[:]...)
+}
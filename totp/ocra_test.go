@@ -0,0 +1,245 @@
+package totp
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	ocraKey20 = "3132333435363738393031323334353637383930"
+	ocraKey32 = "3132333435363738393031323334353637383930313233343536373839303132"
+	ocraKey64 = "31323334353637383930313233343536373839303132333435363738393031323334353637383930" +
+		"313233343536373839303132333435363738393031323334"
+)
+
+func TestParseSuite(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		suite string
+		err   bool
+	}{
+		{name: "challenge only", suite: "OCRA-1:HOTP-SHA1-6:QN08"},
+		{name: "counter and pin", suite: "OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1"},
+		{name: "session", suite: "OCRA-1:HOTP-SHA512-8:QA10-S064"},
+		{name: "timestamp", suite: "OCRA-1:HOTP-SHA512-8:QN08-T1M"},
+		{name: "all inputs", suite: "OCRA-1:HOTP-SHA1-6:C-QH40-PSHA256-S128-T48H"},
+		{name: "wrong version", suite: "OCRA-2:HOTP-SHA1-6:QN08", err: true},
+		{name: "wrong hash", suite: "OCRA-1:HOTP-MD5-6:QN08", err: true},
+		{name: "wrong digits", suite: "OCRA-1:HOTP-SHA1-11:QN08", err: true},
+		{name: "no challenge", suite: "OCRA-1:HOTP-SHA1-6:C", err: true},
+		{name: "wrong challenge format", suite: "OCRA-1:HOTP-SHA1-6:QX08", err: true},
+		{name: "wrong challenge length", suite: "OCRA-1:HOTP-SHA1-6:QN65", err: true},
+		{name: "wrong time step", suite: "OCRA-1:HOTP-SHA1-6:QN08-T60S", err: true},
+		{name: "wrong order", suite: "OCRA-1:HOTP-SHA1-6:QN08-T1M-PSHA1", err: true},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			s, err := ParseSuite(c.suite)
+			if c.err {
+				if !errors.Is(err, ErrInvalidSuite) {
+					t.Errorf("error should match with %v, got: %v", ErrInvalidSuite, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("unwanted error: %v", err)
+				return
+			}
+
+			if s.String() != c.suite {
+				t.Errorf("wrong suite string: %s", s)
+			}
+		})
+	}
+}
+
+// Test vectors from RFC 6287 Appendix C
+func TestOCRA_Generate(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		suite      string
+		key        string
+		inputs     []OCRAInput
+		wantValues []string
+	}{
+		{
+			name:  "one-way challenge",
+			suite: "OCRA-1:HOTP-SHA1-6:QN08",
+			key:   ocraKey20,
+			inputs: []OCRAInput{
+				{Challenge: "00000000"},
+				{Challenge: "11111111"},
+				{Challenge: "22222222"},
+				{Challenge: "33333333"},
+				{Challenge: "44444444"},
+				{Challenge: "55555555"},
+				{Challenge: "66666666"},
+				{Challenge: "77777777"},
+				{Challenge: "88888888"},
+				{Challenge: "99999999"},
+			},
+			wantValues: []string{
+				"237653", "243178", "653583", "740991", "608993",
+				"388898", "816933", "224598", "750600", "294470",
+			},
+		},
+		{
+			name:  "counter and pin",
+			suite: "OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1",
+			key:   ocraKey32,
+			inputs: []OCRAInput{
+				{Counter: 0, Challenge: "12345678", PIN: "1234"},
+				{Counter: 1, Challenge: "12345678", PIN: "1234"},
+				{Counter: 2, Challenge: "12345678", PIN: "1234"},
+				{Counter: 3, Challenge: "12345678", PIN: "1234"},
+				{Counter: 4, Challenge: "12345678", PIN: "1234"},
+				{Counter: 5, Challenge: "12345678", PIN: "1234"},
+				{Counter: 6, Challenge: "12345678", PIN: "1234"},
+				{Counter: 7, Challenge: "12345678", PIN: "1234"},
+				{Counter: 8, Challenge: "12345678", PIN: "1234"},
+				{Counter: 9, Challenge: "12345678", PIN: "1234"},
+			},
+			wantValues: []string{
+				"65347737", "86775851", "78192410", "71565254", "10104329",
+				"65983500", "70069104", "91771096", "75011558", "08522129",
+			},
+		},
+		{
+			name:  "pin",
+			suite: "OCRA-1:HOTP-SHA256-8:QN08-PSHA1",
+			key:   ocraKey32,
+			inputs: []OCRAInput{
+				{Challenge: "00000000", PIN: "1234"},
+				{Challenge: "11111111", PIN: "1234"},
+				{Challenge: "22222222", PIN: "1234"},
+				{Challenge: "33333333", PIN: "1234"},
+				{Challenge: "44444444", PIN: "1234"},
+			},
+			wantValues: []string{"83238735", "01501458", "17957585", "86776967", "86807031"},
+		},
+		{
+			name:  "counter sha512",
+			suite: "OCRA-1:HOTP-SHA512-8:C-QN08",
+			key:   ocraKey64,
+			inputs: []OCRAInput{
+				{Counter: 0, Challenge: "00000000"},
+				{Counter: 1, Challenge: "11111111"},
+				{Counter: 2, Challenge: "22222222"},
+				{Counter: 3, Challenge: "33333333"},
+				{Counter: 4, Challenge: "44444444"},
+				{Counter: 5, Challenge: "55555555"},
+				{Counter: 6, Challenge: "66666666"},
+				{Counter: 7, Challenge: "77777777"},
+				{Counter: 8, Challenge: "88888888"},
+				{Counter: 9, Challenge: "99999999"},
+			},
+			wantValues: []string{
+				"07016083", "63947962", "70123924", "25341727", "33203315",
+				"34205738", "44343969", "51946085", "20403879", "31409299",
+			},
+		},
+		{
+			name:  "timestamp",
+			suite: "OCRA-1:HOTP-SHA512-8:QN08-T1M",
+			key:   ocraKey64,
+			inputs: []OCRAInput{
+				{Challenge: "00000000", Time: time.Unix(0x132d0b6*60, 0)},
+				{Challenge: "11111111", Time: time.Unix(0x132d0b6*60, 0)},
+				{Challenge: "22222222", Time: time.Unix(0x132d0b6*60, 0)},
+				{Challenge: "33333333", Time: time.Unix(0x132d0b6*60, 0)},
+				{Challenge: "44444444", Time: time.Unix(0x132d0b6*60+59, 0)},
+			},
+			wantValues: []string{"95209754", "55907591", "22048402", "24218844", "36209546"},
+		},
+		{
+			name:  "mutual challenge",
+			suite: "OCRA-1:HOTP-SHA256-8:QA08",
+			key:   ocraKey32,
+			inputs: []OCRAInput{
+				{Challenge: "CLI22220SRV11110"},
+				{Challenge: "CLI22221SRV11111"},
+			},
+			wantValues: []string{"28247970", "01984843"},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if len(c.inputs) != len(c.wantValues) {
+				panic("invalid test table: inputs and values len mismatch")
+			}
+
+			suite, err := ParseSuite(c.suite)
+			if err != nil {
+				panic(err)
+			}
+
+			key, err := hex.DecodeString(c.key)
+			if err != nil {
+				panic(err)
+			}
+
+			ocra := NewOCRA(suite, key)
+
+			for i, in := range c.inputs {
+				got, err := ocra.Generate(in)
+				if err != nil {
+					t.Errorf("unwanted error: %v", err)
+					continue
+				}
+
+				if got != c.wantValues[i] {
+					t.Errorf("wrong ocra value for %+v input, want: %s != got: %s", in, c.wantValues[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestOCRA_Generate_InvalidInput(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		suite string
+		input OCRAInput
+	}{
+		{
+			name:  "short challenge",
+			suite: "OCRA-1:HOTP-SHA1-6:QN08",
+			input: OCRAInput{Challenge: "123"},
+		},
+		{
+			name:  "long challenge",
+			suite: "OCRA-1:HOTP-SHA1-6:QN08",
+			input: OCRAInput{Challenge: "12345678901234567"},
+		},
+		{
+			name:  "non-numeric challenge",
+			suite: "OCRA-1:HOTP-SHA1-6:QN08",
+			input: OCRAInput{Challenge: "12ab"},
+		},
+		{
+			name:  "non-hex challenge",
+			suite: "OCRA-1:HOTP-SHA1-6:QH08",
+			input: OCRAInput{Challenge: "12xy"},
+		},
+		{
+			name:  "long session",
+			suite: "OCRA-1:HOTP-SHA1-6:QN08-S002",
+			input: OCRAInput{Challenge: "1234", Session: []byte("123")},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			suite, err := ParseSuite(c.suite)
+			if err != nil {
+				panic(err)
+			}
+
+			if _, err := NewOCRA(suite, []byte("12345678901234567890")).Generate(c.input); err == nil {
+				t.Error("error shouldn't be nil")
+			}
+		})
+	}
+}
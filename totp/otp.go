@@ -1,25 +1,23 @@
 package totp
 
 import (
-	"bytes"
 	"crypto/hmac"
 	"crypto/sha1" //nolint:gosec // used in hmac only, see RFC 4226 B.2. section
 	"encoding/binary"
-	"fmt"
 	"hash"
-	"math"
 )
 
 const defaultDigits = 6
 
 type Opts struct {
-	Digits    int
-	Secret    string
+	Digits int
+	// Secret is the raw secret, it's used as is, not copied
+	Secret    []byte
 	Algorithm func() hash.Hash
 }
 
 // DefaultOTP returns 6-digits HMAC-SHA-1 OTP based on given secret and counter
-func NewDefaultOTP(secret string) *OTP {
+func NewDefaultOTP(secret []byte) *OTP {
 	return &OTP{
 		Opts{Digits: defaultDigits, Secret: secret, Algorithm: sha1.New},
 	}
@@ -36,34 +34,31 @@ type OTP struct {
 
 // Generate returns otp value based on given counter
 func (o *OTP) Generate(counter uint64) string {
-	mac := hmac.New(o.Algorithm, o.secret())
-	if _, err := mac.Write(itob(counter)); err != nil {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(o.Algorithm, o.Secret)
+	if _, err := mac.Write(msg[:]); err != nil {
 		panic(err)
 	}
 
-	hmacResult := mac.Sum(nil)
-	offset := int(hmacResult[len(hmacResult)-1] & 0xf)
-	code := ((int(hmacResult[offset]) & 0x7f) << 24) |
-		((int(hmacResult[offset+1] & 0xff)) << 16) |
-		((int(hmacResult[offset+2] & 0xff)) << 8) |
-		(int(hmacResult[offset+3]) & 0xff)
-
-	value := code % int(math.Pow10(o.Digits))
-
-	return fmt.Sprintf(fmt.Sprintf("%%0%dd", o.Digits), value)
+	return truncate(mac.Sum(nil), o.Digits)
 }
 
-func (o *OTP) secret() []byte {
-	return []byte(o.Secret)
+// Generator returns reusable allocation-free generator with the same options
+func (o *OTP) Generator() (*Generator, error) {
+	return NewGenerator(o.Opts)
 }
 
-func itob(i uint64) []byte {
-	buf := new(bytes.Buffer)
+// truncate performs dynamic truncation of HMAC result and returns digits
+// long value, see RFC 4226 section 5.3
+func truncate(hmacResult []byte, digits int) string {
+	return string(appendDigits(make([]byte, 0, digits), truncateValue(hmacResult), digits))
+}
 
-	err := binary.Write(buf, binary.BigEndian, i)
-	if err != nil {
-		panic(err)
+// Wipe zeroes the secret, the OTP can't be used after that
+func (o *OTP) Wipe() {
+	for i := range o.Secret {
+		o.Secret[i] = 0
 	}
-
-	return buf.Bytes()
 }
@@ -1,6 +1,7 @@
 package totp
 
 import (
+	"bytes"
 	"crypto/sha1" //nolint:gosec // used in hmac only, see RFC 4226 B.2. section
 	"crypto/sha256"
 	"crypto/sha512"
@@ -19,7 +20,7 @@ func TestGenerate(t *testing.T) {
 			opts: Opts{
 				Digits:    3,
 				Algorithm: sha1.New,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 			},
 			counters: []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
 			wantValues: []string{
@@ -40,7 +41,7 @@ func TestGenerate(t *testing.T) {
 			opts: Opts{
 				Digits:    6,
 				Algorithm: sha1.New,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 			},
 			counters: []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
 			wantValues: []string{
@@ -61,7 +62,7 @@ func TestGenerate(t *testing.T) {
 			opts: Opts{
 				Digits:    8,
 				Algorithm: sha256.New,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 			},
 			counters: []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
 			wantValues: []string{
@@ -82,7 +83,7 @@ func TestGenerate(t *testing.T) {
 			opts: Opts{
 				Digits:    8,
 				Algorithm: sha512.New,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 			},
 			counters: []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
 			wantValues: []string{
@@ -117,27 +118,27 @@ func TestGenerate(t *testing.T) {
 }
 
 func TestNewOTP(t *testing.T) {
-	opts := Opts{Digits: 1, Secret: "1", Algorithm: sha1.New}
+	opts := Opts{Digits: 1, Secret: []byte("1"), Algorithm: sha1.New}
 	otp := NewOTP(opts)
 
 	if otp.Digits != opts.Digits {
 		t.Errorf("wrong digits: %d", otp.Digits)
 	}
 
-	if otp.Secret != opts.Secret {
+	if !bytes.Equal(otp.Secret, opts.Secret) {
 		t.Errorf("wrong secret: %s", otp.Secret)
 	}
 }
 
 func TestNewDefaultOTP(t *testing.T) {
-	secret := "12345678901234567890"
+	secret := []byte("12345678901234567890")
 	otp := NewDefaultOTP(secret)
 
 	if otp.Digits != defaultDigits {
 		t.Errorf("wrong digits: %d", otp.Digits)
 	}
 
-	if otp.Secret != secret {
+	if !bytes.Equal(otp.Secret, secret) {
 		t.Errorf("wrong secret: %s", otp.Secret)
 	}
 
@@ -149,3 +150,13 @@ func TestNewDefaultOTP(t *testing.T) {
 		t.Errorf("wrong otp value for default otp: %s", got)
 	}
 }
+
+func TestOTP_Wipe(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	otp := NewDefaultOTP(secret)
+	otp.Wipe()
+
+	if !bytes.Equal(secret, make([]byte, len(secret))) {
+		t.Errorf("secret should be zeroed, got: %v", secret)
+	}
+}
@@ -0,0 +1,24 @@
+package totp
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// minSecretSize is the minimal shared secret length, see RFC 4226 section 4 R6
+const minSecretSize = 16
+
+// GenerateSecret returns a new random shared secret of given size in bytes.
+// RFC 4226 recommends the size to match the HMAC output size, e.g. 20 bytes for SHA-1.
+func GenerateSecret(size int) ([]byte, error) {
+	if size < minSecretSize {
+		return nil, fmt.Errorf("secret should be at least %d bytes long", minSecretSize)
+	}
+
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	return secret, nil
+}
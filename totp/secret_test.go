@@ -0,0 +1,30 @@
+package totp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	s1, err := GenerateSecret(20)
+	if err != nil {
+		panic(err)
+	}
+
+	s2, err := GenerateSecret(20)
+	if err != nil {
+		panic(err)
+	}
+
+	if len(s1) != 20 || len(s2) != 20 {
+		t.Errorf("wrong secret length: %d, %d", len(s1), len(s2))
+	}
+
+	if bytes.Equal(s1, s2) {
+		t.Errorf("secrets should differ")
+	}
+
+	if _, err := GenerateSecret(minSecretSize - 1); err == nil {
+		t.Error("error shouldn't be nil for short secret")
+	}
+}
@@ -1,31 +1,202 @@
 package totp
 
 import (
+	"crypto/subtle"
+	"math"
 	"time"
 )
 
 const defaultTimeStep = 30
 
+// Clock is a source of the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to use ordinary function as a Clock
+type ClockFunc func() time.Time
+
+// Now calls f()
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is a Clock backed by time.Now
+var SystemClock Clock = ClockFunc(time.Now)
+
 // DefaultOTP returns 6-digits HMAC-SHA-1 OTP based on given secret and counter
-func NewDefaultTOTP(secret string) *TOTP {
-	return &TOTP{NewDefaultOTP(secret), defaultTimeStep}
+func NewDefaultTOTP(secret []byte) *TOTP {
+	return &TOTP{OTP: NewDefaultOTP(secret), TimeStep: defaultTimeStep}
 }
 
 // NewOTP returns OTP object
 func NewTOTP(opts Opts, timestep int) *TOTP {
-	return &TOTP{NewOTP(opts), timestep}
+	return &TOTP{OTP: NewOTP(opts), TimeStep: timestep}
 }
 
 type TOTP struct {
 	*OTP
 	TimeStep int
+	// T0 is the Unix time to start counting time steps from, see RFC 6238 section 4
+	T0 int64
+	// Clock is used by Now, SystemClock if nil
+	Clock Clock
 }
 
+// Now returns otp value for the current time of the TOTP's clock
 func (t *TOTP) Now() string {
-	ts := time.Now().Unix()
-	return t.At(ts)
+	return t.AtTime(t.clock().Now())
 }
 
+// At returns otp value for given Unix time
 func (t *TOTP) At(ts int64) string {
-	return t.Generate(uint64(ts / int64(t.TimeStep)))
+	return t.Generate(t.Counter(ts))
+}
+
+// AtTime returns otp value for given time
+func (t *TOTP) AtTime(tm time.Time) string {
+	return t.At(tm.Unix())
+}
+
+// Counter returns time step number for given Unix time. Timestamps before T0
+// belong to the first step.
+func (t *TOTP) Counter(ts int64) uint64 {
+	if ts < t.T0 {
+		return 0
+	}
+
+	return uint64((ts - t.T0) / int64(t.TimeStep))
+}
+
+// CounterAt returns time step number for given time
+func (t *TOTP) CounterAt(tm time.Time) uint64 {
+	return t.Counter(tm.Unix())
+}
+
+// Code is otp value together with its time step and validity interval
+type Code struct {
+	Value      string
+	Counter    uint64
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// CurrentStep returns time step number for the current time of the TOTP's clock
+func (t *TOTP) CurrentStep() uint64 {
+	return t.CounterAt(t.clock().Now())
+}
+
+// StepStart returns time the given step starts at
+func (t *TOTP) StepStart(counter uint64) time.Time {
+	return time.Unix(t.T0+int64(counter)*int64(t.TimeStep), 0)
+}
+
+// StepEnd returns time the given step ends at, i.e. the next step start
+func (t *TOTP) StepEnd(counter uint64) time.Time {
+	return t.StepStart(counter + 1)
+}
+
+// Remaining returns time left until the current step ends
+func (t *TOTP) Remaining() time.Duration {
+	return t.RemainingAt(t.clock().Now())
+}
+
+// RemainingAt returns time left at given time until its step ends
+func (t *TOTP) RemainingAt(tm time.Time) time.Duration {
+	return t.StepEnd(t.CounterAt(tm)).Sub(tm)
+}
+
+// CodeFor returns code for given time step
+func (t *TOTP) CodeFor(counter uint64) Code {
+	return Code{
+		Value:      t.Generate(counter),
+		Counter:    counter,
+		ValidFrom:  t.StepStart(counter),
+		ValidUntil: t.StepEnd(counter),
+	}
+}
+
+// Codes returns codes for prev steps before and next steps after the step of
+// given time, ordered by time. Steps before the first one are omitted,
+// negative prev or next mean no steps on that side.
+func (t *TOTP) Codes(tm time.Time, prev, next int) []Code {
+	first, last := t.window(tm, prev, next)
+
+	codes := make([]Code, 0, last-first+1)
+	for c := first; ; c++ {
+		codes = append(codes, t.CodeFor(c))
+
+		if c == last {
+			return codes
+		}
+	}
+}
+
+// Match compares code with codes of prev steps before and next steps after the
+// step of given time and returns the matched step. Every code is generated
+// without allocations and compared in constant time, so the time taken
+// doesn't depend on which step matched.
+func (t *TOTP) Match(tm time.Time, prev, next int, code string) (uint64, bool, error) {
+	g, err := t.Generator()
+	if err != nil {
+		return 0, false, err
+	}
+
+	first, last := t.window(tm, prev, next)
+
+	var (
+		buf     [maxDigits]byte
+		want    = []byte(code)
+		step    uint64
+		matched bool
+	)
+
+	for c := first; ; c++ {
+		got, err := g.AppendCode(buf[:0], c)
+		if err != nil {
+			return 0, false, err
+		}
+
+		if subtle.ConstantTimeCompare(got, want) == 1 {
+			step, matched = c, true
+		}
+
+		if c == last {
+			return step, matched, nil
+		}
+	}
+}
+
+// window returns the first and the last of prev steps before and next steps
+// after the step of given time. Negative prev or next are taken as zero,
+// steps out of the counter range are omitted.
+func (t *TOTP) window(tm time.Time, prev, next int) (first, last uint64) {
+	current := t.CounterAt(tm)
+	first, last = current, current
+
+	if prev > 0 {
+		if uint64(prev) < current {
+			first = current - uint64(prev)
+		} else {
+			first = 0
+		}
+	}
+
+	if next > 0 {
+		if uint64(next) < math.MaxUint64-current {
+			last = current + uint64(next)
+		} else {
+			last = math.MaxUint64
+		}
+	}
+
+	return first, last
+}
+
+func (t *TOTP) clock() Clock {
+	if t.Clock == nil {
+		return SystemClock
+	}
+
+	return t.Clock
 }
@@ -20,7 +20,7 @@ func TestTOTP_At(t *testing.T) {
 			name: "sha1",
 			opts: Opts{
 				Digits:    8,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 				Algorithm: sha1.New,
 			},
 			step:       30,
@@ -31,7 +31,7 @@ func TestTOTP_At(t *testing.T) {
 			name: "sha1",
 			opts: Opts{
 				Digits:    8,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 				Algorithm: sha1.New,
 			},
 			step:       1,
@@ -42,7 +42,7 @@ func TestTOTP_At(t *testing.T) {
 			name: "sha256",
 			opts: Opts{
 				Digits:    6,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 				Algorithm: sha256.New,
 			},
 			step:       30,
@@ -53,7 +53,7 @@ func TestTOTP_At(t *testing.T) {
 			name: "sha512",
 			opts: Opts{
 				Digits:    8,
-				Secret:    "12345678901234567890",
+				Secret:    []byte("12345678901234567890"),
 				Algorithm: sha512.New,
 			},
 			step:       30,
@@ -79,9 +79,203 @@ func TestTOTP_At(t *testing.T) {
 }
 
 func TestTOTP_Now(t *testing.T) {
-	totp := NewTOTP(Opts{Digits: 6, Secret: "12345678901234567890", Algorithm: sha1.New}, 30)
+	totp := NewTOTP(Opts{Digits: 6, Secret: []byte("12345678901234567890"), Algorithm: sha1.New}, 30)
 
 	if totp.Now() != totp.At(time.Now().Unix()) {
 		t.Errorf("wrong Now() value")
 	}
 }
+
+func TestTOTP_Now_Clock(t *testing.T) {
+	totp := NewTOTP(Opts{Digits: 8, Secret: []byte("12345678901234567890"), Algorithm: sha1.New}, 30)
+	totp.Clock = ClockFunc(func() time.Time { return time.Unix(1111111109, 0) })
+
+	if got := totp.Now(); got != "07081804" {
+		t.Errorf("wrong Now() value for frozen clock: %s", got)
+	}
+}
+
+func TestTOTP_T0(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		t0          int64
+		ts          int64
+		wantCounter uint64
+		wantValue   string
+	}{
+		{
+			name:        "zero t0",
+			t0:          0,
+			ts:          59,
+			wantCounter: 1,
+			wantValue:   "94287082",
+		},
+		{
+			name:        "shifted t0",
+			t0:          1000,
+			ts:          1059,
+			wantCounter: 1,
+			wantValue:   "94287082",
+		},
+		{
+			name:        "shifted step border",
+			t0:          15,
+			ts:          59,
+			wantCounter: 1,
+			wantValue:   "94287082",
+		},
+		{
+			name:        "before t0",
+			t0:          1000,
+			ts:          10,
+			wantCounter: 0,
+			wantValue:   "84755224",
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			totp := NewTOTP(Opts{Digits: 8, Secret: []byte("12345678901234567890"), Algorithm: sha1.New}, 30)
+			totp.T0 = c.t0
+
+			if got := totp.Counter(c.ts); got != c.wantCounter {
+				t.Errorf("wrong counter, want: %d != got: %d", c.wantCounter, got)
+			}
+
+			if got := totp.CounterAt(time.Unix(c.ts, 0)); got != c.wantCounter {
+				t.Errorf("wrong counter for time, want: %d != got: %d", c.wantCounter, got)
+			}
+
+			if got := totp.At(c.ts); got != c.wantValue {
+				t.Errorf("wrong otp value, want: %s != got: %s", c.wantValue, got)
+			}
+
+			if got := totp.AtTime(time.Unix(c.ts, 0)); got != c.wantValue {
+				t.Errorf("wrong otp value for time, want: %s != got: %s", c.wantValue, got)
+			}
+		})
+	}
+}
+
+func TestTOTP_Steps(t *testing.T) {
+	totp := NewTOTP(Opts{Digits: 8, Secret: []byte("12345678901234567890"), Algorithm: sha1.New}, 30)
+	totp.T0 = 10
+	totp.Clock = ClockFunc(func() time.Time { return time.Unix(1111111109, 500*int64(time.Millisecond)) })
+
+	// (1111111109 - 10) / 30 = 37037036
+	if got := totp.CurrentStep(); got != 37037036 {
+		t.Errorf("wrong current step: %d", got)
+	}
+
+	if got := totp.StepStart(37037036); got.Unix() != 1111111090 {
+		t.Errorf("wrong step start: %d", got.Unix())
+	}
+
+	if got := totp.StepEnd(37037036); got.Unix() != 1111111120 {
+		t.Errorf("wrong step end: %d", got.Unix())
+	}
+
+	if got := totp.Remaining(); got != 10500*time.Millisecond {
+		t.Errorf("wrong remaining time: %s", got)
+	}
+}
+
+func TestTOTP_Codes(t *testing.T) {
+	totp := NewTOTP(Opts{Digits: 8, Secret: []byte("12345678901234567890"), Algorithm: sha1.New}, 30)
+
+	for _, c := range []struct {
+		name         string
+		ts           int64
+		prev, next   int
+		wantCounters []uint64
+	}{
+		{
+			name:         "current only",
+			ts:           59,
+			wantCounters: []uint64{1},
+		},
+		{
+			name:         "window",
+			ts:           95,
+			prev:         1,
+			next:         2,
+			wantCounters: []uint64{2, 3, 4, 5},
+		},
+		{
+			name:         "first steps",
+			ts:           31,
+			prev:         3,
+			next:         1,
+			wantCounters: []uint64{0, 1, 2},
+		},
+		{
+			name:         "negative prev",
+			ts:           95,
+			prev:         -1,
+			next:         1,
+			wantCounters: []uint64{3, 4},
+		},
+		{
+			name:         "negative next",
+			ts:           95,
+			prev:         1,
+			next:         -2,
+			wantCounters: []uint64{2, 3},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			codes := totp.Codes(time.Unix(c.ts, 0), c.prev, c.next)
+			if len(codes) != len(c.wantCounters) {
+				t.Errorf("wrong codes count, want: %d != got: %d", len(c.wantCounters), len(codes))
+				return
+			}
+
+			for i, code := range codes {
+				if code.Counter != c.wantCounters[i] {
+					t.Errorf("wrong counter, want: %d != got: %d", c.wantCounters[i], code.Counter)
+				}
+
+				if code.Value != totp.Generate(code.Counter) {
+					t.Errorf("wrong value for %d counter: %s", code.Counter, code.Value)
+				}
+
+				if code.ValidUntil.Sub(code.ValidFrom) != 30*time.Second {
+					t.Errorf("wrong validity interval: %s - %s", code.ValidFrom, code.ValidUntil)
+				}
+			}
+		})
+	}
+}
+
+func TestTOTP_Match(t *testing.T) {
+	totp := NewTOTP(Opts{Digits: 8, Secret: []byte("12345678901234567890"), Algorithm: sha1.New}, 30)
+	now := time.Unix(1111111109, 0)
+
+	for _, c := range []struct {
+		name     string
+		code     string
+		window   int
+		wantStep uint64
+		wantOK   bool
+	}{
+		{name: "current", code: "07081804", wantStep: 37037036, wantOK: true},
+		{name: "previous", code: "89731029", window: 1, wantStep: 37037035, wantOK: true},
+		{name: "next", code: "14050471", window: 1, wantStep: 37037037, wantOK: true},
+		{name: "outside window", code: "14050471"},
+		{name: "prefix", code: "0708180"},
+		{name: "wrong", code: "12345678", window: 1},
+		{name: "negative window", code: "07081804", window: -1, wantStep: 37037036, wantOK: true},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			step, ok, err := totp.Match(now, c.window, c.window, c.code)
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+
+			if ok != c.wantOK || step != c.wantStep {
+				t.Errorf("wrong match, want: %d %t != got: %d %t", c.wantStep, c.wantOK, step, ok)
+			}
+		})
+	}
+}
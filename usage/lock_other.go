@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package usage
+
+import "os"
+
+// lockFile is a no-op, concurrent clotp runs may lose uses here
+func lockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package usage
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes exclusive advisory lock of the file, it's released when the
+// file is closed
+func lockFile(f *os.File) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package usage keeps per-item usage statistics, the last use time and the
+// use count. They are kept apart from the vault, so secrets are never
+// rewritten just because a code was generated.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+// DefaultName is the statistics file name in the config directory
+const DefaultName = "usage.json"
+
+// Order is items order
+type Order string
+
+const (
+	ByName     Order = "name"
+	ByRecent   Order = "recent"
+	ByFrequent Order = "frequent"
+	ByIssuer   Order = "issuer"
+)
+
+// Orders lists orders accepted by ParseOrder
+var Orders = []string{string(ByName), string(ByRecent), string(ByFrequent), string(ByIssuer)}
+
+// Stat is usage statistics of an item
+type Stat struct {
+	LastUsed time.Time `json:"last_used"`
+	Count    int       `json:"count"`
+}
+
+// ParseOrder returns order by its name
+func ParseOrder(s string) (Order, error) {
+	for _, o := range Orders {
+		if s == o {
+			return Order(s), nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown sort order: %s, use %s", s, strings.Join(Orders, ", "))
+}
+
+// Open returns store keeping statistics in JSON file at given path, the file
+// is created by the first Use
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+type Store struct {
+	path string
+}
+
+// Stats returns statistics by item name, items which have never been used
+// have no statistics
+func (s *Store) Stats() (map[string]Stat, error) {
+	stats := make(map[string]Stat)
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("invalid usage file %s: %w", s.path, err)
+	}
+
+	return stats, nil
+}
+
+// Use counts use of the item at given time. The file is written to
+// a temporary file and renamed over the store file, so a crash never leaves
+// a partially written file. Concurrent uses are serialized by a lock of
+// a separate file, the store file itself is replaced on every use.
+func (s *Store) Use(name string, at time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		return err
+	}
+
+	stat := stats[name]
+	stat.Count++
+	stat.LastUsed = at.UTC()
+	stats[name] = stat
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Sort sorts items in the order. Recently and frequently used items go
+// first, items without issuer go last, ties keep the vault order.
+func Sort(items []*vault.Item, stats map[string]Stat, order Order) {
+	less := func(i, j int) bool { return false }
+
+	switch order {
+	case ByName:
+		less = func(i, j int) bool { return items[i].Name < items[j].Name }
+	case ByRecent:
+		less = func(i, j int) bool { return stats[items[i].Name].LastUsed.After(stats[items[j].Name].LastUsed) }
+	case ByFrequent:
+		less = func(i, j int) bool {
+			a, b := stats[items[i].Name], stats[items[j].Name]
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+
+			return a.LastUsed.After(b.LastUsed)
+		}
+	case ByIssuer:
+		less = func(i, j int) bool {
+			a, b := items[i], items[j]
+			if (a.Issuer == "") != (b.Issuer == "") {
+				return b.Issuer == ""
+			}
+
+			if a.Issuer != b.Issuer {
+				return a.Issuer < b.Issuer
+			}
+
+			return a.Name < b.Name
+		}
+	}
+
+	sort.SliceStable(items, less)
+}
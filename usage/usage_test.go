@@ -0,0 +1,131 @@
+package usage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/vault"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usage")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := Open(filepath.Join(dir, "clotp", DefaultName))
+
+	stats, err := s.Stats()
+	if err != nil || len(stats) != 0 {
+		t.Fatalf("missing file should have no statistics, got: %v, %v", stats, err)
+	}
+
+	at := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"github", "aws", "github"} {
+		at = at.Add(time.Minute)
+		if err := s.Use(name, at); err != nil {
+			t.Fatalf("unwanted error: %v", err)
+		}
+	}
+
+	stats, err = Open(s.path).Stats()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	want := map[string]Stat{
+		"github": {LastUsed: at, Count: 2},
+		"aws":    {LastUsed: at.Add(-time.Minute), Count: 1},
+	}
+
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("want: %v != got: %v", want, stats)
+	}
+
+	if err := ioutil.WriteFile(s.path, []byte("{"), 0600); err != nil {
+		panic(err)
+	}
+
+	if _, err := s.Stats(); err == nil {
+		t.Error("invalid file should be reported")
+	}
+}
+
+func TestStore_Concurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usage")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, DefaultName)
+	at := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// like separate clotp runs
+			if err := Open(path).Use("github", at); err != nil {
+				t.Errorf("unwanted error: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stats, err := Open(path).Stats()
+	if err != nil || stats["github"].Count != 50 {
+		t.Errorf("uses are lost: %d uses, %v", stats["github"].Count, err)
+	}
+}
+
+func TestSort(t *testing.T) {
+	at := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	stats := map[string]Stat{
+		"c": {LastUsed: at, Count: 1},
+		"d": {LastUsed: at.Add(-time.Hour), Count: 5},
+		"a": {LastUsed: at.Add(-2 * time.Hour), Count: 5},
+	}
+
+	for _, c := range []struct {
+		order Order
+		want  []string
+	}{
+		{ByName, []string{"a", "b", "c", "d"}},
+		{ByRecent, []string{"c", "d", "a", "b"}},
+		{ByFrequent, []string{"d", "a", "c", "b"}},
+		{ByIssuer, []string{"c", "d", "b", "a"}},
+	} {
+		c := c
+		t.Run(string(c.order), func(t *testing.T) {
+			items := []*vault.Item{
+				{Name: "b", Issuer: "Zeta"},
+				{Name: "d", Issuer: "Alpha"},
+				{Name: "a"},
+				{Name: "c", Issuer: "Alpha"},
+			}
+
+			Sort(items, stats, c.order)
+
+			got := make([]string, 0, len(items))
+			for _, i := range items {
+				got = append(got, i.Name)
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want: %v != got: %v", c.want, got)
+			}
+		})
+	}
+}
@@ -1,20 +1,61 @@
 package main
 
 import (
-	"encoding/base32"
+	"flag"
+	"fmt"
 	"strings"
+
+	"github.com/mullakhmetov/clotp/usage"
+	"github.com/mullakhmetov/clotp/vault"
 )
 
-func DecodeBase32Secret(s string) string {
-	s = strings.ToUpper(s)
+// sortFlagUsage is the usage of -sort flag of commands showing items
+var sortFlagUsage = "Items order: " + strings.Join(usage.Orders, ", ")
+
+// parseInterspersed parses flags which may follow positional arguments,
+// e.g. `get <name> -verbose`, and returns positional arguments. Everything
+// after `--` terminator is returned as is.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional, tail []string
 
-	missingPadding := len(s) % 8
-	if missingPadding != 0 {
-		s += strings.Repeat("=", 8-missingPadding)
+	for i, a := range args {
+		if a == "--" {
+			args, tail = args[:i], args[i+1:]
+			break
+		}
 	}
-	bytes, err := base32.StdEncoding.DecodeString(s)
-	if err != nil {
-		panic("decode secret failed")
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			return append(positional, tail...), nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
-	return string(bytes)
+}
+
+// sortedItems returns items in the order. Unreadable usage statistics are
+// reported, items are sorted as if they have never been used then.
+func sortedItems(env *Env, items []*vault.Item, order usage.Order) []*vault.Item {
+	stats := make(map[string]usage.Stat)
+
+	if env.Usage != nil && (order == usage.ByRecent || order == usage.ByFrequent) {
+		s, err := env.Usage.Stats()
+		if err != nil {
+			fmt.Fprintf(env.Stderr, "warning: %v\n", err)
+		} else {
+			stats = s
+		}
+	}
+
+	sorted := append([]*vault.Item(nil), items...)
+	usage.Sort(sorted, stats, order)
+
+	return sorted
 }
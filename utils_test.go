@@ -1,40 +1,61 @@
 package main
 
 import (
+	"flag"
+	"reflect"
 	"testing"
 )
 
-func TestDecodeBase32Secret(t *testing.T) {
+func TestParseInterspersed(t *testing.T) {
 	for _, c := range []struct {
-		name  string
-		input string
-		want  string
+		name       string
+		args       []string
+		want       []string
+		wantFlag   string
+		wantToggle bool
 	}{
 		{
-			name:  "empty input",
-			input: "",
-			want:  "",
+			name: "no args",
+			args: []string{},
 		},
 		{
-			name:  "test padding #1",
-			input: "GE",
-			want:  "1",
+			name:       "flags first",
+			args:       []string{"-flag", "v", "-toggle", "name"},
+			want:       []string{"name"},
+			wantFlag:   "v",
+			wantToggle: true,
 		},
 		{
-			name:  "test padding #2",
-			input: "GE=====",
-			want:  "1",
+			name:     "flags last",
+			args:     []string{"name", "-flag", "v", "other"},
+			want:     []string{"name", "other"},
+			wantFlag: "v",
 		},
 		{
-			name:  "test lowercase",
-			input: "ge=====",
-			want:  "1",
+			name:       "terminator",
+			args:       []string{"name", "-toggle", "--", "cmd", "-flag", "v"},
+			want:       []string{"name", "cmd", "-flag", "v"},
+			wantToggle: true,
 		},
 	} {
 		c := c
 		t.Run(c.name, func(t *testing.T) {
-			if got := DecodeBase32Secret(c.input); got != c.want {
-				t.Errorf("wrong decoded value for %s input, want: %s != got: %s", c.input, c.want, got)
+			fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+			f := fs.String("flag", "", "")
+			toggle := fs.Bool("toggle", false, "")
+
+			got, err := parseInterspersed(fs, c.args)
+			if err != nil {
+				t.Errorf("unwanted error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("wrong positional args, want: %q != got: %q", c.want, got)
+			}
+
+			if *f != c.wantFlag || *toggle != c.wantToggle {
+				t.Errorf("wrong flags: %q, %t", *f, *toggle)
 			}
 		})
 	}
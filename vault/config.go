@@ -0,0 +1,280 @@
+// Package vault provides access to clotp items storage, so other tools can
+// read and modify the same vault as the clotp command does.
+package vault
+
+import (
+	"crypto/sha1" //nolint:gosec // used in hmac only, see RFC 4226 B.2. section
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+
+	"github.com/mullakhmetov/clotp/securemem"
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+const (
+	DefaultAlgorithm = "sha1"
+	DefaultDigits    = 6
+	DefaultStep      = 30
+
+	DefaultConfigName = "config.ini"
+)
+
+type parseAlgorithmFn func(string) (func() hash.Hash, error)
+
+// SupportedAlgorithms lists algorithm names accepted by ParseAlgorithm
+var SupportedAlgorithms = []string{
+	"sha1",
+	"sha256",
+	"sha512",
+}
+
+// Opts are options of the default INI file vault
+type Opts struct {
+	// Path is the config directory, ~/.config/clotp by default
+	Path string
+	// Filename is the config file name, config.ini by default
+	Filename string
+}
+
+// Mapper reads and writes vault items from and to a storage
+type Mapper interface {
+	Read() ([]*Item, error)
+	Write(items []*Item) error
+}
+
+type Config struct {
+	mapper           Mapper
+	parseAlgorithmFn parseAlgorithmFn
+	itemNames        map[string]struct{}
+	Items            []*Item
+
+	// locked is the locked memory holding item secrets, see Lock
+	locked []byte
+}
+
+// Read reads config via mapper
+func (c *Config) Read() error {
+	items, err := c.mapper.Read()
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := c.add(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reload replaces items with the ones read via mapper, e.g. after the vault
+// was changed by other process
+func (c *Config) Reload() error {
+	c.Items, c.itemNames = nil, nil
+
+	return c.Read()
+}
+
+// MergeFiles merges vault files of the config's backend, see MergeFiles. The
+// mapper may merge its files itself, e.g. shared vault does.
+func (c *Config) MergeFiles(base, ours, theirs string) error {
+	if m, ok := c.mapper.(interface {
+		MergeFiles(base, ours, theirs string) error
+	}); ok {
+		return m.MergeFiles(base, ours, theirs)
+	}
+
+	return MergeFiles(base, ours, theirs)
+}
+
+// Writes config via mapper
+func (c Config) Write() error {
+	return c.mapper.Write(c.Items)
+}
+
+// Lock moves secrets of the items into memory locked into RAM, so they're
+// never swapped out. Secrets of items added later aren't locked.
+func (c *Config) Lock() error {
+	size := 0
+	for _, i := range c.Items {
+		size += len(i.Key)
+	}
+
+	locked, err := securemem.Alloc(size)
+	if err != nil {
+		return err
+	}
+
+	prev := c.locked
+	c.locked = locked
+
+	for _, i := range c.Items {
+		n := copy(locked, i.Key)
+		securemem.Wipe(i.Key)
+
+		// capacity is limited, so appending to a secret never overwrites the next one
+		i.Key, locked = locked[:n:n], locked[n:]
+	}
+
+	// secrets may have been locked before, they're copied out by now
+	if prev != nil {
+		_ = securemem.Free(prev)
+	}
+
+	return nil
+}
+
+// Wipe zeroes secrets of the items and releases locked memory, the config
+// can't be used after that
+func (c *Config) Wipe() {
+	for _, i := range c.Items {
+		i.Wipe()
+	}
+
+	if c.locked != nil {
+		_ = securemem.Free(c.locked)
+		c.locked = nil
+	}
+}
+
+// Add adds given item to config
+func (c *Config) Add(item *Item) error {
+	if ok := item.Validate(); !ok {
+		return ErrInvalidItem
+	}
+
+	return c.add(item)
+}
+
+// Get returns item by its name or nil if there is no such item
+func (c *Config) Get(name string) *Item {
+	for _, i := range c.Items {
+		if i.Name == name {
+			return i
+		}
+	}
+
+	return nil
+}
+
+// Update replaces the item having the same name with given one
+func (c *Config) Update(item *Item) error {
+	if ok := item.Validate(); !ok {
+		return ErrInvalidItem
+	}
+
+	for n, i := range c.Items {
+		if i.Name == item.Name {
+			c.Items[n] = item
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrItemNotFound, item.Name)
+}
+
+// Remove removes item by its name
+func (c *Config) Remove(name string) error {
+	for n, i := range c.Items {
+		if i.Name == name {
+			c.Items = append(c.Items[:n], c.Items[n+1:]...)
+			delete(c.itemNames, name)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrItemNotFound, name)
+}
+
+// TOTP returns TOTP object of the item with given name, it should be wiped
+// after use
+func (c *Config) TOTP(name string) (*totp.TOTP, error) {
+	item := c.Get(name)
+	if item == nil {
+		return nil, fmt.Errorf("%w: %s", ErrItemNotFound, name)
+	}
+
+	return item.TOTP()
+}
+
+// Code returns current TOTP code of the item with given name
+func (c *Config) Code(name string) (string, error) {
+	t, err := c.TOTP(name)
+	if err != nil {
+		return "", err
+	}
+	defer t.Wipe()
+
+	return t.Now(), nil
+}
+
+func (c *Config) add(item *Item) error {
+	if c.itemNames == nil {
+		c.itemNames = make(map[string]struct{})
+	}
+
+	if _, ok := c.itemNames[item.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrItemAlreadyExists, item.Name)
+	}
+
+	c.itemNames[item.Name] = struct{}{}
+	c.Items = append(c.Items, item)
+
+	return nil
+}
+
+// NewConfig reads config file if it exist or creates new empty one if doesn't
+func NewConfig(opts Opts) (*Config, error) {
+	if opts.Path == "" {
+		opts.Path = DefaultDir()
+	}
+
+	if opts.Filename == "" {
+		opts.Filename = DefaultConfigName
+	}
+
+	return NewConfigWithMapper(NewIniMapper(opts, ParseAlgorithm))
+}
+
+// NewConfigWithMapper reads config via given mapper
+func NewConfigWithMapper(m Mapper) (*Config, error) {
+	cfg := &Config{
+		mapper:           m,
+		parseAlgorithmFn: ParseAlgorithm,
+	}
+
+	if err := cfg.Read(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// DefaultDir returns default config directory
+func DefaultDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "clotp")
+}
+
+// ParseAlgorithm returns hash function by its name, sha1 for empty name
+func ParseAlgorithm(a string) (func() hash.Hash, error) {
+	if a == "" {
+		a = DefaultAlgorithm
+	}
+
+	switch a {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unknown algorithm: %s", a)
+	}
+}
@@ -0,0 +1,447 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+type stubMapper struct {
+	ItemsToRead []*Item
+	WriteBuffer []*Item
+}
+
+func (m stubMapper) Read() ([]*Item, error) {
+	return m.ItemsToRead, nil
+}
+func (m *stubMapper) Write(items []*Item) error {
+	m.WriteBuffer = items
+	return nil
+}
+
+func TestConfigRead(t *testing.T) {
+	wantItems := []*Item{
+		{Name: "n1", Key: []byte("GE")},
+		{Name: "n2", Key: []byte("GE")},
+	}
+	config := &Config{mapper: &stubMapper{ItemsToRead: wantItems}}
+
+	err := config.Read()
+	if err != nil {
+		panic(err)
+	}
+
+	if !reflect.DeepEqual(config.Items, wantItems) {
+		t.Errorf("wrong config items, want: %+v != got: %+v", wantItems, config.Items)
+	}
+}
+
+func TestConfigWrite(t *testing.T) {
+	items := []*Item{
+		{Name: "n1", Key: []byte("GE")},
+		{Name: "n2", Key: []byte("GE")},
+	}
+	mapper := &stubMapper{}
+	config := &Config{mapper: mapper, Items: items}
+
+	err := config.Write()
+	if err != nil {
+		panic(err)
+	}
+
+	if !reflect.DeepEqual(mapper.WriteBuffer, items) {
+		t.Errorf("wrong config items, want: %+v != got: %+v", items, mapper.WriteBuffer)
+	}
+}
+
+func TestItemValidate(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		item Item
+		want bool
+	}{
+		{
+			name: "no name",
+			item: Item{Key: []byte("GE")},
+			want: false,
+		},
+		{
+			name: "no key",
+			item: Item{Name: "n"},
+			want: false,
+		},
+		{
+			name: "invalid key",
+			item: Item{Name: "n", Key: []byte("not base32")},
+			want: false,
+		},
+		{
+			name: "negative step",
+			item: Item{Name: "n", Step: -1},
+			want: false,
+		},
+		{
+			name: "negative t0",
+			item: Item{Name: "n", Key: []byte("GE"), T0: -1},
+			want: false,
+		},
+		{
+			name: "unknown algorithm",
+			item: Item{Name: "n", Key: []byte("GE"), Algorithm: "md5"},
+			want: false,
+		},
+		{
+			name: "invalid ocra suite",
+			item: Item{Name: "n", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6"},
+			want: false,
+		},
+		{
+			name: "valid",
+			item: Item{Name: "n", Key: []byte("GE")},
+			want: true,
+		},
+		{
+			name: "valid ocra",
+			item: Item{Name: "n", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08"},
+			want: true,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.item.Validate(); got != c.want {
+				t.Errorf("wrong validation result, should be %t", c.want)
+			}
+		})
+	}
+}
+
+func TestItemTOTP(t *testing.T) {
+	item := &Item{
+		Name:      "n",
+		Issuer:    "issuer",
+		Key:       []byte("GE"),
+		Algorithm: "sha1",
+		Digits:    6,
+		Step:      30,
+		T0:        100,
+	}
+
+	totp, err := item.TOTP()
+	if err != nil {
+		panic(err)
+	}
+
+	if item.Digits != totp.Digits {
+		t.Errorf("wrong digits value")
+	}
+
+	if secret, _ := DecodeBase32Secret(item.Key); !bytes.Equal(secret, totp.Secret) {
+		t.Errorf("wrong secret value")
+	}
+
+	if item.Step != totp.TimeStep {
+		t.Errorf("wrong timestep value")
+	}
+
+	if item.T0 != totp.T0 {
+		t.Errorf("wrong t0 value")
+	}
+
+	item.Suite = "OCRA-1:HOTP-SHA1-6:QN08"
+	if _, err := item.TOTP(); !errors.Is(err, ErrOCRAItem) {
+		t.Errorf("wrong error for OCRA item, want: %v != got: %v", ErrOCRAItem, err)
+	}
+}
+
+func TestItemOCRA(t *testing.T) {
+	item := &Item{Name: "n", Key: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"), Suite: "OCRA-1:HOTP-SHA1-6:QN08"}
+
+	ocra, err := item.OCRA()
+	if err != nil {
+		panic(err)
+	}
+
+	if secret, _ := DecodeBase32Secret(item.Key); !bytes.Equal(secret, ocra.Secret) {
+		t.Errorf("wrong secret value")
+	}
+
+	// RFC 6287 Appendix C one-way challenge response
+	if got, _ := ocra.Generate(totp.OCRAInput{Challenge: "00000000"}); got != "237653" {
+		t.Errorf("wrong ocra value: %s", got)
+	}
+
+	if _, err := (&Item{Name: "n", Key: []byte("GE")}).OCRA(); err == nil {
+		t.Error("error shouldn't be nil for item without suite")
+	}
+}
+
+func TestItemURI(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		item Item
+		want string
+	}{
+		{
+			name: "minimal",
+			item: Item{Name: "alice@example.com", Key: []byte("ge")},
+			want: "otpauth://totp/alice@example.com?secret=GE",
+		},
+		{
+			name: "full",
+			item: Item{Name: "alice", Issuer: "Big Corp", Key: []byte("GE======"), Algorithm: "sha256", Digits: 8, Step: 60},
+			want: "otpauth://totp/Big%20Corp:alice?algorithm=SHA256&digits=8&issuer=Big+Corp&period=60&secret=GE",
+		},
+		{
+			name: "ocra",
+			item: Item{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 42},
+			want: "otpauth://ocra/vpn?counter=42&secret=GE&suite=OCRA-1%3AHOTP-SHA1-6%3AQN08",
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.item.URI(); got != c.want {
+				t.Errorf("wrong uri, want: %s != got: %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigGet(t *testing.T) {
+	config := Config{Items: []*Item{{Name: "n1", Key: []byte("GE")}, {Name: "n2", Key: []byte("GE")}}}
+
+	if item := config.Get("n2"); item != config.Items[1] {
+		t.Errorf("wrong item: %+v", item)
+	}
+
+	if item := config.Get("n3"); item != nil {
+		t.Errorf("item should be nil, got: %+v", item)
+	}
+}
+
+func TestConfigAdd(t *testing.T) {
+	config := Config{}
+
+	for _, c := range []struct {
+		name string
+		item *Item
+		err  error
+	}{
+		{
+			name: "no name",
+			item: &Item{Key: []byte("GE")},
+			err:  ErrInvalidItem,
+		},
+		{
+			name: "no key",
+			item: &Item{Name: "n"},
+			err:  ErrInvalidItem,
+		},
+		{
+			name: "negative step",
+			item: &Item{Name: "foo", Key: []byte("GE"), Step: -1},
+			err:  ErrInvalidItem,
+		},
+		{
+			name: "valid #1",
+			item: &Item{Name: "n", Key: []byte("GE")},
+		},
+		{
+			name: "duplicate",
+			item: &Item{Name: "n", Key: []byte("GE")},
+			err:  ErrItemAlreadyExists,
+		},
+		{
+			name: "valid #2",
+			item: &Item{Name: "n2", Key: []byte("GE")},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := config.Add(c.item)
+			if err != nil {
+				if c.err == nil {
+					t.Errorf("unwanted error: %v", err)
+					return
+				}
+
+				if !errors.Is(err, c.err) {
+					t.Errorf("error should match with %v", c.err)
+					return
+				}
+
+				return
+			}
+
+			if c.err != nil {
+				t.Error("error shouldn't be nil")
+				return
+			}
+		})
+	}
+
+	want := []*Item{
+		{Name: "n", Key: []byte("GE")},
+		{Name: "n2", Key: []byte("GE")},
+	}
+
+	// function type is incomparable
+	for _, i := range config.Items {
+		i.digest = nil
+	}
+
+	if !reflect.DeepEqual(want, config.Items) {
+		t.Errorf("wrong config items state, want: %+v != got: %+v", want, config.Items)
+	}
+}
+
+func TestConfigUpdate(t *testing.T) {
+	config := Config{}
+	for _, i := range []*Item{{Name: "n1", Key: []byte("GE")}, {Name: "n2", Key: []byte("GE")}} {
+		if err := config.Add(i); err != nil {
+			panic(err)
+		}
+	}
+
+	updated := &Item{Name: "n2", Key: []byte("GEZA"), Digits: 8}
+	if err := config.Update(updated); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+
+	if config.Get("n2") != updated {
+		t.Errorf("item wasn't updated: %+v", config.Get("n2"))
+	}
+
+	if err := config.Update(&Item{Name: "n3", Key: []byte("GE")}); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("error should match with %v, got: %v", ErrItemNotFound, err)
+	}
+
+	if err := config.Update(&Item{Name: "n1"}); !errors.Is(err, ErrInvalidItem) {
+		t.Errorf("error should match with %v, got: %v", ErrInvalidItem, err)
+	}
+}
+
+func TestConfigRemove(t *testing.T) {
+	config := Config{}
+	for _, i := range []*Item{{Name: "n1", Key: []byte("GE")}, {Name: "n2", Key: []byte("GE")}} {
+		if err := config.Add(i); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := config.Remove("n1"); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+
+	if len(config.Items) != 1 || config.Items[0].Name != "n2" {
+		t.Errorf("wrong config items state: %+v", config.Items)
+	}
+
+	if err := config.Remove("n1"); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("error should match with %v, got: %v", ErrItemNotFound, err)
+	}
+
+	// removed name could be used again
+	if err := config.Add(&Item{Name: "n1", Key: []byte("GE")}); err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+}
+
+func TestConfigCode(t *testing.T) {
+	config := Config{Items: []*Item{{Name: "n", Key: []byte("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")}}}
+
+	got, err := config.Code("n")
+	if err != nil {
+		t.Errorf("unwanted error: %v", err)
+	}
+
+	t0, err := config.Items[0].TOTP()
+	if err != nil {
+		panic(err)
+	}
+
+	if want := t0.Now(); got != want {
+		t.Errorf("wrong code, want: %s != got: %s", want, got)
+	}
+
+	if _, err := config.Code("unknown"); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("error should match with %v, got: %v", ErrItemNotFound, err)
+	}
+}
+
+func TestConfigLock(t *testing.T) {
+	config := Config{Items: []*Item{{Name: "n1", Key: []byte("GE")}, {Name: "n2", Key: []byte("GEZA")}}}
+	key := config.Items[0].Key
+
+	if err := config.Lock(); err != nil {
+		t.Skipf("memory can't be locked: %v", err)
+	}
+
+	// locking again moves secrets out of the released memory
+	if err := config.Lock(); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if !bytes.Equal(key, []byte{0, 0}) {
+		t.Errorf("original secret should be wiped, got: %q", key)
+	}
+
+	if string(config.Items[0].Key) != "GE" || string(config.Items[1].Key) != "GEZA" {
+		t.Errorf("secrets should be kept, got: %q, %q", config.Items[0].Key, config.Items[1].Key)
+	}
+
+	if cap(config.Items[0].Key) != 2 {
+		t.Errorf("secret capacity should be limited, got: %d", cap(config.Items[0].Key))
+	}
+
+	item := &Item{Name: "n3", Key: []byte("GE")}
+	if err := config.Add(item); err != nil {
+		panic(err)
+	}
+
+	config.Wipe()
+
+	if !bytes.Equal(item.Key, []byte{0, 0}) {
+		t.Errorf("secret should be wiped, got: %q", item.Key)
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prefix")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config, err := NewConfig(Opts{Path: dir})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, i := range []*Item{{Name: "n1", Key: []byte("GE"), Algorithm: "sha256"}, {Name: "n2", Key: []byte("GE")}} {
+		if err := config.Add(i); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := config.Write(); err != nil {
+		panic(err)
+	}
+
+	reopened, err := NewConfig(Opts{Path: dir})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, i := range reopened.Items {
+		i.digest = nil
+	}
+
+	if !reflect.DeepEqual(config.Items, reopened.Items) {
+		t.Errorf("wrong reopened items, want: %+v != got: %+v", config.Items, reopened.Items)
+	}
+}
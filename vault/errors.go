@@ -0,0 +1,12 @@
+package vault
+
+import "errors"
+
+var (
+	ErrInvalidItem       = errors.New("item validation failed")
+	ErrItemAlreadyExists = errors.New("item already exists")
+	ErrItemNotFound      = errors.New("item not found")
+	ErrInvalidURI        = errors.New("invalid otpauth URI")
+	ErrInvalidSecret     = errors.New("secret isn't valid base32")
+	ErrOCRAItem          = errors.New("OCRA item has no TOTP codes")
+)
@@ -0,0 +1,210 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mullakhmetov/clotp/securemem"
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+// Item is a single vault entry
+type Item struct {
+	Name   string `ini:"-"`
+	Issuer string `ini:"issuer,omitempty"`
+	// Key is the base32 encoded secret, it's a byte slice so it can be wiped
+	Key       []byte `ini:"-"`
+	Algorithm string `ini:"algorithm,omitempty"`
+	Digits    int    `ini:"digits,omitempty"`
+	Step      int    `ini:"step,omitempty"`
+	T0        int64  `ini:"t0,omitempty"`
+	Suite     string `ini:"ocra,omitempty"`
+	Counter   uint64 `ini:"counter,omitempty"`
+
+	digest func() hash.Hash
+}
+
+func (i Item) Validate() bool {
+	if i.Name == "" {
+		return false
+	}
+
+	if len(i.Key) == 0 {
+		return false
+	}
+
+	secret, err := DecodeBase32Secret(i.Key)
+	if err != nil {
+		return false
+	}
+
+	securemem.Wipe(secret)
+
+	if i.Step < 0 {
+		return false
+	}
+
+	if i.T0 < 0 {
+		return false
+	}
+
+	if _, err := ParseAlgorithm(i.Algorithm); err != nil {
+		return false
+	}
+
+	if i.Suite != "" {
+		if _, err := totp.ParseSuite(i.Suite); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Digest returns hash function of the item's algorithm
+func (i Item) Digest() func() hash.Hash {
+	if i.digest != nil {
+		return i.digest
+	}
+
+	d, err := ParseAlgorithm(i.Algorithm)
+	if err != nil {
+		return nil
+	}
+
+	return d
+}
+
+// CodeDigits returns number of digits of the item's codes, default if unset
+func (i Item) CodeDigits() int {
+	if i.Digits == 0 {
+		return DefaultDigits
+	}
+
+	return i.Digits
+}
+
+// TimeStep returns the item's time step in seconds, default if unset
+func (i Item) TimeStep() int {
+	if i.Step == 0 {
+		return DefaultStep
+	}
+
+	return i.Step
+}
+
+// TOTP returns TOTP object of the item with defaults applied. It holds
+// decoded secret, wipe it when it isn't needed anymore. OCRA items have no
+// TOTP, ErrOCRAItem is returned for them.
+func (i Item) TOTP() (*totp.TOTP, error) {
+	if i.Suite != "" {
+		return nil, fmt.Errorf("%w: %s", ErrOCRAItem, i.Name)
+	}
+
+	if i.Algorithm == "" {
+		i.Algorithm = DefaultAlgorithm
+	}
+
+	secret, err := DecodeBase32Secret(i.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", i.Name, err)
+	}
+
+	t := totp.NewTOTP(totp.Opts{
+		Digits:    i.CodeDigits(),
+		Secret:    secret,
+		Algorithm: i.Digest(),
+	}, i.TimeStep())
+	t.T0 = i.T0
+
+	return t, nil
+}
+
+// URI returns otpauth URI of the item, understood by most authenticator apps,
+// see https://github.com/google/google-authenticator/wiki/Key-Uri-Format.
+// OCRA items have no TOTP URI, ExtendedURI is returned for them. The URI
+// holds the secret and Wipe can't clear it, keep it only as long as needed.
+func (i Item) URI() string {
+	if i.Suite != "" {
+		return i.ExtendedURI()
+	}
+
+	u, v := i.otpauth()
+	u.RawQuery = v.Encode()
+
+	return u.String()
+}
+
+// otpauth returns totp URI of the item without the query and its parameters.
+// The secret is copied to strings here, it's the exception to keeping it in
+// wipeable bytes, since URIs are passed on as strings anyway: printed,
+// encoded to QR codes and JSON, stored in KeePass fields.
+func (i Item) otpauth() (url.URL, url.Values) {
+	label := i.Name
+	if i.Issuer != "" {
+		label = i.Issuer + ":" + i.Name
+	}
+
+	v := url.Values{}
+	v.Set("secret", strings.ToUpper(strings.TrimRight(string(i.Key), "=")))
+
+	if i.Issuer != "" {
+		v.Set("issuer", i.Issuer)
+	}
+
+	if i.Algorithm != "" {
+		v.Set("algorithm", strings.ToUpper(i.Algorithm))
+	}
+
+	if i.Digits != 0 {
+		v.Set("digits", strconv.Itoa(i.Digits))
+	}
+
+	if i.Step != 0 {
+		v.Set("period", strconv.Itoa(i.Step))
+	}
+
+	return url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label}, v
+}
+
+// Equal reports whether items have the same name, secret and parameters
+func (i *Item) Equal(o *Item) bool {
+	return i.Name == o.Name &&
+		i.Issuer == o.Issuer &&
+		bytes.Equal(i.Key, o.Key) &&
+		i.Algorithm == o.Algorithm &&
+		i.Digits == o.Digits &&
+		i.Step == o.Step &&
+		i.T0 == o.T0 &&
+		i.Suite == o.Suite &&
+		i.Counter == o.Counter
+}
+
+// Wipe zeroes the item's secret
+func (i *Item) Wipe() {
+	securemem.Wipe(i.Key)
+}
+
+// OCRA returns OCRA object if item has OCRA suite configured, wipe it when
+// it isn't needed anymore
+func (i Item) OCRA() (*totp.OCRA, error) {
+	if i.Suite == "" {
+		return nil, fmt.Errorf("%s has no OCRA suite configured", i.Name)
+	}
+
+	suite, err := totp.ParseSuite(i.Suite)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := DecodeBase32Secret(i.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", i.Name, err)
+	}
+
+	return totp.NewOCRA(suite, secret), nil
+}
@@ -0,0 +1,331 @@
+package vault
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mullakhmetov/clotp/kdbx"
+)
+
+const (
+	// keePassOTP is the field KeePassXC keeps otpauth URI in
+	keePassOTP = "otp"
+
+	// KeePass 2.47+ TOTP fields
+	timeOTPPrefix    = "TimeOtp-"
+	timeOTPSecret    = "TimeOtp-Secret"
+	timeOTPHex       = "TimeOtp-Secret-Hex"
+	timeOTPBase32    = "TimeOtp-Secret-Base32"
+	timeOTPBase64    = "TimeOtp-Secret-Base64"
+	timeOTPLength    = "TimeOtp-Length"
+	timeOTPPeriod    = "TimeOtp-Period"
+	timeOTPAlgorithm = "TimeOtp-Algorithm"
+)
+
+// KeePassOpts are options of KeePass database mapper
+type KeePassOpts struct {
+	// Path is the KDBX 4 database path
+	Path string
+	// Password is the database password, nil if the database has no password
+	Password []byte
+	// KeyFile is the key file path, empty if the database has no key file
+	KeyFile string
+}
+
+// NewKeePassMapper returns mapper keeping items in entries of a KeePass
+// database. Item name is the entry title prefixed with its groups, e.g.
+// Work/VPN/gateway. TOTP settings are read from KeePassXC otp field or from
+// KeePass TimeOtp-* fields, entries without them are ignored.
+func NewKeePassMapper(opts KeePassOpts) *KeePassMapper {
+	return &KeePassMapper{opts: opts}
+}
+
+type KeePassMapper struct {
+	opts KeePassOpts
+	db   *kdbx.Database
+	// entries are entries of the items read, by item name
+	entries map[string]*kdbx.Entry
+	// sums are hashes of URIs of the items as they were read, so unchanged
+	// entries aren't touched
+	sums map[string]uriSum
+}
+
+// Read decrypts the database and returns items of its TOTP entries
+func (m *KeePassMapper) Read() ([]*Item, error) {
+	key, err := m.key()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(m.opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db, err := kdbx.Open(f, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.opts.Path, err)
+	}
+
+	m.db = db
+	m.entries = make(map[string]*kdbx.Entry)
+	m.sums = make(map[string]uriSum)
+
+	items := make([]*Item, 0)
+
+	for _, e := range db.Entries() {
+		item, err := entryItem(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entryName(e), err)
+		}
+
+		if item == nil {
+			continue
+		}
+
+		// titles aren't unique in KeePass
+		name := entryName(e)
+		for n := 2; m.entries[name] != nil; n++ {
+			name = entryName(e) + " (" + strconv.Itoa(n) + ")"
+		}
+
+		item.Name = name
+		m.entries[name] = e
+		m.sums[name] = sumURI(item.ExtendedURI())
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// Write updates TOTP fields of changed entries and adds entries of new items.
+// Entries of removed items lose TOTP fields only, as they usually keep a
+// password too. The database is replaced atomically.
+func (m *KeePassMapper) Write(items []*Item) error {
+	if m.db == nil {
+		return fmt.Errorf("%s: database should be read before writing", m.opts.Path)
+	}
+
+	written := make(map[string]struct{}, len(items))
+
+	for _, i := range items {
+		written[i.Name] = struct{}{}
+
+		sum := sumURI(i.ExtendedURI())
+		if prev, ok := m.sums[i.Name]; ok && prev == sum {
+			continue
+		}
+
+		e := m.entries[i.Name]
+		if e == nil {
+			parts := strings.Split(i.Name, "/")
+
+			var err error
+			if e, err = m.db.AddEntry(parts[:len(parts)-1], parts[len(parts)-1]); err != nil {
+				return err
+			}
+
+			m.entries[i.Name] = e
+		}
+
+		setEntryItem(e, i)
+		m.sums[i.Name] = sum
+	}
+
+	for name, e := range m.entries {
+		if _, ok := written[name]; ok {
+			continue
+		}
+
+		for _, key := range e.Keys() {
+			if key == keePassOTP || strings.HasPrefix(key, timeOTPPrefix) {
+				e.Delete(key)
+			}
+		}
+
+		delete(m.entries, name)
+		delete(m.sums, name)
+	}
+
+	return m.save()
+}
+
+func (m *KeePassMapper) save() error {
+	f, err := ioutil.TempFile(filepath.Dir(m.opts.Path), filepath.Base(m.opts.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err := m.db.Write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if info, err := os.Stat(m.opts.Path); err == nil {
+		_ = os.Chmod(f.Name(), info.Mode().Perm())
+	}
+
+	if err := os.Rename(f.Name(), m.opts.Path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+func (m *KeePassMapper) key() (*kdbx.Key, error) {
+	var keyFile []byte
+
+	if m.opts.KeyFile != "" {
+		var err error
+		if keyFile, err = ioutil.ReadFile(m.opts.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return kdbx.NewKey(m.opts.Password, keyFile)
+}
+
+func entryName(e *kdbx.Entry) string {
+	return strings.Join(append(append([]string(nil), e.Groups...), e.Title()), "/")
+}
+
+// entryItem returns item of the entry or nil if it has no TOTP settings
+func entryItem(e *kdbx.Entry) (*Item, error) {
+	if otp, ok := e.Get(keePassOTP); ok && strings.TrimSpace(otp) != "" {
+		return parseKeePassOTP(strings.TrimSpace(otp))
+	}
+
+	secret, err := timeOTPSecretValue(e)
+	if err != nil || secret == "" {
+		return nil, err
+	}
+
+	item := &Item{Key: []byte(secret)}
+
+	if v, ok := e.Get(timeOTPLength); ok && v != "" {
+		if item.Digits, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", timeOTPLength, v)
+		}
+	}
+
+	if v, ok := e.Get(timeOTPPeriod); ok && v != "" {
+		if item.Step, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", timeOTPPeriod, v)
+		}
+	}
+
+	if v, ok := e.Get(timeOTPAlgorithm); ok && v != "" {
+		a := strings.ToLower(strings.Replace(strings.TrimPrefix(v, "HMAC-"), "-", "", -1))
+		if _, err := ParseAlgorithm(a); err != nil {
+			return nil, err
+		}
+
+		item.Algorithm = a
+	}
+
+	return item, nil
+}
+
+// parseKeePassOTP parses otpauth URI or KeeOtp "key=...&step=...&size=..."
+// settings, both are found in otp fields
+func parseKeePassOTP(otp string) (*Item, error) {
+	if strings.HasPrefix(otp, uriScheme+"://") {
+		return ParseURI(otp)
+	}
+
+	q, err := url.ParseQuery(otp)
+	if err != nil || q.Get("key") == "" {
+		return nil, fmt.Errorf("%w: unknown otp field format", ErrInvalidURI)
+	}
+
+	item := &Item{Key: []byte(strings.Replace(q.Get("key"), " ", "", -1))}
+
+	if v := q.Get("size"); v != "" {
+		if item.Digits, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("%w: invalid size: %s", ErrInvalidURI, v)
+		}
+	}
+
+	if v := q.Get("step"); v != "" {
+		if item.Step, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("%w: invalid step: %s", ErrInvalidURI, v)
+		}
+	}
+
+	return item, nil
+}
+
+// timeOTPSecretValue returns base32 secret of TimeOtp-Secret* fields
+func timeOTPSecretValue(e *kdbx.Entry) (string, error) {
+	if v, ok := e.Get(timeOTPBase32); ok && v != "" {
+		return strings.ToUpper(strings.Replace(v, " ", "", -1)), nil
+	}
+
+	var (
+		secret []byte
+		err    error
+	)
+
+	if v, ok := e.Get(timeOTPSecret); ok && v != "" {
+		secret = []byte(v)
+	} else if v, ok := e.Get(timeOTPHex); ok && v != "" {
+		secret, err = hex.DecodeString(strings.Replace(v, " ", "", -1))
+	} else if v, ok := e.Get(timeOTPBase64); ok && v != "" {
+		secret, err = base64.StdEncoding.DecodeString(v)
+	} else {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("invalid TimeOtp secret: %w", err)
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret), nil
+}
+
+// setEntryItem writes item settings to the fields the entry already uses,
+// otp field for new entries and for settings TimeOtp fields can't keep
+func setEntryItem(e *kdbx.Entry, i *Item) {
+	_, hasOTP := e.Get(keePassOTP)
+	secret, _ := timeOTPSecretValue(e)
+	hasTimeOTP := secret != ""
+
+	if hasTimeOTP {
+		for _, key := range []string{timeOTPSecret, timeOTPHex, timeOTPBase64} {
+			e.Delete(key)
+		}
+
+		algorithm := i.Algorithm
+		if algorithm == "" {
+			algorithm = DefaultAlgorithm
+		}
+
+		e.Set(timeOTPBase32, strings.ToUpper(strings.TrimRight(string(i.Key), "=")), true)
+		e.Set(timeOTPLength, strconv.Itoa(i.CodeDigits()), false)
+		e.Set(timeOTPPeriod, strconv.Itoa(i.TimeStep()), false)
+		e.Set(timeOTPAlgorithm, "HMAC-SHA-"+strings.TrimPrefix(strings.ToUpper(algorithm), "SHA"), false)
+	}
+
+	if hasOTP || !hasTimeOTP || i.T0 != 0 || i.Suite != "" {
+		labeled := *i
+		labeled.Name = e.Title()
+		e.Set(keePassOTP, labeled.ExtendedURI(), true)
+	}
+}
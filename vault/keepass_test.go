@@ -0,0 +1,189 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/kdbx"
+)
+
+// newKeePassDB creates temporary database protected with a password and a key
+// file, fill adds entries to it
+func newKeePassDB(t *testing.T, fill func(db *kdbx.Database)) (opts KeePassOpts, cleanup func()) {
+	dir, err := ioutil.TempDir("", "keepass")
+	if err != nil {
+		panic(err)
+	}
+
+	opts = KeePassOpts{
+		Path:     filepath.Join(dir, "db.kdbx"),
+		Password: []byte("secret"),
+		KeyFile:  filepath.Join(dir, "db.keyx"),
+	}
+
+	keyFile := []byte("any file can be a key file")
+	if err := ioutil.WriteFile(opts.KeyFile, keyFile, 0600); err != nil {
+		panic(err)
+	}
+
+	key, err := kdbx.NewKey(opts.Password, keyFile)
+	if err != nil {
+		panic(err)
+	}
+
+	db, err := kdbx.New(key)
+	if err != nil {
+		panic(err)
+	}
+
+	fill(db)
+
+	f, err := os.OpenFile(opts.Path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	if err := db.Write(f); err != nil {
+		panic(err)
+	}
+
+	return opts, func() { os.RemoveAll(dir) }
+}
+
+func mustAddEntry(db *kdbx.Database, groups []string, title string, fields map[string]string) *kdbx.Entry {
+	e, err := db.AddEntry(groups, title)
+	if err != nil {
+		panic(err)
+	}
+
+	for k, v := range fields {
+		e.Set(k, v, false)
+	}
+
+	return e
+}
+
+func TestKeePassMapper(t *testing.T) {
+	opts, cleanup := newKeePassDB(t, func(db *kdbx.Database) {
+		mustAddEntry(db, nil, "mail", map[string]string{"Password": "hunter2"})
+		mustAddEntry(db, nil, "github", map[string]string{
+			"Password": "hunter2",
+			"otp":      "otpauth://totp/GitHub:john?secret=GEZDGNBVGY3TQOJQ&issuer=GitHub",
+		})
+		mustAddEntry(db, []string{"Work"}, "vpn", map[string]string{"otp": "key=GEZD GNBV&step=60&size=8"})
+		mustAddEntry(db, []string{"Work"}, "vpn", map[string]string{
+			"TimeOtp-Secret-Hex": "3132",
+			"TimeOtp-Length":     "7",
+			"TimeOtp-Period":     "45",
+			"TimeOtp-Algorithm":  "HMAC-SHA-256",
+		})
+	})
+	defer cleanup()
+
+	m := NewKeePassMapper(opts)
+
+	items, err := m.Read()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	want := []*Item{
+		{Name: "github", Issuer: "GitHub", Key: []byte("GEZDGNBVGY3TQOJQ")},
+		{Name: "Work/vpn", Key: []byte("GEZDGNBV"), Digits: 8, Step: 60},
+		{Name: "Work/vpn (2)", Key: []byte("GEZA"), Algorithm: "sha256", Digits: 7, Step: 45},
+	}
+
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("wrong items, want: %+v != got: %+v", want, items)
+	}
+
+	changed := []*Item{
+		{Name: "Work/vpn", Key: []byte("GEZDGNBV"), Digits: 8, Step: 60},
+		{Name: "Work/vpn (2)", Key: []byte("GEZDGNBVGY"), Algorithm: "sha512", Digits: 6, Step: 30},
+		{Name: "Personal/bank", Issuer: "Bank", Key: []byte("GE"), T0: 100},
+	}
+
+	if err := m.Write(changed); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	reread := NewKeePassMapper(opts)
+
+	items, err = reread.Read()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if !reflect.DeepEqual(items, changed) {
+		t.Errorf("wrong items after write, want: %+v != got: %+v", changed, items)
+	}
+
+	fields := make(map[string]map[string]string)
+	for _, e := range reread.db.Entries() {
+		name := entryName(e)
+		if _, ok := fields[name]; ok {
+			name += " (2)"
+		}
+
+		fields[name] = make(map[string]string)
+		for _, k := range e.Keys() {
+			fields[name][k], _ = e.Get(k)
+		}
+	}
+
+	for _, c := range []struct {
+		entry, key, want string
+	}{
+		// removed items keep everything but TOTP settings
+		{"github", "Password", "hunter2"},
+		{"github", "otp", ""},
+		// unchanged entries aren't rewritten
+		{"Work/vpn", "otp", "key=GEZD GNBV&step=60&size=8"},
+		// TimeOtp fields are updated in place
+		{"Work/vpn (2)", "TimeOtp-Secret-Hex", ""},
+		{"Work/vpn (2)", "TimeOtp-Secret-Base32", "GEZDGNBVGY"},
+		{"Work/vpn (2)", "TimeOtp-Length", "6"},
+		{"Work/vpn (2)", "TimeOtp-Period", "30"},
+		{"Work/vpn (2)", "TimeOtp-Algorithm", "HMAC-SHA-512"},
+		{"Work/vpn (2)", "otp", ""},
+		{"Personal/bank", "otp", "otpauth://totp/Bank:bank?issuer=Bank&secret=GE&t0=100"},
+	} {
+		if got := fields[c.entry][c.key]; got != c.want {
+			t.Errorf("%s %s: want: %q != got: %q", c.entry, c.key, c.want, got)
+		}
+	}
+
+	if _, ok := fields["mail"]; !ok {
+		t.Error("entries without TOTP settings should be kept")
+	}
+}
+
+func TestParseKeePassOTP(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		otp     string
+		want    *Item
+		wantErr bool
+	}{
+		{"uri", "otpauth://totp/a?secret=GE&digits=8", &Item{Name: "a", Key: []byte("GE"), Digits: 8}, false},
+		{"keeotp", "key=GE&size=8&step=60", &Item{Key: []byte("GE"), Digits: 8, Step: 60}, false},
+		{"keeotp without key", "size=8", nil, true},
+		{"keeotp invalid size", "key=GE&size=x", nil, true},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseKeePassOTP(c.otp)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("unwanted error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want: %+v != got: %+v", c.want, got)
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package vault
 
 import (
 	"fmt"
@@ -9,8 +9,12 @@ import (
 	"gopkg.in/ini.v1"
 )
 
+// iniSecret is the key of item secret in INI sections
+const iniSecret = "secret"
+
+// NewIniMapper returns mapper storing items as INI file sections
 func NewIniMapper(opts Opts, fn parseAlgorithmFn) *IniMapper {
-	path := filepath.Join(opts.path, opts.filename)
+	path := filepath.Join(opts.Path, opts.Filename)
 	return &IniMapper{opts, path, fn}
 }
 
@@ -28,6 +32,26 @@ func (m *IniMapper) Read() ([]*Item, error) {
 		return nil, err
 	}
 
+	return readIni(r, m.parseAlgorithmFn)
+}
+
+// Write writes config items to writer
+func (m IniMapper) Write(items []*Item) error {
+	w, err := m.writer()
+	if err != nil {
+		return err
+	}
+
+	if err := writeIni(w, items); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// readIni reads items from INI sections, sections without secrets are skipped
+func readIni(r io.Reader, fn parseAlgorithmFn) ([]*Item, error) {
 	cfg, err := ini.Load(r)
 	if err != nil {
 		return nil, err
@@ -45,14 +69,16 @@ func (m *IniMapper) Read() ([]*Item, error) {
 			return nil, err
 		}
 
-		d, err := m.parseAlgorithmFn(item.Algorithm)
+		item.Key = []byte(section.Key(iniSecret).String())
+
+		d, err := fn(item.Algorithm)
 		if err != nil {
 			return nil, err
 		}
 
 		item.digest = d
 
-		if item.Key == "" {
+		if len(item.Key) == 0 {
 			continue
 		}
 
@@ -62,35 +88,32 @@ func (m *IniMapper) Read() ([]*Item, error) {
 	return items, nil
 }
 
-// Write writes config items to writer
-func (m IniMapper) Write(items []*Item) error {
-	w, err := m.writer()
-	if err != nil {
-		return err
-	}
-
+func writeIni(w io.Writer, items []*Item) error {
 	cfg := ini.Empty()
 
 	for _, item := range items {
 		section, err := cfg.NewSection(item.Name)
 		if err != nil {
-			w.Close()
 			return err
 		}
 
-		err = section.ReflectFrom(item)
-		if err != nil {
-			w.Close()
+		// the secret isn't reflected, it follows the issuer
+		if item.Issuer != "" {
+			_, _ = section.NewKey("issuer", item.Issuer)
+		}
+
+		if _, err := section.NewKey(iniSecret, string(item.Key)); err != nil {
 			return err
 		}
-	}
 
-	if _, err := cfg.WriteTo(w); err != nil {
-		w.Close()
-		return err
+		if err := section.ReflectFrom(item); err != nil {
+			return err
+		}
 	}
 
-	return w.Close()
+	_, err := cfg.WriteTo(w)
+
+	return err
 }
 
 func (m IniMapper) reader() (io.Reader, error) {
@@ -98,25 +121,36 @@ func (m IniMapper) reader() (io.Reader, error) {
 }
 
 func (m IniMapper) writer() (io.WriteCloser, error) {
-	return m.readWriterCloser()
+	f, err := m.readWriterCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	// written config may be shorter than the existing one
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to truncate config file: %w", err)
+	}
+
+	return f, nil
 }
 
-func (m IniMapper) readWriterCloser() (io.ReadWriteCloser, error) {
-	if m.opts.path == "" {
-		m.opts.path = defaultConfigDir()
+func (m IniMapper) readWriterCloser() (*os.File, error) {
+	if m.opts.Path == "" {
+		m.opts.Path = DefaultDir()
 	}
 
-	if m.opts.filename == "" {
-		m.opts.filename = defaultConfigName
+	if m.opts.Filename == "" {
+		m.opts.Filename = DefaultConfigName
 	}
 
-	if !pathExists(m.opts.path) {
-		if err := os.Mkdir(m.opts.path, 0700); err != nil {
+	if !pathExists(m.opts.Path) {
+		if err := os.Mkdir(m.opts.Path, 0700); err != nil {
 			return nil, fmt.Errorf("failed to create directory: %w", err)
 		}
 	}
 
-	cfgPath := filepath.Join(m.opts.path, m.opts.filename)
+	cfgPath := filepath.Join(m.opts.Path, m.opts.Filename)
 
 	var getFile func(string) (*os.File, error)
 
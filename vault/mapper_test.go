@@ -1,4 +1,4 @@
-package main
+package vault
 
 import (
 	"crypto/sha1" //nolint:gosec // used in hmac only, see RFC 4226 B.2. section
@@ -19,6 +19,7 @@ secret=secret-key-1
 algorithm=sha1
 digits=6
 step=30
+t0=100
 `)
 
 	onlySecret = []byte(`[Name-2]
@@ -58,7 +59,7 @@ func TestInitMapper_Read_Create(t *testing.T) {
 	}
 	defer os.RemoveAll(dir)
 
-	mapper := NewIniMapper(Opts{path: dir}, parse)
+	mapper := NewIniMapper(Opts{Path: dir}, parse)
 
 	items, err := mapper.Read()
 	if err != nil {
@@ -69,7 +70,7 @@ func TestInitMapper_Read_Create(t *testing.T) {
 		t.Errorf("items should be nil, got: %+v", items)
 	}
 
-	cfgPath := filepath.Join(dir, mapper.opts.filename)
+	cfgPath := filepath.Join(dir, mapper.opts.Filename)
 	_, err = os.Stat(cfgPath)
 	if err != nil {
 		panic(err)
@@ -82,7 +83,7 @@ func TestMapper_Read_Open(t *testing.T) {
 
 	for _, c := range []struct {
 		name   string
-		mapper mapper
+		mapper Mapper
 		input  []byte
 		want   []*Item
 	}{
@@ -90,14 +91,14 @@ func TestMapper_Read_Open(t *testing.T) {
 			name:  "check fields parsing",
 			input: fullConfig,
 			want: []*Item{
-				{Name: "Name-1", Issuer: "issuer-1", Key: "secret-key-1", Algorithm: "sha1", Digits: 6, Step: 30},
+				{Name: "Name-1", Issuer: "issuer-1", Key: []byte("secret-key-1"), Algorithm: "sha1", Digits: 6, Step: 30, T0: 100},
 			},
 		},
 		{
 			name:  "check empty fields",
 			input: onlySecret,
 			want: []*Item{
-				{Name: "Name-2", Key: "secret-key-2"},
+				{Name: "Name-2", Key: []byte("secret-key-2")},
 			},
 		},
 
@@ -110,8 +111,8 @@ func TestMapper_Read_Open(t *testing.T) {
 			name:  "check multiple secrets",
 			input: multiple,
 			want: []*Item{
-				{Name: "Name-4", Issuer: "issuer-4", Key: "secret-key-4", Algorithm: "sha1", Digits: 6, Step: 30},
-				{Name: "Name-5", Issuer: "issuer-5", Key: "secret-key-5", Algorithm: "sha1", Digits: 6, Step: 60},
+				{Name: "Name-4", Issuer: "issuer-4", Key: []byte("secret-key-4"), Algorithm: "sha1", Digits: 6, Step: 30},
+				{Name: "Name-5", Issuer: "issuer-5", Key: []byte("secret-key-5"), Algorithm: "sha1", Digits: 6, Step: 60},
 			},
 		},
 	} {
@@ -123,7 +124,7 @@ func TestMapper_Read_Open(t *testing.T) {
 			}
 			defer os.RemoveAll(dir)
 
-			file, err := ioutil.TempFile(dir, "*"+defaultConfigName)
+			file, err := ioutil.TempFile(dir, "*"+DefaultConfigName)
 			if err != nil {
 				panic(err)
 			}
@@ -133,7 +134,7 @@ func TestMapper_Read_Open(t *testing.T) {
 				panic(err)
 			}
 
-			mapper := NewIniMapper(Opts{path: dir, filename: filepath.Base(file.Name())}, parse)
+			mapper := NewIniMapper(Opts{Path: dir, Filename: filepath.Base(file.Name())}, parse)
 
 			items, err := mapper.Read()
 			if err != nil {
@@ -0,0 +1,173 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrMergeConflict = errors.New("conflicting changes of items")
+	// ErrSharedMerge is returned if shared vault files can't be merged
+	ErrSharedMerge = errors.New("shared vault can't be merged")
+)
+
+// Conflict is an item changed differently on both sides of a merge, missing
+// versions are nil
+type Conflict struct {
+	Name         string
+	Ours, Theirs *Item
+}
+
+func (c Conflict) String() string {
+	switch {
+	case c.Ours == nil:
+		return c.Name + " is removed by ours and edited by theirs"
+	case c.Theirs == nil:
+		return c.Name + " is edited by ours and removed by theirs"
+	default:
+		return c.Name + " is changed differently by ours and theirs"
+	}
+}
+
+// Merge applies changes made to base items in ours and theirs, items are
+// matched by name. An item changed on one side only takes that change, the
+// same change on both sides is taken once. HOTP counters only grow, so an
+// item kept on both sides gets the greater counter of them. Items changed
+// differently on both sides, including edited on one side and removed on
+// the other, are returned as conflicts, ours version of them is kept.
+func Merge(base, ours, theirs []*Item) ([]*Item, []Conflict) {
+	baseByName, oursByName, theirsByName := itemsByName(base), itemsByName(ours), itemsByName(theirs)
+
+	var (
+		merged    []*Item
+		conflicts []Conflict
+	)
+
+	seen := make(map[string]struct{})
+
+	take := func(name string) {
+		if _, ok := seen[name]; ok {
+			return
+		}
+
+		seen[name] = struct{}{}
+
+		b, o, t := baseByName[name], oursByName[name], theirsByName[name]
+
+		var m *Item
+
+		switch {
+		case sameVersion(o, b):
+			m = t
+		case sameVersion(t, b), sameVersion(o, t):
+			m = o
+		default:
+			conflicts = append(conflicts, Conflict{Name: name, Ours: o, Theirs: t})
+			m = o
+		}
+
+		if m == nil {
+			return
+		}
+
+		if o != nil && t != nil && maxCounter(o, t) != m.Counter {
+			c := *m
+			c.Counter = maxCounter(o, t)
+			m = &c
+		}
+
+		merged = append(merged, m)
+	}
+
+	// ours order is kept, items added by theirs follow
+	for _, items := range [][]*Item{ours, theirs, base} {
+		for _, i := range items {
+			take(i.Name)
+		}
+	}
+
+	return merged, conflicts
+}
+
+// MergeFiles merges INI vault files as Merge does and writes the result to
+// ours file, like git merge drivers do. ErrMergeConflict describing the
+// conflicts is returned if there are any, ours versions of conflicting items
+// are written then. Shared vaults are merged by SharedMapper.MergeFiles only,
+// ErrSharedMerge is returned for them.
+func MergeFiles(base, ours, theirs string) error {
+	var sides [3][]*Item
+
+	for _, path := range []string{base, ours, theirs} {
+		if IsSharedFile(path) {
+			return fmt.Errorf("%w: it's opened by shared backend only", ErrSharedMerge)
+		}
+	}
+
+	for n, path := range []string{base, ours, theirs} {
+		items, err := iniFileMapper(path).Read()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		sides[n] = items
+	}
+
+	merged, conflicts := Merge(sides[0], sides[1], sides[2])
+
+	if err := iniFileMapper(ours).Write(merged); err != nil {
+		return err
+	}
+
+	return conflictsError(conflicts)
+}
+
+// conflictsError returns ErrMergeConflict describing the conflicts, nil if
+// there are none
+func conflictsError(conflicts []Conflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		descriptions = append(descriptions, c.String())
+	}
+
+	return fmt.Errorf("%w: %s", ErrMergeConflict, strings.Join(descriptions, "; "))
+}
+
+func iniFileMapper(path string) *IniMapper {
+	return NewIniMapper(Opts{Path: filepath.Dir(path), Filename: filepath.Base(path)}, ParseAlgorithm)
+}
+
+func itemsByName(items []*Item) map[string]*Item {
+	res := make(map[string]*Item, len(items))
+	for _, i := range items {
+		res[i.Name] = i
+	}
+
+	return res
+}
+
+// sameVersion reports whether items are equal but for their counters, or
+// both missing
+func sameVersion(a, b *Item) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	c := *a
+	c.Counter = b.Counter
+
+	return c.Equal(b)
+}
+
+func maxCounter(a, b *Item) uint64 {
+	if a.Counter > b.Counter {
+		return a.Counter
+	}
+
+	return b.Counter
+}
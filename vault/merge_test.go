@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	a := &Item{Name: "a", Key: []byte("GE")}
+	a2 := &Item{Name: "a", Key: []byte("GE"), Digits: 8}
+	a3 := &Item{Name: "a", Key: []byte("GE"), Step: 60}
+	b := &Item{Name: "b", Key: []byte("GE")}
+	c := &Item{Name: "c", Key: []byte("GE")}
+	d := &Item{Name: "d", Key: []byte("GE")}
+	h := &Item{Name: "h", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 3}
+	h5 := &Item{Name: "h", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 5}
+	h7 := &Item{Name: "h", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 7}
+	h8 := &Item{Name: "h", Key: []byte("GE"), Counter: 8}
+	hEdited := &Item{Name: "h", Issuer: "VPN", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 4}
+	hEdited7 := &Item{Name: "h", Issuer: "VPN", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 7}
+
+	for _, tc := range []struct {
+		name              string
+		base, ours, their []*Item
+		want              []*Item
+		conflicts         []Conflict
+	}{
+		{"unchanged", []*Item{a, b}, []*Item{a, b}, []*Item{a, b}, []*Item{a, b}, nil},
+		{"added on both sides", []*Item{a}, []*Item{a, c}, []*Item{a, d}, []*Item{a, c, d}, nil},
+		{"same item added on both sides", nil, []*Item{c}, []*Item{c}, []*Item{c}, nil},
+		{"edited by ours", []*Item{a, b}, []*Item{a2, b}, []*Item{a, b}, []*Item{a2, b}, nil},
+		{"edited by theirs", []*Item{a, b}, []*Item{a, b}, []*Item{a2, b}, []*Item{a2, b}, nil},
+		{"same edit", []*Item{a}, []*Item{a2}, []*Item{a2}, []*Item{a2}, nil},
+		{"removed by theirs", []*Item{a, b}, []*Item{a, b}, []*Item{b}, []*Item{b}, nil},
+		{"removed by both", []*Item{a, b}, []*Item{b}, []*Item{b}, []*Item{b}, nil},
+		{"conflicting edits", []*Item{a, b}, []*Item{a2, b}, []*Item{a3, c}, []*Item{a2, c}, []Conflict{{"a", a2, a3}}},
+		{"edited and removed", []*Item{a, b}, []*Item{b}, []*Item{a2, b}, []*Item{b}, []Conflict{{"a", nil, a2}}},
+		{"different items added", nil, []*Item{a2}, []*Item{a3}, []*Item{a2}, []Conflict{{"a", a2, a3}}},
+		{"counters of both sides", []*Item{h}, []*Item{h5}, []*Item{h7}, []*Item{h7}, nil},
+		{"counter of theirs", []*Item{h}, []*Item{h}, []*Item{h5}, []*Item{h5}, nil},
+		{"edit and counter", []*Item{h}, []*Item{hEdited}, []*Item{h7}, []*Item{hEdited7}, nil},
+		{"conflict keeps greater counter", []*Item{h}, []*Item{hEdited}, []*Item{h8}, []*Item{{Name: "h", Issuer: "VPN", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 8}}, []Conflict{{"h", hEdited, h8}}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, conflicts := Merge(tc.base, tc.ours, tc.their)
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("wrong items, want: %+v != got: %+v", tc.want, got)
+			}
+
+			if !reflect.DeepEqual(conflicts, tc.conflicts) {
+				t.Errorf("wrong conflicts, want: %v != got: %v", tc.conflicts, conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			panic(err)
+		}
+
+		return path
+	}
+
+	base := write("base", "[a]\nsecret = GE\n")
+	ours := write("ours", "[a]\nsecret = GE\ndigits = 8\n")
+	theirs := write("theirs", "[a]\nsecret = GE\n\n[b]\nsecret = GEZA\n")
+
+	if err := MergeFiles(base, ours, theirs); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	got, err := iniFileMapper(ours).Read()
+	if err != nil {
+		panic(err)
+	}
+
+	want := []*Item{{Name: "a", Key: []byte("GE"), Digits: 8}, {Name: "b", Key: []byte("GEZA")}}
+	if len(got) != len(want) || !got[0].Equal(want[0]) || !got[1].Equal(want[1]) {
+		t.Errorf("wrong merged items, want: %+v != got: %+v", want, got)
+	}
+
+	theirs = write("theirs", "[a]\nsecret = GE\ndigits = 7\n")
+	err = MergeFiles(base, ours, theirs)
+	if !errors.Is(err, ErrMergeConflict) || !strings.Contains(err.Error(), "a is changed differently") {
+		t.Errorf("error should match with %v and describe the conflict, got: %v", ErrMergeConflict, err)
+	}
+
+	if got, _ := iniFileMapper(ours).Read(); len(got) != 2 || got[0].Digits != 8 {
+		t.Errorf("ours version of conflicting item should be kept, got: %+v", got)
+	}
+}
@@ -0,0 +1,319 @@
+package vault
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	passExt   = ".gpg"
+	passGPGID = ".gpg-id"
+
+	defaultGPG = "gpg"
+
+	// DefaultPassPrefix is the store subdirectory items are kept in, so
+	// reading the vault doesn't decrypt every password of the store
+	DefaultPassPrefix = "otp"
+)
+
+// PassOpts are options of pass(1) password store mapper
+type PassOpts struct {
+	// Dir is the store directory, $PASSWORD_STORE_DIR or ~/.password-store by default
+	Dir string
+	// Prefix is a subdirectory of the store items are kept in,
+	// DefaultPassPrefix by default, "." for the whole store
+	Prefix string
+	// GPG is gpg binary path, gpg from PATH by default
+	GPG string
+}
+
+// NewPassMapper returns mapper keeping each item as otpauth URI line of a
+// pass entry, the layout pass-otp extension uses. Item name is the entry path
+// relative to the prefix, entries without otpauth line are ignored.
+func NewPassMapper(opts PassOpts) *PassMapper {
+	if opts.Dir == "" {
+		opts.Dir = os.Getenv("PASSWORD_STORE_DIR")
+	}
+
+	if opts.Dir == "" {
+		opts.Dir = filepath.Join(os.Getenv("HOME"), ".password-store")
+	}
+
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPassPrefix
+	}
+
+	if opts.GPG == "" {
+		opts.GPG = defaultGPG
+	}
+
+	return &PassMapper{opts: opts, sums: make(map[string]uriSum)}
+}
+
+type PassMapper struct {
+	opts PassOpts
+	// sums are hashes of otpauth URIs of the entries as they were read, so
+	// unchanged entries aren't re-encrypted and removed items can be found
+	sums map[string]uriSum
+}
+
+// Read decrypts all entries under the prefix and returns their items
+func (m *PassMapper) Read() ([]*Item, error) {
+	root := m.root()
+	items := make([]*Item, 0)
+
+	if !pathExists(root) {
+		return items, nil
+	}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && strings.HasPrefix(info.Name(), ".") && path != root {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() || !strings.HasSuffix(path, passExt) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(strings.TrimSuffix(rel, passExt))
+
+		content, err := m.decrypt(path)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", name, err)
+		}
+
+		uri := findURI(content)
+		if uri == "" {
+			return nil
+		}
+
+		item, err := ParseURI(uri)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		item.Name = name
+		m.sums[name] = sumURI(uri)
+		items = append(items, item)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Write encrypts changed items to the recipients of .gpg-id nearest to the
+// entry. The otpauth line of existing entries is replaced, other lines are
+// kept. Entries of removed items lose the otpauth line or are deleted if
+// nothing else is left.
+func (m *PassMapper) Write(items []*Item) error {
+	written := make(map[string]struct{}, len(items))
+
+	for _, i := range items {
+		written[i.Name] = struct{}{}
+
+		uri := i.ExtendedURI()
+
+		sum := sumURI(uri)
+		if prev, ok := m.sums[i.Name]; ok && prev == sum {
+			continue
+		}
+
+		if err := m.writeEntry(i.Name, uri); err != nil {
+			return fmt.Errorf("failed to write %s: %w", i.Name, err)
+		}
+
+		m.sums[i.Name] = sum
+	}
+
+	removed := make([]string, 0)
+	for name := range m.sums {
+		if _, ok := written[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(removed)
+
+	for _, name := range removed {
+		if err := m.writeEntry(name, ""); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+
+		delete(m.sums, name)
+	}
+
+	return nil
+}
+
+// writeEntry replaces otpauth line of the entry with uri, empty uri removes it
+func (m *PassMapper) writeEntry(name, uri string) error {
+	path, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	if pathExists(path) {
+		if content, err = m.decrypt(path); err != nil {
+			return err
+		}
+	}
+
+	content = replaceURI(content, uri)
+
+	if len(bytes.TrimSpace(content)) == 0 {
+		return os.Remove(path)
+	}
+
+	recipients, err := m.recipients(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return m.encrypt(path, content, recipients)
+}
+
+func (m *PassMapper) root() string {
+	return filepath.Join(m.opts.Dir, filepath.FromSlash(m.opts.Prefix))
+}
+
+// path returns file of the entry, names can't point outside of the root
+func (m *PassMapper) path(name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+
+	if rel == "." || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" ||
+		rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is outside of the store", ErrInvalidItem, name)
+	}
+
+	return filepath.Join(m.root(), rel+passExt), nil
+}
+
+// recipients reads .gpg-id of the directory or of the nearest parent up to
+// the store directory, like pass does
+func (m *PassMapper) recipients(dir string) ([]string, error) {
+	for {
+		data, err := ioutil.ReadFile(filepath.Join(dir, passGPGID))
+		if err == nil {
+			var ids []string
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+					ids = append(ids, line)
+				}
+			}
+
+			return ids, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if filepath.Clean(dir) == filepath.Clean(m.opts.Dir) {
+			return nil, fmt.Errorf("no %s found, run pass init", passGPGID)
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (m *PassMapper) decrypt(path string) ([]byte, error) {
+	return m.gpg(nil, "--decrypt", path)
+}
+
+func (m *PassMapper) encrypt(path string, content []byte, recipients []string) error {
+	args := []string{"--encrypt", "--no-encrypt-to", "--compress-algo=none", "--output", path}
+	for _, r := range recipients {
+		args = append(args, "--recipient", r)
+	}
+
+	_, err := m.gpg(content, args...)
+
+	return err
+}
+
+func (m *PassMapper) gpg(stdin []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(m.opts.GPG, append([]string{"--quiet", "--batch", "--yes"}, args...)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", m.opts.GPG, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func findURI(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); strings.HasPrefix(line, uriScheme+"://") {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// replaceURI replaces the first otpauth line of content, the line is appended
+// if there is none. pass keeps the password on the first line, so the URI of
+// a new entry is its only line.
+func replaceURI(content []byte, uri string) []byte {
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(content) == 0 {
+		lines = nil
+	}
+
+	replaced := false
+	result := make([]string, 0, len(lines)+1)
+
+	for _, line := range lines {
+		if !replaced && strings.HasPrefix(strings.TrimSpace(line), uriScheme+"://") {
+			replaced = true
+			if uri != "" {
+				result = append(result, uri)
+			}
+
+			continue
+		}
+
+		result = append(result, line)
+	}
+
+	if !replaced && uri != "" {
+		result = append(result, uri)
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(result, "\n") + "\n")
+}
@@ -0,0 +1,270 @@
+package vault
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const passTestKey = "clotp-test@example.com"
+
+// newPassStore creates temporary GNUPGHOME with a passphrase-less key and a
+// pass store initialized for it
+func newPassStore(t *testing.T) (store string, gpg string, cleanup func()) {
+	gpg, err := exec.LookPath("gpg")
+	if err != nil {
+		t.Skip("gpg isn't installed")
+	}
+
+	dir, err := ioutil.TempDir("", "pass")
+	if err != nil {
+		panic(err)
+	}
+
+	home := filepath.Join(dir, "gnupg")
+	store = filepath.Join(dir, "store")
+
+	for _, d := range []string{home, store} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			panic(err)
+		}
+	}
+
+	prev, hadPrev := os.LookupEnv("GNUPGHOME")
+	os.Setenv("GNUPGHOME", home)
+
+	cleanup = func() {
+		_ = exec.Command("gpgconf", "--kill", "gpg-agent").Run()
+
+		if hadPrev {
+			os.Setenv("GNUPGHOME", prev)
+		} else {
+			os.Unsetenv("GNUPGHOME")
+		}
+
+		os.RemoveAll(dir)
+	}
+
+	out, err := exec.Command(gpg, "--batch", "--passphrase", "", "--quick-gen-key", passTestKey, "future-default", "default", "never").CombinedOutput()
+	if err != nil {
+		cleanup()
+		t.Fatalf("failed to generate key: %v: %s", err, out)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(store, passGPGID), []byte(passTestKey+"\n"), 0600); err != nil {
+		panic(err)
+	}
+
+	return store, gpg, cleanup
+}
+
+func mustPath(m *PassMapper, name string) string {
+	path, err := m.path(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return path
+}
+
+func TestPassMapper(t *testing.T) {
+	store, gpg, cleanup := newPassStore(t)
+	defer cleanup()
+
+	m := NewPassMapper(PassOpts{Dir: store, Prefix: ".", GPG: gpg})
+
+	// an existing password entry gets the otpauth line appended
+	existing := NewPassMapper(PassOpts{Dir: store, Prefix: ".", GPG: gpg})
+	if err := os.Mkdir(filepath.Join(store, "web"), 0700); err != nil {
+		panic(err)
+	}
+
+	if err := existing.encrypt(mustPath(existing, "web/bank"), []byte("hunter2\nuser: john\n"), []string{passTestKey}); err != nil {
+		panic(err)
+	}
+
+	items, err := m.Read()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if len(items) != 0 {
+		t.Fatalf("entries without otpauth line should be ignored, got: %+v", items)
+	}
+
+	want := []*Item{
+		{Name: "github", Key: []byte("GEZDGNBVGY3TQOJQ")},
+		{Name: "web/bank", Issuer: "Bank", Key: []byte("GE"), Algorithm: "sha256", Digits: 8, T0: 100},
+		{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 3},
+	}
+
+	if err := m.Write(want); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	content, err := m.decrypt(mustPath(m, "web/bank"))
+	if err != nil {
+		panic(err)
+	}
+
+	wantContent := "hunter2\nuser: john\n" + want[1].ExtendedURI() + "\n"
+	if string(content) != wantContent {
+		t.Errorf("wrong entry content, want: %q != got: %q", wantContent, content)
+	}
+
+	got, err := NewPassMapper(PassOpts{Dir: store, Prefix: ".", GPG: gpg}).Read()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	byName := func(items []*Item) map[string]*Item {
+		res := make(map[string]*Item)
+		for _, i := range items {
+			res[i.Name] = i
+		}
+
+		return res
+	}
+
+	if !reflect.DeepEqual(byName(got), byName(want)) {
+		t.Errorf("wrong items, want: %+v != got: %+v", want, got)
+	}
+
+	// removed items lose the otpauth line, empty entries are deleted
+	if err := m.Write(want[2:]); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if pathExists(mustPath(m, "github")) {
+		t.Error("entry of removed item should be deleted")
+	}
+
+	content, err = m.decrypt(mustPath(m, "web/bank"))
+	if err != nil {
+		panic(err)
+	}
+
+	if string(content) != "hunter2\nuser: john\n" {
+		t.Errorf("password lines should be kept, got: %q", content)
+	}
+}
+
+func TestPassMapper_Prefix(t *testing.T) {
+	store, gpg, cleanup := newPassStore(t)
+	defer cleanup()
+
+	// other entries of the store aren't decrypted
+	other := NewPassMapper(PassOpts{Dir: store, Prefix: ".", GPG: "/nonexistent/gpg"})
+	if err := ioutil.WriteFile(mustPath(other, "email"), []byte("not encrypted"), 0600); err != nil {
+		panic(err)
+	}
+
+	m := NewPassMapper(PassOpts{Dir: store, GPG: gpg})
+	if err := m.Write([]*Item{{Name: "github", Key: []byte("GE")}}); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if !pathExists(filepath.Join(store, DefaultPassPrefix, "github.gpg")) {
+		t.Error("entry should be written under the default prefix")
+	}
+
+	if _, err := m.Read(); err != nil {
+		t.Errorf("entries outside of the prefix should be skipped: %v", err)
+	}
+
+	if err := os.Remove(mustPath(other, "email")); err != nil {
+		panic(err)
+	}
+
+	items, err := NewPassMapper(PassOpts{Dir: store, Prefix: ".", GPG: gpg}).Read()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if len(items) != 1 || items[0].Name != "otp/github" {
+		t.Errorf("wrong items read from the store root: %+v", items)
+	}
+}
+
+func TestPassMapper_GPGError(t *testing.T) {
+	store, _, cleanup := newPassStore(t)
+	defer cleanup()
+
+	m := NewPassMapper(PassOpts{Dir: store, GPG: "/nonexistent/gpg"})
+
+	err := m.Write([]*Item{{Name: "github", Key: []byte("GE")}})
+	if err == nil || !strings.Contains(err.Error(), "/nonexistent/gpg") {
+		t.Errorf("error should mention gpg binary, got: %v", err)
+	}
+}
+
+func TestPassMapper_Path(t *testing.T) {
+	m := NewPassMapper(PassOpts{Dir: "/store", Prefix: "otp"})
+
+	for _, c := range []struct {
+		name string
+		want string
+	}{
+		{name: "github", want: "/store/otp/github.gpg"},
+		{name: "web/bank", want: "/store/otp/web/bank.gpg"},
+		{name: "web/../bank", want: "/store/otp/bank.gpg"},
+		{name: "../bank"},
+		{name: "web/../../bank"},
+		{name: ".."},
+		{name: "."},
+		{name: ""},
+		{name: "/etc/bank"},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := m.path(c.name)
+			if c.want == "" {
+				if !errors.Is(err, ErrInvalidItem) {
+					t.Errorf("wrong error, want: %v != got: %v", ErrInvalidItem, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unwanted error: %v", err)
+			}
+
+			if got != filepath.FromSlash(c.want) {
+				t.Errorf("wrong path, want: %s != got: %s", c.want, got)
+			}
+		})
+	}
+
+	// the name is checked before gpg runs
+	if err := m.Write([]*Item{{Name: "../escape", Key: []byte("GE")}}); !errors.Is(err, ErrInvalidItem) {
+		t.Errorf("wrong write error, want: %v != got: %v", ErrInvalidItem, err)
+	}
+}
+
+func TestReplaceURI(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		content string
+		uri     string
+		want    string
+	}{
+		{"new entry", "", "otpauth://totp/a?secret=GE", "otpauth://totp/a?secret=GE\n"},
+		{"append", "pass\n", "otpauth://totp/a?secret=GE", "pass\notpauth://totp/a?secret=GE\n"},
+		{"replace", "pass\notpauth://totp/a?secret=AA\nnote\n", "otpauth://totp/a?secret=GE", "pass\notpauth://totp/a?secret=GE\nnote\n"},
+		{"remove", "pass\notpauth://totp/a?secret=AA\n", "", "pass\n"},
+		{"remove only line", "otpauth://totp/a?secret=AA\n", "", ""},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := string(replaceURI([]byte(c.content), c.uri)); got != c.want {
+				t.Errorf("want: %q != got: %q", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mullakhmetov/clotp/envelope"
+	"github.com/mullakhmetov/clotp/securemem"
+)
+
+// DefaultSharedName is the shared vault file name in the config directory
+const DefaultSharedName = "shared.vault"
+
+// SharedOpts are options of shared vault mapper
+type SharedOpts struct {
+	// Path is the vault file path
+	Path string
+	// Identities are private keys opening the vault, one of them should be
+	// its recipient
+	Identities []envelope.Identity
+}
+
+// NewSharedMapper returns mapper keeping items in a file encrypted to several
+// recipients, see envelope package. Items are kept as INI sections inside.
+// The vault is created on the first write, encrypted to the recipients of
+// the identities.
+func NewSharedMapper(opts SharedOpts) *SharedMapper {
+	return &SharedMapper{opts: opts}
+}
+
+type SharedMapper struct {
+	opts SharedOpts
+	// env keeps the data key of the vault read
+	env *envelope.Envelope
+}
+
+// Read decrypts the vault, a missing vault has no items
+func (m *SharedMapper) Read() ([]*Item, error) {
+	data, err := ioutil.ReadFile(m.opts.Path)
+	if os.IsNotExist(err) {
+		return []*Item{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	env, plaintext, err := envelope.Open(data, m.opts.Identities)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.opts.Path, err)
+	}
+	defer securemem.Wipe(plaintext)
+
+	items, err := readIni(bytes.NewReader(plaintext), ParseAlgorithm)
+	if err != nil {
+		env.Wipe()
+		return nil, fmt.Errorf("%s: %w", m.opts.Path, err)
+	}
+
+	if m.env != nil {
+		m.env.Wipe()
+	}
+
+	m.env = env
+
+	return items, nil
+}
+
+// Write encrypts items with the data key of the vault read, the vault is
+// replaced atomically
+func (m *SharedMapper) Write(items []*Item) error {
+	if m.env == nil {
+		if err := m.create(); err != nil {
+			return err
+		}
+	}
+
+	var b bytes.Buffer
+	defer func() { securemem.Wipe(b.Bytes()[:b.Cap()]) }()
+
+	if err := writeIni(&b, items); err != nil {
+		return err
+	}
+
+	data, err := m.env.Seal(b.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return m.save(data)
+}
+
+// Recipients returns recipients of the vault, recipients of the identities
+// if the vault doesn't exist yet
+func (m *SharedMapper) Recipients() []string {
+	if m.env == nil {
+		var res []string
+		for _, r := range m.identityRecipients() {
+			res = append(res, r.String())
+		}
+
+		return res
+	}
+
+	return m.env.Recipients()
+}
+
+// AddRecipient wraps the data key for the recipient, the vault should be
+// written then
+func (m *SharedMapper) AddRecipient(r envelope.Recipient) error {
+	if m.env == nil {
+		if err := m.create(); err != nil {
+			return err
+		}
+	}
+
+	return m.env.Add(r)
+}
+
+// RemoveRecipient removes the recipient and rotates the data key, the vault
+// should be written then. Removed recipient may keep secrets it has seen, so
+// they should be rotated as well.
+func (m *SharedMapper) RemoveRecipient(r envelope.Recipient) error {
+	if m.env == nil {
+		return fmt.Errorf("%s: %w: %s", m.opts.Path, envelope.ErrUnknownRecipient, r)
+	}
+
+	return m.env.Remove(r)
+}
+
+// Wipe zeroes the data key of the vault
+func (m *SharedMapper) Wipe() {
+	if m.env != nil {
+		m.env.Wipe()
+	}
+}
+
+func (m *SharedMapper) create() error {
+	env, err := envelope.New(m.identityRecipients())
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.opts.Path, err)
+	}
+
+	m.env = env
+
+	return nil
+}
+
+// identityRecipients returns distinct recipients of the identities
+func (m *SharedMapper) identityRecipients() []envelope.Recipient {
+	var res []envelope.Recipient
+
+	seen := make(map[string]struct{})
+
+	for _, id := range m.opts.Identities {
+		r := id.Recipient()
+		if _, ok := seen[r.String()]; ok {
+			continue
+		}
+
+		seen[r.String()] = struct{}{}
+		res = append(res, r)
+	}
+
+	return res
+}
+
+func (m *SharedMapper) save(data []byte) error {
+	dir := filepath.Dir(m.opts.Path)
+	if !pathExists(dir) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	f, err := ioutil.TempFile(dir, filepath.Base(m.opts.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if info, err := os.Stat(m.opts.Path); err == nil {
+		_ = os.Chmod(f.Name(), info.Mode().Perm())
+	}
+
+	if err := os.Rename(f.Name(), m.opts.Path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// MergeFiles merges shared vault files as MergeFiles does, the result is
+// encrypted with the data key of ours file. Recipient changes can't be merged
+// by items, so ErrSharedMerge is returned if the recipients of the sides
+// differ.
+func (m *SharedMapper) MergeFiles(base, ours, theirs string) error {
+	var sides [3]*SharedMapper
+
+	defer func() {
+		for _, side := range sides {
+			if side != nil {
+				side.Wipe()
+			}
+		}
+	}()
+
+	var items [3][]*Item
+
+	for n, path := range []string{base, ours, theirs} {
+		sides[n] = NewSharedMapper(SharedOpts{Path: path, Identities: m.opts.Identities})
+
+		// the side is empty if the vault is added on both sides
+		if info, err := os.Stat(path); err == nil && info.Size() == 0 {
+			continue
+		}
+
+		var err error
+		if items[n], err = sides[n].Read(); err != nil {
+			return err
+		}
+	}
+
+	ourSide, theirSide := sides[1], sides[2]
+	if ourSide.env == nil {
+		ourSide.env, theirSide.env = theirSide.env, nil
+	}
+
+	if theirSide.env != nil && !sameRecipients(ourSide.env.Recipients(), theirSide.env.Recipients()) {
+		return fmt.Errorf("%w: recipients are changed, sync before changing them", ErrSharedMerge)
+	}
+
+	merged, conflicts := Merge(items[0], items[1], items[2])
+
+	if err := ourSide.Write(merged); err != nil {
+		return err
+	}
+
+	return conflictsError(conflicts)
+}
+
+// IsSharedFile reports whether the file is a shared vault
+func IsSharedFile(path string) bool {
+	data, err := ioutil.ReadFile(path)
+
+	return err == nil && envelope.IsVault(data)
+}
+
+func sameRecipients(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[string]struct{}, len(a))
+	for _, r := range a {
+		set[r] = struct{}{}
+	}
+
+	for _, r := range b {
+		if _, ok := set[r]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mullakhmetov/clotp/envelope"
+)
+
+func newSSHIdentity() envelope.Identity {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		panic(err)
+	}
+
+	ids, err := envelope.ParseIdentities(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return ids[0]
+}
+
+func TestSharedMapper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "team", DefaultSharedName)
+	alice, bob := newSSHIdentity(), newSSHIdentity()
+
+	m := NewSharedMapper(SharedOpts{Path: path, Identities: []envelope.Identity{alice}})
+
+	items, err := m.Read()
+	if err != nil || len(items) != 0 {
+		t.Fatalf("missing vault isn't empty: %v %v", items, err)
+	}
+
+	if got := m.Recipients(); len(got) != 1 || got[0] != alice.Recipient().String() {
+		t.Errorf("wrong recipients of new vault: %v", got)
+	}
+
+	want := []*Item{
+		{Name: "github", Key: []byte("GE"), Algorithm: DefaultAlgorithm, Digits: DefaultDigits},
+		{Name: "vpn", Issuer: "Ops", Key: []byte("GEZA"), Algorithm: "sha256", Digits: 8},
+	}
+
+	if err := m.Write(want); err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+
+	if err := m.AddRecipient(bob.Recipient()); err != nil {
+		t.Fatalf("failed to add recipient: %v", err)
+	}
+
+	if err := m.Write(want); err != nil {
+		t.Fatalf("failed to write vault: %v", err)
+	}
+
+	bobs := NewSharedMapper(SharedOpts{Path: path, Identities: []envelope.Identity{bob}})
+
+	got, err := bobs.Read()
+	if err != nil {
+		t.Fatalf("failed to read vault by added recipient: %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("wrong items, want: %v != got: %v", want, got)
+	}
+
+	for n := range want {
+		if !got[n].Equal(want[n]) {
+			t.Errorf("wrong item, want: %+v != got: %+v", want[n], got[n])
+		}
+	}
+
+	if err := bobs.RemoveRecipient(alice.Recipient()); err != nil {
+		t.Fatalf("failed to remove recipient: %v", err)
+	}
+
+	if err := bobs.Write(got); err != nil {
+		t.Fatalf("failed to write vault: %v", err)
+	}
+
+	if _, err := m.Read(); !errors.Is(err, envelope.ErrNoIdentity) {
+		t.Errorf("removed recipient reads the vault: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(path, data[:len(data)/2], 0600); err != nil {
+		panic(err)
+	}
+
+	if _, err := bobs.Read(); !errors.Is(err, envelope.ErrInvalidVault) {
+		t.Errorf("want: %v != got: %v", envelope.ErrInvalidVault, err)
+	}
+}
+
+func TestSharedMapper_MergeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shared")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	alice, bob := newSSHIdentity(), newSSHIdentity()
+	ids := []envelope.Identity{alice}
+
+	write := func(name string, items ...*Item) string {
+		path := filepath.Join(dir, name)
+		if err := NewSharedMapper(SharedOpts{Path: path, Identities: ids}).Write(items); err != nil {
+			panic(err)
+		}
+
+		return path
+	}
+
+	base := write("base", &Item{Name: "github", Key: []byte("GE")})
+	ours := write("ours", &Item{Name: "github", Key: []byte("GE")}, &Item{Name: "gitlab", Key: []byte("GE")})
+	theirs := write("theirs", &Item{Name: "github", Key: []byte("GE")}, &Item{Name: "aws", Key: []byte("GE")})
+
+	if err := MergeFiles(base, ours, theirs); !errors.Is(err, ErrSharedMerge) {
+		t.Errorf("shared vault is merged as INI: %v", err)
+	}
+
+	m := NewSharedMapper(SharedOpts{Path: ours, Identities: ids})
+	if err := m.MergeFiles(base, ours, theirs); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	got, err := NewSharedMapper(SharedOpts{Path: ours, Identities: ids}).Read()
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if len(got) != 3 || got[0].Name != "github" || got[1].Name != "gitlab" || got[2].Name != "aws" {
+		t.Errorf("wrong merged items: %+v", got)
+	}
+
+	// theirs is encrypted to other recipients
+	other := NewSharedMapper(SharedOpts{Path: theirs, Identities: ids})
+	if _, err := other.Read(); err != nil {
+		panic(err)
+	}
+
+	if err := other.AddRecipient(bob.Recipient()); err != nil {
+		panic(err)
+	}
+
+	if err := other.Write([]*Item{{Name: "github", Key: []byte("GE")}}); err != nil {
+		panic(err)
+	}
+
+	if err := m.MergeFiles(base, ours, theirs); !errors.Is(err, ErrSharedMerge) {
+		t.Errorf("recipient changes are merged: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	uriScheme = "otpauth"
+	uriTOTP   = "totp"
+	// uriOCRA is clotp extension for OCRA items
+	uriOCRA = "ocra"
+)
+
+// ParseURI parses otpauth URI of TOTP item, see Item.URI. The item name is
+// the account part of the label. Parameters written by Item.ExtendedURI are
+// supported too.
+func ParseURI(s string) (*Item, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != uriScheme {
+		return nil, fmt.Errorf("%w: not an otpauth URI", ErrInvalidURI)
+	}
+
+	if u.Host != uriTOTP && u.Host != uriOCRA {
+		return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidURI, u.Host)
+	}
+
+	q := u.Query()
+	item := &Item{
+		Key:       []byte(q.Get("secret")),
+		Issuer:    q.Get("issuer"),
+		Algorithm: strings.ToLower(q.Get("algorithm")),
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if n := strings.Index(label, ":"); n >= 0 {
+		if item.Issuer == "" {
+			item.Issuer = label[:n]
+		}
+
+		label = strings.TrimSpace(label[n+1:])
+	}
+
+	item.Name = label
+
+	for _, p := range []struct {
+		name string
+		dst  interface{}
+	}{
+		{"digits", &item.Digits},
+		{"period", &item.Step},
+		{"t0", &item.T0},
+		{"counter", &item.Counter},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+
+		var err error
+
+		switch dst := p.dst.(type) {
+		case *int:
+			*dst, err = strconv.Atoi(v)
+		case *int64:
+			*dst, err = strconv.ParseInt(v, 10, 64)
+		case *uint64:
+			*dst, err = strconv.ParseUint(v, 10, 64)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s: %s", ErrInvalidURI, p.name, v)
+		}
+	}
+
+	if u.Host == uriOCRA {
+		item.Suite = q.Get("suite")
+	}
+
+	if len(item.Key) == 0 {
+		return nil, fmt.Errorf("%w: no secret", ErrInvalidURI)
+	}
+
+	return item, nil
+}
+
+// ExtendedURI returns otpauth URI keeping all item fields: T0 is written as
+// t0 parameter and OCRA items have ocra type with suite and counter
+// parameters. Authenticator apps don't understand them, use URI for apps.
+// Like URI it holds the secret, which Wipe can't clear.
+func (i Item) ExtendedURI() string {
+	u, q := i.otpauth()
+
+	if i.T0 != 0 {
+		q.Set("t0", strconv.FormatInt(i.T0, 10))
+	}
+
+	if i.Suite != "" {
+		u.Host = uriOCRA
+		q.Set("suite", i.Suite)
+		q.Set("counter", strconv.FormatUint(i.Counter, 10))
+	}
+
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// uriSum is hash of otpauth URI, mappers keep it to find changed items, so
+// the URIs with secrets aren't kept as strings, which can't be wiped
+type uriSum [sha256.Size]byte
+
+func sumURI(uri string) uriSum {
+	return sha256.Sum256([]byte(uri))
+}
@@ -0,0 +1,87 @@
+package vault
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		uri  string
+		want *Item
+		err  error
+	}{
+		{
+			name: "minimal",
+			uri:  "otpauth://totp/github?secret=GEZDGNBVGY3TQOJQ",
+			want: &Item{Name: "github", Key: []byte("GEZDGNBVGY3TQOJQ")},
+		},
+		{
+			name: "full",
+			uri:  "otpauth://totp/Bank:john?secret=GE&issuer=Bank&algorithm=SHA256&digits=8&period=60",
+			want: &Item{Name: "john", Issuer: "Bank", Key: []byte("GE"), Algorithm: "sha256", Digits: 8, Step: 60},
+		},
+		{
+			name: "issuer in label only",
+			uri:  "otpauth://totp/Bank:%20john?secret=GE",
+			want: &Item{Name: "john", Issuer: "Bank", Key: []byte("GE")},
+		},
+		{
+			name: "extended",
+			uri:  "otpauth://ocra/vpn?secret=GE&suite=OCRA-1:HOTP-SHA1-6:QN08&counter=42&t0=100",
+			want: &Item{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 42, T0: 100},
+		},
+		{
+			name: "hotp",
+			uri:  "otpauth://hotp/github?secret=GE&counter=1",
+			err:  ErrInvalidURI,
+		},
+		{
+			name: "no secret",
+			uri:  "otpauth://totp/github",
+			err:  ErrInvalidURI,
+		},
+		{
+			name: "invalid digits",
+			uri:  "otpauth://totp/github?secret=GE&digits=six",
+			err:  ErrInvalidURI,
+		},
+		{
+			name: "other scheme",
+			uri:  "https://example.com/",
+			err:  ErrInvalidURI,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseURI(c.uri)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("wrong error, want: %v != got: %v", c.err, err)
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("wrong item, want: %+v != got: %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestExtendedURI(t *testing.T) {
+	for _, item := range []*Item{
+		{Name: "github", Key: []byte("GEZDGNBVGY3TQOJQ")},
+		{Name: "john", Issuer: "Bank", Key: []byte("GE"), Algorithm: "sha512", Digits: 8, Step: 60, T0: 100},
+		{Name: "vpn", Key: []byte("GE"), Suite: "OCRA-1:HOTP-SHA1-6:QN08", Counter: 42},
+	} {
+		got, err := ParseURI(item.ExtendedURI())
+		if err != nil {
+			t.Errorf("unwanted error for %s: %v", item.Name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, item) {
+			t.Errorf("item changed after round trip, want: %+v != got: %+v", item, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package vault
+
+import (
+	"encoding/base32"
+
+	"github.com/mullakhmetov/clotp/securemem"
+)
+
+// DecodeBase32Secret decodes base32 secret, lowercase and unpadded secrets
+// are accepted. Temporary buffers are wiped, the caller should wipe the
+// returned secret after use.
+func DecodeBase32Secret(s []byte) ([]byte, error) {
+	padded := make([]byte, len(s)+(8-len(s)%8)%8)
+	defer securemem.Wipe(padded)
+
+	for i, c := range s {
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+
+		padded[i] = c
+	}
+
+	for i := len(s); i < len(padded); i++ {
+		padded[i] = '='
+	}
+
+	secret := make([]byte, base32.StdEncoding.DecodedLen(len(padded)))
+
+	n, err := base32.StdEncoding.Decode(secret, padded)
+	if err != nil {
+		securemem.Wipe(secret)
+		return nil, ErrInvalidSecret
+	}
+
+	return secret[:n], nil
+}
+
+// EncodeBase32Secret returns unpadded base32 representation of the secret
+func EncodeBase32Secret(b []byte) []byte {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	s := make([]byte, enc.EncodedLen(len(b)))
+	enc.Encode(s, b)
+
+	return s
+}
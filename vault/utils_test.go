@@ -0,0 +1,66 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeBase32Secret(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "empty input",
+			input: "",
+			want:  "",
+		},
+		{
+			name:  "test padding #1",
+			input: "GE",
+			want:  "1",
+		},
+		{
+			name:  "test padding #2",
+			input: "GE=====",
+			want:  "1",
+		},
+		{
+			name:  "test lowercase",
+			input: "ge=====",
+			want:  "1",
+		},
+		{
+			name:    "invalid character",
+			input:   "GE1",
+			wantErr: true,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			got, err := DecodeBase32Secret([]byte(c.input))
+			if (err != nil) != c.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(got) != c.want {
+				t.Errorf("wrong decoded value for %s input, want: %s != got: %s", c.input, c.want, got)
+			}
+		})
+	}
+}
+
+func TestEncodeBase32Secret(t *testing.T) {
+	for _, input := range []string{"", "1", "12345678901234567890"} {
+		got := EncodeBase32Secret([]byte(input))
+		if strings.Contains(string(got), "=") {
+			t.Errorf("encoded secret shouldn't be padded: %s", got)
+		}
+
+		if decoded, _ := DecodeBase32Secret(got); string(decoded) != input {
+			t.Errorf("wrong round trip value, want: %s != got: %s", input, decoded)
+		}
+	}
+}
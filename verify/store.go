@@ -0,0 +1,144 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State is verification state of a user
+type State struct {
+	// NextStep is the first time step which hasn't been used yet
+	NextStep    uint64    `json:"next_step"`
+	Failures    int       `json:"failures,omitempty"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+}
+
+// Store keeps users verification state. Load returns zero state for unknown users.
+type Store interface {
+	Load(user string) (State, error)
+	Save(user string, s State) error
+}
+
+// NewMemoryStore returns store which forgets state when the process exits
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+func (s *MemoryStore) Load(user string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.states[user], nil
+}
+
+func (s *MemoryStore) Save(user string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[user] = state
+
+	return nil
+}
+
+// NewFileStore returns store persisting state as JSON file at given path,
+// existing state is read from the file
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, states: make(map[string]State)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.states); err != nil {
+		return nil, fmt.Errorf("invalid verification state file %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	states map[string]State
+}
+
+func (s *FileStore) Load(user string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.states[user], nil
+}
+
+// Save writes the whole state to a temporary file, syncs it and renames it
+// over the store file, so a crash never leaves a partially written file
+func (s *FileStore) Save(user string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, existed := s.states[user]
+	s.states[user] = state
+
+	if err := s.write(); err != nil {
+		if existed {
+			s.states[user] = prev
+		} else {
+			delete(s.states, user)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileStore) write() error {
+	data, err := json.Marshal(s.states)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	// the rename may reach the disk before the data otherwise
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package verify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "verify.json")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	state, err := s.Load("user")
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if !reflect.DeepEqual(state, State{}) {
+		t.Errorf("state of unknown user should be zero, got: %+v", state)
+	}
+
+	want := State{NextStep: 42, Failures: 2, LockedUntil: time.Unix(1111111109, 0).UTC()}
+	if err := s.Save("user", want); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("wrong file mode: %v", info.Mode())
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	got, err := reopened.Load("user")
+	if err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong state, want: %+v != got: %+v", want, got)
+	}
+}
+
+func TestFileStore_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "verify.json")
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		panic(err)
+	}
+
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("error shouldn't be nil")
+	}
+}
@@ -0,0 +1,156 @@
+// Package verify implements server-side TOTP verification: codes are accepted
+// within a window of time steps, an accepted step can't be reused and
+// repeated failures lock the user out for a while.
+package verify
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+const (
+	DefaultWindow      = 1
+	DefaultMaxFailures = 5
+	DefaultLockout     = 5 * time.Minute
+)
+
+var (
+	ErrInvalidCode = errors.New("invalid code")
+	ErrReplayed    = errors.New("code has already been used")
+	ErrLockedOut   = errors.New("too many failed attempts")
+)
+
+// Opts configures Validator, zero MaxFailures, Lockout and Clock are
+// replaced by defaults
+type Opts struct {
+	// Window is how many time steps before and after the current one are
+	// accepted, zero accepts the current step only
+	Window int
+	// MaxFailures is how many failed attempts in a row lock the user out
+	MaxFailures int
+	// Lockout is how long the user is locked out for
+	Lockout time.Duration
+	Clock   totp.Clock
+}
+
+// NewValidator returns validator keeping users state in given store
+func NewValidator(store Store, opts Opts) *Validator {
+	if opts.Window < 0 {
+		opts.Window = 0
+	}
+
+	if opts.MaxFailures <= 0 {
+		opts.MaxFailures = DefaultMaxFailures
+	}
+
+	if opts.Lockout <= 0 {
+		opts.Lockout = DefaultLockout
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = totp.SystemClock
+	}
+
+	return &Validator{opts: opts, store: store, locks: make(map[string]*userLock)}
+}
+
+type Validator struct {
+	opts  Opts
+	store Store
+
+	// mu guards locks
+	mu sync.Mutex
+	// locks serialize load-check-save of a user state, users don't wait for
+	// each other
+	locks map[string]*userLock
+}
+
+// userLock is a lock of a user, it's removed when nobody holds or waits for it
+type userLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the user state and returns function unlocking it
+func (v *Validator) lock(user string) func() {
+	v.mu.Lock()
+
+	l := v.locks[user]
+	if l == nil {
+		l = &userLock{}
+		v.locks[user] = l
+	}
+
+	l.refs++
+	v.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		if l.refs--; l.refs == 0 {
+			delete(v.locks, user)
+		}
+	}
+}
+
+// Validate checks code of the user generated by t. On success the matched step
+// is returned and it and all the steps before it are rejected afterwards.
+// Errors are ErrInvalidCode, ErrReplayed, ErrLockedOut or store errors.
+func (v *Validator) Validate(user string, t *totp.TOTP, code string) (uint64, error) {
+	defer v.lock(user)()
+
+	now := v.opts.Clock.Now()
+
+	state, err := v.store.Load(user)
+	if err != nil {
+		return 0, err
+	}
+
+	if now.Before(state.LockedUntil) {
+		return 0, ErrLockedOut
+	}
+
+	step, matched, err := t.Match(now, v.opts.Window, v.opts.Window, code)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case !matched:
+		err = ErrInvalidCode
+	case step < state.NextStep:
+		err = ErrReplayed
+	}
+
+	if err != nil {
+		state.Failures++
+		if state.Failures >= v.opts.MaxFailures {
+			state.Failures = 0
+			state.LockedUntil = now.Add(v.opts.Lockout)
+		}
+
+		if serr := v.store.Save(user, state); serr != nil {
+			return 0, serr
+		}
+
+		return 0, err
+	}
+
+	state.NextStep = step + 1
+	state.Failures = 0
+	state.LockedUntil = time.Time{}
+
+	if err := v.store.Save(user, state); err != nil {
+		return 0, err
+	}
+
+	return step, nil
+}
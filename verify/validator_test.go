@@ -0,0 +1,163 @@
+package verify
+
+import (
+	"crypto/sha1"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mullakhmetov/clotp/totp"
+)
+
+// RFC 6238 SHA1 codes for steps around 1111111109
+const (
+	prevCode    = "89731029"
+	currentCode = "07081804"
+	nextCode    = "14050471"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestTOTP() *totp.TOTP {
+	return totp.NewTOTP(totp.Opts{Digits: 8, Secret: []byte("12345678901234567890"), Algorithm: sha1.New}, 30)
+}
+
+func TestValidator_Window(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		window   int
+		code     string
+		wantStep uint64
+		wantErr  error
+	}{
+		{name: "current", window: 1, code: currentCode, wantStep: 37037036},
+		{name: "previous", window: 1, code: prevCode, wantStep: 37037035},
+		{name: "next", window: 1, code: nextCode, wantStep: 37037037},
+		{name: "next outside window", window: 0, code: nextCode, wantErr: ErrInvalidCode},
+		{name: "current zero window", window: 0, code: currentCode, wantStep: 37037036},
+		{name: "wrong", window: 1, code: "12345678", wantErr: ErrInvalidCode},
+		{name: "short", window: 1, code: "081804", wantErr: ErrInvalidCode},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			clock := &testClock{time.Unix(1111111109, 0)}
+			v := NewValidator(NewMemoryStore(), Opts{Window: c.window, Clock: clock})
+
+			step, err := v.Validate("user", newTestTOTP(), c.code)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("wrong error, want: %v != got: %v", c.wantErr, err)
+			}
+
+			if step != c.wantStep {
+				t.Errorf("wrong step, want: %d != got: %d", c.wantStep, step)
+			}
+		})
+	}
+}
+
+func TestValidator_Replay(t *testing.T) {
+	clock := &testClock{time.Unix(1111111109, 0)}
+	v := NewValidator(NewMemoryStore(), Opts{Window: 1, Clock: clock})
+	tp := newTestTOTP()
+
+	if _, err := v.Validate("user", tp, currentCode); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	// the same code and codes of earlier steps are rejected
+	for _, code := range []string{currentCode, prevCode} {
+		if _, err := v.Validate("user", tp, code); !errors.Is(err, ErrReplayed) {
+			t.Errorf("wrong error for %s, want: %v != got: %v", code, ErrReplayed, err)
+		}
+	}
+
+	if _, err := v.Validate("other", tp, currentCode); err != nil {
+		t.Errorf("unwanted error for other user: %v", err)
+	}
+
+	if _, err := v.Validate("user", tp, nextCode); err != nil {
+		t.Errorf("unwanted error for next code: %v", err)
+	}
+}
+
+func TestValidator_Lockout(t *testing.T) {
+	clock := &testClock{time.Unix(1111111109, 0)}
+	v := NewValidator(NewMemoryStore(), Opts{Window: 1, MaxFailures: 3, Lockout: time.Minute, Clock: clock})
+	tp := newTestTOTP()
+
+	// failures are reset by a successful attempt
+	for _, code := range []string{"1", "2", currentCode, "3", "4"} {
+		_, _ = v.Validate("user", tp, code)
+	}
+
+	if _, err := v.Validate("user", tp, nextCode); err != nil {
+		t.Fatalf("unwanted error: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.Validate("user", tp, "wrong"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("wrong error, want: %v != got: %v", ErrInvalidCode, err)
+		}
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+
+	code := tp.CodeFor(tp.CounterAt(clock.now)).Value
+	if _, err := v.Validate("user", tp, code); !errors.Is(err, ErrLockedOut) {
+		t.Errorf("wrong error, want: %v != got: %v", ErrLockedOut, err)
+	}
+
+	clock.now = clock.now.Add(31 * time.Second)
+
+	code = tp.CodeFor(tp.CounterAt(clock.now)).Value
+	if _, err := v.Validate("user", tp, code); err != nil {
+		t.Errorf("unwanted error after lockout: %v", err)
+	}
+}
+
+func TestValidator_Concurrent(t *testing.T) {
+	clock := &testClock{time.Unix(1111111109, 0)}
+	v := NewValidator(NewMemoryStore(), Opts{Window: 1, Clock: clock})
+
+	const n = 10
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted = make(map[string]int)
+	)
+
+	for i := 0; i < n; i++ {
+		for _, user := range []string{"user", "other"} {
+			wg.Add(1)
+
+			go func(user string) {
+				defer wg.Done()
+
+				if _, err := v.Validate(user, newTestTOTP(), currentCode); err == nil {
+					mu.Lock()
+					accepted[user]++
+					mu.Unlock()
+				}
+			}(user)
+		}
+	}
+
+	wg.Wait()
+
+	// a code is accepted once per user
+	if accepted["user"] != 1 || accepted["other"] != 1 {
+		t.Errorf("wrong accepted codes: %v", accepted)
+	}
+
+	if len(v.locks) != 0 {
+		t.Errorf("unused locks are kept: %d", len(v.locks))
+	}
+}